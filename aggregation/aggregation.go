@@ -0,0 +1,604 @@
+package aggregation
+
+import (
+	"sort"
+	"strconv"
+
+	db "github.com/YaleOpenLab/openclimate/database"
+	"github.com/pkg/errors"
+)
+
+/*
+
+	Rolls the emissions of climate actors up through the nested scopes
+	described in docs/nested.md: assets are rolled up into companies,
+	companies and cities into states and regions, and states, regions,
+	cities and companies into countries.
+
+	A company's plant in a city is counted by the city, by the state the city
+	is in and by the company itself, so simply adding up the totals reported
+	at each level counts the plant several times. Following docs/nested.md,
+	emissions are tracked per asset, and every part of an actor lists the
+	assets its total includes. Assets included by more than one part are
+	double counted, and their emissions are subtracted from the sum of parts.
+
+	Emissions that are not tracked per asset (e.g. households in a city) can't
+	be told apart, so overlap between two parts is only detected for assets.
+
+*/
+
+// Result is the aggregated emissions of a climate actor for one metric and year
+type Result struct {
+	ActorType string
+	ActorID   int
+	Name      string
+	Metric    string
+	Year      int
+
+	Reported    float64 // total reported by the actor itself
+	HasReported bool
+
+	SumOfParts   float64 // sum of the totals of the actor's parts
+	Overlap      float64 // emissions included in the totals of more than one part
+	Deduplicated float64 // SumOfParts - Overlap
+
+	// Reported - Deduplicated: emissions that are not covered by any of the
+	// actor's parts. Negative if the parts add up to more than the reported total.
+	Unattributed float64
+
+	// Reported if the actor reported a total, Deduplicated otherwise
+	Total float64
+
+	Parts         []Part
+	DoubleCounted []DoubleCount
+}
+
+// Part is one of the parts an actor's emissions are aggregated from
+type Part struct {
+	ActorType string
+	ActorID   int
+	Name      string
+	Value     float64
+	Reported  bool  // whether Value was reported by the part or aggregated from its own parts
+	Assets    []int // the assets whose emissions are included in Value
+}
+
+// DoubleCount is an asset whose emissions are included in more than one part
+type DoubleCount struct {
+	AssetID   int
+	Name      string
+	Value     float64
+	CountedBy []string // the parts that include the asset, e.g. "state/3"
+}
+
+// location is where an asset is. IDs are 0 if unknown.
+type location struct {
+	city    int
+	state   int
+	region  int
+	country int
+}
+
+// ErrActorNotFound is returned when aggregating an actor that doesn't exist
+var ErrActorNotFound = errors.New("actor not found")
+
+type engine struct {
+	metric string
+	year   int
+	source string
+
+	values map[string]float64 // annual values by actor key
+
+	assets    []db.Asset
+	locations map[int]location
+	companies map[int]db.Company
+	cities    map[int]db.City
+	states    map[int]db.State
+	regions   map[int]db.Region
+	countries map[int]db.Country
+
+	// sorted IDs, so that results don't depend on map iteration order
+	companyIDs []int
+	cityIDs    []int
+	stateIDs   []int
+	regionIDs  []int
+	countryIDs []int
+
+	results map[string]Result
+}
+
+func key(actorType string, actorID int) string {
+	return actorType + "/" + strconv.Itoa(actorID)
+}
+
+/*
+	Aggregate rolls up the emissions of a climate actor's parts for the given
+	metric (e.g. scope1) and year. Values are taken from the time series of
+	the given source, self-reported if source is empty. Assets report their
+	values under the actor type "asset".
+*/
+func Aggregate(actorType string, actorID int, metric string, year int, source string) (Result, error) {
+	var result Result
+
+	e, err := newEngine(metric, year, source)
+	if err != nil {
+		return result, errors.Wrap(err, "could not load actors")
+	}
+
+	if !e.exists(actorType, actorID) {
+		return result, ErrActorNotFound
+	}
+
+	return e.aggregate(actorType, actorID), nil
+}
+
+// Assets returns the assets of a company, or the assets located in the area
+// of a city, state, region or country, sorted by ID
+func Assets(actorType string, actorID int) ([]db.Asset, error) {
+	var assets []db.Asset
+
+	e, err := newEngine("", 0, "")
+	if err != nil {
+		return assets, errors.Wrap(err, "could not load actors")
+	}
+
+	if !e.exists(actorType, actorID) {
+		return assets, ErrActorNotFound
+	}
+
+	for _, asset := range e.assets {
+		if (actorType == "company" && asset.CompanyID == actorID) || e.within(asset.Index, actorType, actorID) {
+			assets = append(assets, asset)
+		}
+	}
+
+	sort.Slice(assets, func(i, j int) bool {
+		return assets[i].Index < assets[j].Index
+	})
+	return assets, nil
+}
+
+func newEngine(metric string, year int, source string) (*engine, error) {
+	e := &engine{metric: metric, year: year, source: source}
+	if e.source == "" {
+		e.source = db.SourceSelfReported
+	}
+
+	points, err := db.RetrieveAllDataPoints()
+	if err != nil {
+		return e, err
+	}
+	e.values = annualValues(points, metric, year, e.source)
+
+	e.assets, err = db.RetrieveAllAssets()
+	if err != nil {
+		return e, err
+	}
+
+	companies, err := db.RetrieveAllCompanies()
+	if err != nil {
+		return e, err
+	}
+	e.companies = make(map[int]db.Company)
+	for _, company := range companies {
+		e.companies[company.Index] = company
+		e.companyIDs = append(e.companyIDs, company.Index)
+	}
+	sort.Ints(e.companyIDs)
+
+	cities, err := db.RetrieveAllCities()
+	if err != nil {
+		return e, err
+	}
+	e.cities = make(map[int]db.City)
+	for _, city := range cities {
+		e.cities[city.Index] = city
+		e.cityIDs = append(e.cityIDs, city.Index)
+	}
+	sort.Ints(e.cityIDs)
+
+	states, err := db.RetrieveAllStates()
+	if err != nil {
+		return e, err
+	}
+	e.states = make(map[int]db.State)
+	for _, state := range states {
+		e.states[state.Index] = state
+		e.stateIDs = append(e.stateIDs, state.Index)
+	}
+	sort.Ints(e.stateIDs)
+
+	regions, err := db.RetrieveAllRegions()
+	if err != nil {
+		return e, err
+	}
+	e.regions = make(map[int]db.Region)
+	for _, region := range regions {
+		e.regions[region.Index] = region
+		e.regionIDs = append(e.regionIDs, region.Index)
+	}
+	sort.Ints(e.regionIDs)
+
+	countries, err := db.RetrieveAllCountries()
+	if err != nil {
+		return e, err
+	}
+	e.countries = make(map[int]db.Country)
+	for _, country := range countries {
+		e.countries[country.Index] = country
+		e.countryIDs = append(e.countryIDs, country.Index)
+	}
+	sort.Ints(e.countryIDs)
+
+	e.locations = make(map[int]location)
+	for _, asset := range e.assets {
+		e.locations[asset.Index] = e.locate(asset)
+	}
+
+	e.results = make(map[string]Result)
+	return e, nil
+}
+
+// annualValues returns the value of a metric in a year for every actor. A
+// reported annual value takes precedence over the sum of monthly values.
+func annualValues(points []db.DataPoint, metric string, year int, source string) map[string]float64 {
+	annual := make(map[string]float64)
+	monthly := make(map[string]float64)
+	period := strconv.Itoa(year)
+
+	for _, point := range points {
+		if point.Metric != metric || point.Source != source || len(point.Period) < 4 || point.Period[:4] != period {
+			continue
+		}
+
+		k := key(point.ActorType, point.ActorID)
+		if point.Period == period {
+			annual[k] = point.Value
+		} else {
+			monthly[k] += point.Value
+		}
+	}
+
+	for k, value := range monthly {
+		if _, exists := annual[k]; !exists {
+			annual[k] = value
+		}
+	}
+	return annual
+}
+
+func (e *engine) exists(actorType string, actorID int) bool {
+	var exists bool
+	switch actorType {
+	case "company":
+		_, exists = e.companies[actorID]
+	case "city":
+		_, exists = e.cities[actorID]
+	case "state":
+		_, exists = e.states[actorID]
+	case "region":
+		_, exists = e.regions[actorID]
+	case "country":
+		_, exists = e.countries[actorID]
+	}
+	return exists
+}
+
+// locate finds the city, state, region and country an asset is in
+func (e *engine) locate(asset db.Asset) location {
+	var loc location
+	company := e.companies[asset.CompanyID]
+
+	for _, id := range e.stateIDs {
+		state := e.states[id]
+		if loc.state == 0 && state.Name == asset.State && (company.Country == "" || state.Country == company.Country) {
+			loc.state = id
+		}
+	}
+	for _, id := range e.regionIDs {
+		region := e.regions[id]
+		if loc.region == 0 && region.Name == asset.State && (company.Country == "" || region.Country == company.Country) {
+			loc.region = id
+		}
+	}
+
+	countryName := company.Country
+	if loc.state != 0 {
+		countryName = e.states[loc.state].Country
+	} else if loc.region != 0 {
+		countryName = e.regions[loc.region].Country
+	}
+
+	for _, id := range e.cityIDs {
+		city := e.cities[id]
+		if loc.city == 0 && city.Name == asset.Location && (countryName == "" || city.Country == countryName) {
+			loc.city = id
+			countryName = city.Country
+		}
+	}
+
+	// the city tells us the state or region if the asset doesn't
+	if loc.city != 0 && loc.state == 0 && loc.region == 0 {
+		city := e.cities[loc.city]
+		loc.state = e.parentState(city)
+		loc.region = e.parentRegion(city)
+	}
+
+	for _, id := range e.countryIDs {
+		if loc.country == 0 && e.countries[id].Name == countryName {
+			loc.country = id
+		}
+	}
+	return loc
+}
+
+// within checks whether an asset is within the area of a geographic actor
+func (e *engine) within(assetID int, actorType string, actorID int) bool {
+	loc := e.locations[assetID]
+	switch actorType {
+	case "city":
+		return loc.city == actorID
+	case "state":
+		return loc.state == actorID
+	case "region":
+		return loc.region == actorID
+	case "country":
+		return loc.country == actorID
+	}
+	return false
+}
+
+// cityIn checks whether a city is within the area of a geographic actor
+func (e *engine) cityIn(city db.City, actorType string, actorID int) bool {
+	switch actorType {
+	case "state":
+		state := e.states[actorID]
+		return city.Region == state.Name && city.Country == state.Country
+	case "region":
+		region := e.regions[actorID]
+		return city.Region == region.Name && city.Country == region.Country
+	case "country":
+		return city.Country == e.countries[actorID].Name
+	}
+	return false
+}
+
+func (e *engine) name(actorType string, actorID int) string {
+	switch actorType {
+	case "company":
+		return e.companies[actorID].Name
+	case "city":
+		return e.cities[actorID].Name
+	case "state":
+		return e.states[actorID].Name
+	case "region":
+		return e.regions[actorID].Name
+	case "country":
+		return e.countries[actorID].Name
+	}
+	return ""
+}
+
+// aggregate computes the result for an actor, reusing results that have
+// already been computed for its parts
+func (e *engine) aggregate(actorType string, actorID int) Result {
+	k := key(actorType, actorID)
+	if result, exists := e.results[k]; exists {
+		return result
+	}
+
+	var result Result
+	result.ActorType = actorType
+	result.ActorID = actorID
+	result.Name = e.name(actorType, actorID)
+	result.Metric = e.metric
+	result.Year = e.year
+	result.Reported, result.HasReported = e.values[k]
+
+	result.Parts = e.parts(actorType, actorID)
+	for _, part := range result.Parts {
+		result.SumOfParts += part.Value
+	}
+
+	result.DoubleCounted = e.doubleCounted(result.Parts)
+	for _, dc := range result.DoubleCounted {
+		result.Overlap += dc.Value * float64(len(dc.CountedBy)-1)
+	}
+
+	result.Deduplicated = result.SumOfParts - result.Overlap
+	result.Total = result.Deduplicated
+	if result.HasReported {
+		result.Unattributed = result.Reported - result.Deduplicated
+		result.Total = result.Reported
+	}
+
+	e.results[k] = result
+	return result
+}
+
+// parts returns the parts an actor's emissions are aggregated from
+func (e *engine) parts(actorType string, actorID int) []Part {
+	var parts []Part
+
+	switch actorType {
+	case "company":
+		for _, asset := range e.assets {
+			if asset.CompanyID == actorID {
+				parts = append(parts, e.assetPart(asset))
+			}
+		}
+
+	case "city":
+		for _, asset := range e.assets {
+			if e.within(asset.Index, actorType, actorID) {
+				parts = append(parts, e.assetPart(asset))
+			}
+		}
+
+	case "state", "region", "country":
+		if actorType == "country" {
+			country := e.countries[actorID]
+			for _, id := range e.stateIDs {
+				if e.states[id].Country == country.Name {
+					parts = append(parts, e.areaPart("state", id))
+				}
+			}
+			for _, id := range e.regionIDs {
+				if e.regions[id].Country == country.Name {
+					parts = append(parts, e.areaPart("region", id))
+				}
+			}
+		}
+
+		for _, id := range e.cityIDs {
+			city := e.cities[id]
+			if !e.cityIn(city, actorType, actorID) {
+				continue
+			}
+			// cities in a state or region of the country are already
+			// included in the state's or region's total
+			if actorType == "country" && (e.parentState(city) != 0 || e.parentRegion(city) != 0) {
+				continue
+			}
+			parts = append(parts, e.areaPart("city", id))
+		}
+
+		for _, id := range e.companyIDs {
+			part, ok := e.companyShare(id, actorType, actorID)
+			if ok {
+				parts = append(parts, part)
+			}
+		}
+	}
+
+	return parts
+}
+
+func (e *engine) assetPart(asset db.Asset) Part {
+	var part Part
+	part.ActorType = "asset"
+	part.ActorID = asset.Index
+	part.Name = asset.Name
+	part.Value, part.Reported = e.values[key("asset", asset.Index)]
+	if part.Reported {
+		part.Assets = []int{asset.Index}
+	}
+	return part
+}
+
+// areaPart returns a geographic actor as a part. Its total is assumed to
+// include the emissions of all the assets within its area.
+func (e *engine) areaPart(actorType string, actorID int) Part {
+	result := e.aggregate(actorType, actorID)
+
+	var part Part
+	part.ActorType = actorType
+	part.ActorID = actorID
+	part.Name = result.Name
+	part.Value = result.Total
+	part.Reported = result.HasReported
+	for _, asset := range e.assets {
+		if _, ok := e.values[key("asset", asset.Index)]; ok && e.within(asset.Index, actorType, actorID) {
+			part.Assets = append(part.Assets, asset.Index)
+		}
+	}
+	return part
+}
+
+/*
+	companyShare returns the part of a company's emissions that lie within
+	the area of a geographic actor, which is the sum of the company's assets
+	in the area. If the company hasn't registered any assets, its whole total
+	is attributed to the areas it's linked to through its nested scopes.
+*/
+func (e *engine) companyShare(companyID int, actorType string, actorID int) (Part, bool) {
+	var part Part
+	part.ActorType = "company"
+	part.ActorID = companyID
+	part.Name = e.companies[companyID].Name
+
+	hasAssets := false
+	for _, asset := range e.assets {
+		if asset.CompanyID != companyID {
+			continue
+		}
+		hasAssets = true
+
+		value, ok := e.values[key("asset", asset.Index)]
+		if ok && e.within(asset.Index, actorType, actorID) {
+			part.Value += value
+			part.Assets = append(part.Assets, asset.Index)
+			part.Reported = true
+		}
+	}
+
+	if hasAssets {
+		return part, len(part.Assets) != 0
+	}
+
+	company := e.companies[companyID]
+	var linked []int
+	switch actorType {
+	case "state":
+		linked = company.States
+	case "region":
+		linked = company.Regions
+	case "country":
+		linked = company.Countries
+	}
+
+	for _, id := range linked {
+		if id == actorID {
+			result := e.aggregate("company", companyID)
+			part.Value = result.Total
+			part.Reported = result.HasReported
+			return part, true
+		}
+	}
+	return part, false
+}
+
+// parentState returns the ID of the state a city is in, 0 if unknown
+func (e *engine) parentState(city db.City) int {
+	for _, id := range e.stateIDs {
+		if e.cityIn(city, "state", id) {
+			return id
+		}
+	}
+	return 0
+}
+
+// parentRegion returns the ID of the region a city is in, 0 if unknown
+func (e *engine) parentRegion(city db.City) int {
+	for _, id := range e.regionIDs {
+		if e.cityIn(city, "region", id) {
+			return id
+		}
+	}
+	return 0
+}
+
+// doubleCounted returns the assets that are included in more than one part
+func (e *engine) doubleCounted(parts []Part) []DoubleCount {
+	countedBy := make(map[int][]string)
+	for _, part := range parts {
+		for _, assetID := range part.Assets {
+			countedBy[assetID] = append(countedBy[assetID], key(part.ActorType, part.ActorID))
+		}
+	}
+
+	var arr []DoubleCount
+	for _, asset := range e.assets {
+		if len(countedBy[asset.Index]) < 2 {
+			continue
+		}
+
+		var dc DoubleCount
+		dc.AssetID = asset.Index
+		dc.Name = asset.Name
+		dc.Value = e.values[key("asset", asset.Index)]
+		dc.CountedBy = countedBy[asset.Index]
+		arr = append(arr, dc)
+	}
+	return arr
+}
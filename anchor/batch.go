@@ -0,0 +1,251 @@
+package anchor
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"time"
+
+	edb "github.com/Varunram/essentials/database"
+	"github.com/Varunram/essentials/utils"
+	globals "github.com/YaleOpenLab/openclimate/globals"
+	"github.com/pkg/errors"
+)
+
+// Buckets the leaves and batches are stored in
+var (
+	LeafBucket  = []byte("AnchorLeaves")
+	BatchBucket = []byte("AnchorBatches")
+)
+
+// Statuses of a batch
+const (
+	BatchPending  = "pending"  // the root is being anchored
+	BatchAnchored = "anchored" // the root is stored on the ledger
+	BatchFailed   = "failed"   // anchoring the root failed, it is retried with the next batch
+)
+
+// Leaf is a CID waiting for the next batch, or anchored in a batch
+type Leaf struct {
+	Index      int
+	Hash       string // the CID
+	ReportType string
+	ActorType  string
+	ActorID    int
+	DataVal    float64 // the "true value" the oracle computed for the data
+
+	BatchID int         // 0 while the CID waits for the next batch
+	Proof   []ProofStep // inclusion proof of the CID in the batch's tree
+
+	Created string
+}
+
+// Batch is a Merkle tree of CIDs whose root is anchored on chain
+type Batch struct {
+	Index     int
+	Root      string // hex encoded with 0x prefix
+	Timestamp int64  // the timestamp the root is stored under on chain
+	Leaves    int    // number of CIDs in the tree
+	Status    string
+	Receipt   Receipt // where the root is anchored
+	Error     string  // why anchoring the root last failed
+
+	Created  string
+	Anchored string
+}
+
+// Enqueue adds a CID to the next batch
+func Enqueue(cid string, reportType string, actorType string, actorID int, dataVal float64) (Leaf, error) {
+	var x Leaf
+	x.Hash = cid
+	x.ReportType = reportType
+	x.ActorType = actorType
+	x.ActorID = actorID
+	x.DataVal = dataVal
+	x.Created = utils.Timestamp()
+	err := save(LeafBucket, &x)
+	return x, err
+}
+
+// AnchorPending anchors the CIDs enqueued since the last batch: it builds
+// their tree, stores the inclusion proof of each CID and commits the root.
+// Batches that failed to be anchored earlier are retried first. It returns
+// the new batch, which is empty if no CIDs were enqueued.
+func AnchorPending(a Anchor) (Batch, error) {
+	var b Batch
+	batches, err := RetrieveAllBatches()
+	if err != nil {
+		return b, err
+	}
+
+	// errors anchoring earlier batches don't hold up the new one
+	var retryErr error
+	var last int64
+	for _, x := range batches {
+		if x.Timestamp > last {
+			last = x.Timestamp
+		}
+		if x.Status == BatchAnchored {
+			continue
+		}
+		err = x.anchor(a)
+		if err != nil && retryErr == nil {
+			retryErr = err
+		}
+	}
+
+	leaves, err := RetrievePendingLeaves()
+	if err != nil {
+		return b, err
+	}
+	if len(leaves) == 0 {
+		return b, retryErr
+	}
+
+	hashes := make([][32]byte, len(leaves))
+	for i, x := range leaves {
+		hashes[i] = LeafHash(x.Hash)
+	}
+	root := MerkleRoot(hashes)
+
+	b.Root = "0x" + hex.EncodeToString(root[:])
+	b.Leaves = len(leaves)
+	b.Status = BatchPending
+	b.Created = utils.Timestamp()
+
+	// ledgers store a single root per timestamp
+	b.Timestamp = time.Now().Unix()
+	if b.Timestamp <= last {
+		b.Timestamp = last + 1
+	}
+
+	err = save(BatchBucket, &b)
+	if err != nil {
+		return b, err
+	}
+
+	for i, x := range leaves {
+		x.BatchID = b.Index
+		x.Proof = MerkleProof(hashes, i)
+		err = save(LeafBucket, &x)
+		if err != nil {
+			return b, err
+		}
+	}
+
+	err = b.anchor(a)
+	if err != nil {
+		return b, err
+	}
+	return b, retryErr
+}
+
+// anchor commits the root of the batch and records the outcome
+func (b *Batch) anchor(a Anchor) error {
+	receipt, err := a.Commit(b.Timestamp, b.Root)
+	if err != nil {
+		b.Status = BatchFailed
+		b.Error = err.Error()
+	} else {
+		b.Status = BatchAnchored
+		b.Receipt = receipt
+		b.Error = ""
+		b.Anchored = utils.Timestamp()
+	}
+
+	saveErr := save(BatchBucket, b)
+	if err != nil {
+		return errors.Wrap(err, "could not anchor batch")
+	}
+	return saveErr
+}
+
+// RetrieveBatch retrieves a batch from the database
+func RetrieveBatch(key int) (Batch, error) {
+	var b Batch
+	batchBytes, err := edb.Retrieve(globals.DbPath, BatchBucket, key)
+	if err != nil {
+		return b, errors.Wrap(err, "error while retrieving key from bucket")
+	}
+	err = json.Unmarshal(batchBytes, &b)
+	return b, err
+}
+
+// RetrieveAllBatches gets a list of all batches, oldest first
+func RetrieveAllBatches() ([]Batch, error) {
+	var arr []Batch
+	keys, err := edb.RetrieveAllKeys(globals.DbPath, BatchBucket)
+	if err != nil {
+		return arr, errors.Wrap(err, "error while retrieving all batches")
+	}
+
+	for _, val := range keys {
+		var b Batch
+		err = json.Unmarshal(val, &b)
+		if err != nil {
+			return arr, errors.Wrap(err, "could not unmarshal json")
+		}
+		arr = append(arr, b)
+	}
+
+	sort.Slice(arr, func(i, j int) bool {
+		return arr[i].Index < arr[j].Index
+	})
+	return arr, nil
+}
+
+// RetrieveAllLeaves gets a list of all leaves, oldest first
+func RetrieveAllLeaves() ([]Leaf, error) {
+	var arr []Leaf
+	keys, err := edb.RetrieveAllKeys(globals.DbPath, LeafBucket)
+	if err != nil {
+		return arr, errors.Wrap(err, "error while retrieving all leaves")
+	}
+
+	for _, val := range keys {
+		var x Leaf
+		err = json.Unmarshal(val, &x)
+		if err != nil {
+			return arr, errors.Wrap(err, "could not unmarshal json")
+		}
+		arr = append(arr, x)
+	}
+
+	sort.Slice(arr, func(i, j int) bool {
+		return arr[i].Index < arr[j].Index
+	})
+	return arr, nil
+}
+
+// RetrievePendingLeaves returns the CIDs waiting for the next batch
+func RetrievePendingLeaves() ([]Leaf, error) {
+	var arr []Leaf
+	all, err := RetrieveAllLeaves()
+	if err != nil {
+		return arr, err
+	}
+
+	for _, x := range all {
+		if x.BatchID == 0 {
+			arr = append(arr, x)
+		}
+	}
+	return arr, nil
+}
+
+// RetrieveLeaves returns the leaves of a CID. Data committed more than once
+// has a leaf for every commit.
+func RetrieveLeaves(cid string) ([]Leaf, error) {
+	var arr []Leaf
+	all, err := RetrieveAllLeaves()
+	if err != nil {
+		return arr, err
+	}
+
+	for _, x := range all {
+		if x.Hash == cid {
+			arr = append(arr, x)
+		}
+	}
+	return arr, nil
+}
@@ -0,0 +1,74 @@
+package anchor
+
+import (
+	"encoding/json"
+
+	edb "github.com/Varunram/essentials/database"
+	"github.com/Varunram/essentials/utils"
+	globals "github.com/YaleOpenLab/openclimate/globals"
+	"github.com/boltdb/bolt"
+	"github.com/pkg/errors"
+)
+
+// Leaves and batches are stored in the platform's database. The database
+// package creates their buckets, but imports this package, so they are
+// saved here (see save in ipfs/db.go).
+
+// record is an item stored in one of the buckets of this package
+type record interface {
+	SetID(id int)
+	GetID() int
+}
+
+// save stores a record, assigning it a new ID if it isn't stored yet
+func save(bucketName []byte, x record) error {
+	db, err := edb.OpenDB(globals.DbPath)
+	if err != nil {
+		return errors.Wrap(err, "could not open database")
+	}
+	defer db.Close()
+
+	return db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketName)
+		if b == nil {
+			return errors.New("Bucket missing")
+		}
+
+		keyBytes, err := utils.ToByte(x.GetID())
+		if err != nil {
+			return err
+		}
+
+		if b.Get(keyBytes) == nil {
+			id, _ := b.NextSequence()
+			x.SetID(int(id))
+		}
+
+		encoded, err := json.Marshal(x)
+		if err != nil {
+			return errors.Wrap(err, "error while marshaling json struct")
+		}
+
+		keyBytes, err = utils.ToByte(x.GetID())
+		if err != nil {
+			return err
+		}
+		return b.Put(keyBytes, encoded)
+	})
+}
+
+func (x *Leaf) SetID(id int) {
+	x.Index = id
+}
+
+func (x *Leaf) GetID() int {
+	return x.Index
+}
+
+func (x *Batch) SetID(id int) {
+	x.Index = id
+}
+
+func (x *Batch) GetID() int {
+	return x.Index
+}
@@ -0,0 +1,52 @@
+package anchor
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/YaleOpenLab/openclimate/blockchain"
+	globals "github.com/YaleOpenLab/openclimate/globals"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+)
+
+// EthereumAnchor stores roots in the root storage contract on Ethereum,
+// keyed by their timestamp. The zero value uses the network, contract and
+// signer configured in globals. Storage and Signer can be set to anchor
+// through another backend, such as go-ethereum's simulated backend.
+type EthereumAnchor struct {
+	Storage *blockchain.RootStorage
+	Signer  *bind.TransactOpts
+}
+
+// Commit stores a root in the contract and waits for the transaction to be
+// confirmed
+func (a EthereumAnchor) Commit(timestamp int64, root string) (Receipt, error) {
+	r := Receipt{Ledger: LedgerEthereum, Timestamp: timestamp}
+
+	var txHash string
+	var err error
+	if a.Storage == nil {
+		txHash, err = blockchain.CommitToChain(big.NewInt(timestamp), root)
+	} else {
+		ctx, cancel := context.WithTimeout(context.Background(), globals.EthTxTimeout)
+		defer cancel()
+		txHash, err = a.Storage.CommitRoot(ctx, a.Signer, big.NewInt(timestamp), root, globals.EthConfirmations)
+	}
+	if err != nil {
+		return r, err
+	}
+
+	// the hash is empty if an earlier attempt stored the root already
+	if txHash != "" {
+		r.TxHashes = []string{txHash}
+	}
+	return r, nil
+}
+
+// Root reads the root stored in the contract under the receipt's timestamp
+func (a EthereumAnchor) Root(r Receipt) (string, error) {
+	if a.Storage == nil {
+		return blockchain.RetrieveRoot(big.NewInt(r.Timestamp))
+	}
+	return a.Storage.ReadRoot(context.Background(), big.NewInt(r.Timestamp))
+}
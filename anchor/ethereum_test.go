@@ -0,0 +1,108 @@
+package anchor
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/YaleOpenLab/openclimate/blockchain"
+	storage "github.com/YaleOpenLab/openclimate/blockchain/contracts/blockchain_storage"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// newTestEthereumAnchor deploys the root storage contract on a simulated
+// backend that mines a block every 100ms until stop is closed
+func newTestEthereumAnchor(t *testing.T, stop chan bool) EthereumAnchor {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := crypto.PubkeyToAddress(key.PublicKey)
+	balance := new(big.Int).Exp(big.NewInt(10), big.NewInt(20), nil)
+	sim := backends.NewSimulatedBackend(core.GenesisAlloc{address: {Balance: balance}}, 8000000)
+
+	signer, err := blockchain.KeySigner(hex.EncodeToString(crypto.FromECDSA(key)), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := ioutil.ReadFile("../blockchain/contracts/blockchain_storage/ipfs_hash_storage.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var bin struct {
+		Object string
+	}
+	err = json.Unmarshal(raw, &bin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := abi.JSON(strings.NewReader(storage.IpfsRootABI))
+	if err != nil {
+		t.Fatal(err)
+	}
+	contract, _, _, err := bind.DeployContract(signer, parsed, common.FromHex(bin.Object), sim)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sim.Commit()
+
+	s, err := blockchain.NewRootStorage(contract, sim)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			case <-time.After(100 * time.Millisecond):
+				sim.Commit()
+			}
+		}
+	}()
+	return EthereumAnchor{Storage: s, Signer: signer}
+}
+
+func TestEthereumAnchor(t *testing.T) {
+	stop := make(chan bool)
+	defer close(stop)
+	a := newTestEthereumAnchor(t, stop)
+
+	root := "0x" + strings.Repeat("ab", 32)
+	r, err := a.Commit(1565752648, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Ledger != LedgerEthereum || r.Timestamp != 1565752648 || len(r.TxHashes) != 1 {
+		t.Errorf("Commit returned receipt %+v", r)
+	}
+
+	stored, err := a.Root(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored != root {
+		t.Errorf("Root = %s, want %s", stored, root)
+	}
+
+	// an earlier attempt that stored the root is not an error
+	again, err := a.Commit(1565752648, root)
+	if err != nil || len(again.TxHashes) != 0 {
+		t.Errorf("committing the same root again = %+v, %v", again, err)
+	}
+
+	if _, err = a.Root(Receipt{Ledger: LedgerEthereum, Timestamp: 1}); err == nil {
+		t.Error("Root of a timestamp without a root succeeded")
+	}
+}
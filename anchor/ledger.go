@@ -0,0 +1,48 @@
+package anchor
+
+import (
+	globals "github.com/YaleOpenLab/openclimate/globals"
+	"github.com/pkg/errors"
+)
+
+// Anchor stores the roots of batches on a ledger and reads them back. The
+// platform is meant to reconcile records kept on several ledgers, so a
+// deployment can anchor on whichever ledger it trusts (see
+// globals.AnchorLedger), and every batch records the ledger it was anchored
+// on.
+type Anchor interface {
+	// Commit stores a root under a timestamp and returns a receipt of where
+	// it is stored
+	Commit(timestamp int64, root string) (Receipt, error)
+	// Root reads back the root a receipt is for
+	Root(r Receipt) (string, error)
+}
+
+// Receipt records where a root is anchored, so that it can be read back
+// from the ledger
+type Receipt struct {
+	Ledger    string
+	Timestamp int64    // the timestamp the root is stored under
+	TxHashes  []string // the transactions that store the root
+}
+
+// Ledgers that can be selected with globals.AnchorLedger
+const (
+	LedgerEthereum = "ethereum"
+	LedgerStellar  = "stellar"
+	LedgerLocal    = "local"
+)
+
+// NewAnchor returns the anchor of a ledger, configured from globals
+func NewAnchor(ledger string) (Anchor, error) {
+	switch ledger {
+	case LedgerEthereum:
+		return EthereumAnchor{}, nil
+	case LedgerStellar:
+		return NewStellarAnchor(globals.StellarHorizonUrl, globals.StellarSeed, globals.StellarAccount), nil
+	case LedgerLocal:
+		return NewLocalAnchor(globals.AnchorLogPath), nil
+	default:
+		return nil, errors.New("unknown ledger " + ledger)
+	}
+}
@@ -0,0 +1,87 @@
+package anchor
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/Varunram/essentials/utils"
+	"github.com/pkg/errors"
+)
+
+// LocalAnchor appends roots to a log file instead of storing them on a
+// ledger. It is meant for development and for deployments that don't
+// anchor on chain yet: a root in the log is only as trustworthy as the
+// platform itself.
+type LocalAnchor struct {
+	path string
+}
+
+func NewLocalAnchor(path string) *LocalAnchor {
+	return &LocalAnchor{path: path}
+}
+
+// localEntry is a line of the log
+type localEntry struct {
+	Timestamp int64
+	Root      string
+	Logged    string
+}
+
+// Commit appends a root to the log
+func (a *LocalAnchor) Commit(timestamp int64, root string) (Receipt, error) {
+	r := Receipt{Ledger: LedgerLocal, Timestamp: timestamp}
+
+	err := os.MkdirAll(filepath.Dir(a.path), os.ModePerm)
+	if err != nil {
+		return r, errors.Wrap(err, "could not create anchor log directory")
+	}
+
+	f, err := os.OpenFile(a.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return r, errors.Wrap(err, "could not open anchor log")
+	}
+	defer f.Close()
+
+	line, err := json.Marshal(localEntry{Timestamp: timestamp, Root: root, Logged: utils.Timestamp()})
+	if err != nil {
+		return r, err
+	}
+	_, err = f.Write(append(line, '\n'))
+	if err != nil {
+		return r, errors.Wrap(err, "could not write to anchor log")
+	}
+	return r, f.Sync()
+}
+
+// Root reads the root logged under the receipt's timestamp
+func (a *LocalAnchor) Root(r Receipt) (string, error) {
+	f, err := os.Open(a.path)
+	if err != nil {
+		return "", errors.Wrap(err, "could not open anchor log")
+	}
+	defer f.Close()
+
+	var root string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var x localEntry
+		err = json.Unmarshal(scanner.Bytes(), &x)
+		if err != nil {
+			return "", errors.Wrap(err, "anchor log is corrupted")
+		}
+		if x.Timestamp == r.Timestamp {
+			root = x.Root
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return "", errors.Wrap(err, "could not read anchor log")
+	}
+
+	if root == "" {
+		return "", errors.New("no root logged under timestamp " + strconv.FormatInt(r.Timestamp, 10))
+	}
+	return root, nil
+}
@@ -0,0 +1,52 @@
+package anchor
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLocalAnchor(t *testing.T) {
+	dir, err := ioutil.TempDir("", "openclimate-anchor-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	a := NewLocalAnchor(filepath.Join(dir, "log", "anchors.log"))
+	_, err = a.Root(Receipt{Ledger: LedgerLocal, Timestamp: 1})
+	if err == nil {
+		t.Error("Root succeeded before the log was created")
+	}
+
+	roots := map[int64]string{
+		1: "0x" + strings.Repeat("ab", 32),
+		2: "0x" + strings.Repeat("cd", 32),
+	}
+	for timestamp, root := range roots {
+		r, err := a.Commit(timestamp, root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Ledger != LedgerLocal || r.Timestamp != timestamp {
+			t.Errorf("Commit returned receipt %+v", r)
+		}
+	}
+
+	for timestamp, root := range roots {
+		stored, err := a.Root(Receipt{Ledger: LedgerLocal, Timestamp: timestamp})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stored != root {
+			t.Errorf("Root of timestamp %d = %s, want %s", timestamp, stored, root)
+		}
+	}
+
+	_, err = a.Root(Receipt{Ledger: LedgerLocal, Timestamp: 3})
+	if err == nil || !strings.Contains(err.Error(), "no root logged") {
+		t.Errorf("Root of a timestamp without a root returned %v", err)
+	}
+}
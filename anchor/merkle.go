@@ -0,0 +1,122 @@
+package anchor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+/*
+
+	Report CIDs are anchored on chain in batches: the CIDs collected over a
+	window are the leaves of a Merkle tree, and only its root is stored on
+	chain. The path from a leaf to the root (its inclusion proof) shows that
+	the CID is part of the anchored batch.
+
+	Leaves and internal nodes are hashed with different prefixes, so that an
+	internal node can't be passed off as a leaf:
+
+	leaf = sha256(0x00 | CID)
+	node = sha256(0x01 | left | right)
+
+	If a level has an odd number of nodes, the last node is moved up to the
+	next level as it is, rather than being paired with a copy of itself.
+
+*/
+
+// ProofStep is a node of an inclusion proof: the sibling of the node on the
+// path from the leaf to the root at one level of the tree
+type ProofStep struct {
+	Hash string // hex encoded
+	Left bool   // whether the sibling is the left node of the pair
+}
+
+// LeafHash returns the hash of the leaf of a CID
+func LeafHash(cid string) [32]byte {
+	return sha256.Sum256(append([]byte{0x00}, cid...))
+}
+
+func nodeHash(left [32]byte, right [32]byte) [32]byte {
+	b := make([]byte, 0, 65)
+	b = append(b, 0x01)
+	b = append(b, left[:]...)
+	b = append(b, right[:]...)
+	return sha256.Sum256(b)
+}
+
+// nextLevel pairs up the nodes of a level of the tree
+func nextLevel(level [][32]byte) [][32]byte {
+	next := make([][32]byte, 0, (len(level)+1)/2)
+	for i := 0; i < len(level); i += 2 {
+		if i+1 == len(level) {
+			next = append(next, level[i])
+			break
+		}
+		next = append(next, nodeHash(level[i], level[i+1]))
+	}
+	return next
+}
+
+// MerkleRoot returns the root of the tree of the leaves, which must not be
+// empty
+func MerkleRoot(leaves [][32]byte) [32]byte {
+	level := leaves
+	for len(level) > 1 {
+		level = nextLevel(level)
+	}
+	return level[0]
+}
+
+// MerkleProof returns the inclusion proof of the i-th leaf
+func MerkleProof(leaves [][32]byte, i int) []ProofStep {
+	var proof []ProofStep
+	level := leaves
+	for len(level) > 1 {
+		sibling := i ^ 1
+		if sibling < len(level) {
+			proof = append(proof, ProofStep{
+				Hash: hex.EncodeToString(level[sibling][:]),
+				Left: sibling < i,
+			})
+		}
+		level = nextLevel(level)
+		i /= 2
+	}
+	return proof
+}
+
+// ProofRoot recomputes the root of a tree from a CID and its inclusion proof,
+// hex encoded with 0x prefix
+func ProofRoot(cid string, proof []ProofStep) (string, error) {
+	hash := LeafHash(cid)
+	for _, step := range proof {
+		b, err := hex.DecodeString(step.Hash)
+		if err != nil || len(b) != 32 {
+			return "", errors.New("invalid proof hash " + step.Hash)
+		}
+		var sibling [32]byte
+		copy(sibling[:], b)
+
+		if step.Left {
+			hash = nodeHash(sibling, hash)
+		} else {
+			hash = nodeHash(hash, sibling)
+		}
+	}
+	return "0x" + hex.EncodeToString(hash[:]), nil
+}
+
+// SameRoot compares two hex encoded roots, with or without 0x prefix
+func SameRoot(a string, b string) bool {
+	a = strings.TrimPrefix(strings.ToLower(a), "0x")
+	b = strings.TrimPrefix(strings.ToLower(b), "0x")
+	return a != "" && a == b
+}
+
+// VerifyProof checks that a CID is a leaf of the tree with the given root
+func VerifyProof(cid string, proof []ProofStep, root string) bool {
+	computed, err := ProofRoot(cid, proof)
+	return err == nil && SameRoot(computed, root)
+}
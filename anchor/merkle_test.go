@@ -0,0 +1,79 @@
+package anchor
+
+import (
+	"encoding/hex"
+	"strconv"
+	"testing"
+)
+
+func testLeaves(n int) ([]string, [][32]byte) {
+	cids := make([]string, n)
+	hashes := make([][32]byte, n)
+	for i := range cids {
+		cids[i] = "Qm" + strconv.Itoa(i)
+		hashes[i] = LeafHash(cids[i])
+	}
+	return cids, hashes
+}
+
+func hexRoot(root [32]byte) string {
+	return "0x" + hex.EncodeToString(root[:])
+}
+
+func TestMerkleProof(t *testing.T) {
+	for _, n := range []int{1, 2, 3, 5, 8} {
+		cids, hashes := testLeaves(n)
+		root := hexRoot(MerkleRoot(hashes))
+
+		for i, cid := range cids {
+			proof := MerkleProof(hashes, i)
+			if !VerifyProof(cid, proof, root) {
+				t.Errorf("%d leaves: proof of leaf %d doesn't verify", n, i)
+				continue
+			}
+
+			if VerifyProof(cid+"x", proof, root) {
+				t.Errorf("%d leaves: proof of leaf %d verifies another CID", n, i)
+			}
+
+			for j := range proof {
+				tampered := append([]ProofStep{}, proof...)
+				b, _ := hex.DecodeString(tampered[j].Hash)
+				b[0] ^= 0xff
+				tampered[j].Hash = hex.EncodeToString(b)
+				if VerifyProof(cid, tampered, root) {
+					t.Errorf("%d leaves: proof of leaf %d verifies with step %d tampered", n, i, j)
+				}
+
+				swapped := append([]ProofStep{}, proof...)
+				swapped[j].Left = !swapped[j].Left
+				if VerifyProof(cid, swapped, root) {
+					t.Errorf("%d leaves: proof of leaf %d verifies with side of step %d swapped", n, i, j)
+				}
+			}
+		}
+	}
+}
+
+// An odd last node is moved up as it is, not paired with a copy of itself
+func TestMerkleRootOdd(t *testing.T) {
+	_, hashes := testLeaves(3)
+	want := nodeHash(nodeHash(hashes[0], hashes[1]), hashes[2])
+	if root := MerkleRoot(hashes); root != want {
+		t.Errorf("root of 3 leaves = %x, want %x", root, want)
+	}
+	if proof := MerkleProof(hashes, 2); len(proof) != 1 || !proof[0].Left {
+		t.Errorf("proof of the odd leaf = %+v, want its left sibling only", proof)
+	}
+}
+
+func TestProofRootInvalid(t *testing.T) {
+	for _, hash := range []string{"zz", "abcd"} {
+		if _, err := ProofRoot("Qm0", []ProofStep{{Hash: hash}}); err == nil {
+			t.Errorf("ProofRoot accepted proof hash %q", hash)
+		}
+	}
+	if SameRoot("", "") || !SameRoot("0xAB", "ab") {
+		t.Error("SameRoot compared roots wrongly")
+	}
+}
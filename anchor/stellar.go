@@ -0,0 +1,167 @@
+package anchor
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	xlm "github.com/Varunram/essentials/crypto/xlm"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/pkg/errors"
+)
+
+/*
+
+	Stellar has no contract to store roots in, so a root is written to the
+	memos of payments the anchoring account makes. A text memo holds at most
+	28 bytes, so the root is base64 encoded and split over two payments, each
+	memo numbered so that the root can be reassembled in order:
+
+	memo = "1/2 " | first 24 characters of the root
+	memo = "2/2 " | remaining 19 characters
+
+	The receipt holds the hashes of the payments, which are read back from a
+	Horizon server.
+
+*/
+
+const stellarMemoLen = 28
+
+// StellarAnchor stores roots in the memos of payments made from the account
+// of a seed to another account (or to itself)
+type StellarAnchor struct {
+	// Source is the account roots have to be paid from to be read back. Any
+	// account is accepted if it is empty.
+	Source string
+
+	horizon     string
+	seed        string
+	destination string
+	client      *http.Client
+}
+
+func NewStellarAnchor(horizon string, seed string, destination string) *StellarAnchor {
+	return &StellarAnchor{
+		horizon:     strings.TrimSuffix(horizon, "/"),
+		seed:        seed,
+		destination: destination,
+		client:      &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// stellarMemos splits a root over the memos of the payments that store it
+func stellarMemos(root string) ([]string, error) {
+	b, err := hexutil.Decode(root)
+	if err != nil || len(b) != 32 {
+		return nil, errors.New("invalid root hash " + root)
+	}
+	encoded := base64.RawStdEncoding.EncodeToString(b)
+
+	var parts []string
+	chunk := stellarMemoLen - len("1/2 ")
+	for len(encoded) > 0 {
+		n := chunk
+		if n > len(encoded) {
+			n = len(encoded)
+		}
+		parts = append(parts, encoded[:n])
+		encoded = encoded[n:]
+	}
+
+	memos := make([]string, len(parts))
+	for i, part := range parts {
+		memos[i] = fmt.Sprintf("%d/%d %s", i+1, len(parts), part)
+	}
+	return memos, nil
+}
+
+// Commit makes a payment for every part of the root
+func (a *StellarAnchor) Commit(timestamp int64, root string) (Receipt, error) {
+	r := Receipt{Ledger: LedgerStellar, Timestamp: timestamp}
+	if a.seed == "" || a.destination == "" {
+		return r, errors.New("no stellar account configured")
+	}
+
+	memos, err := stellarMemos(root)
+	if err != nil {
+		return r, err
+	}
+
+	for _, memo := range memos {
+		_, txHash, err := xlm.SendXLM(a.destination, "1", a.seed, memo)
+		if err != nil {
+			return r, errors.Wrap(err, "could not send stellar payment")
+		}
+		r.TxHashes = append(r.TxHashes, txHash)
+	}
+	return r, nil
+}
+
+// stellarTx is the part of a transaction returned by Horizon the root is
+// read from
+type stellarTx struct {
+	Successful    bool   `json:"successful"`
+	SourceAccount string `json:"source_account"`
+	MemoType      string `json:"memo_type"`
+	Memo          string `json:"memo"`
+}
+
+func (a *StellarAnchor) transaction(hash string) (stellarTx, error) {
+	var tx stellarTx
+	resp, err := a.client.Get(a.horizon + "/transactions/" + hash)
+	if err != nil {
+		return tx, errors.Wrap(err, "could not reach horizon")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return tx, errors.New("horizon responded with " + resp.Status + " for transaction " + hash)
+	}
+	err = json.NewDecoder(resp.Body).Decode(&tx)
+	return tx, err
+}
+
+// Root reads the memos of the receipt's payments and reassembles the root.
+// All payments have to be made from the same account, and from Source if it
+// is set.
+func (a *StellarAnchor) Root(r Receipt) (string, error) {
+	if len(r.TxHashes) == 0 {
+		return "", errors.New("receipt has no transactions")
+	}
+
+	parts := make([]string, len(r.TxHashes))
+	var source string
+	for _, hash := range r.TxHashes {
+		tx, err := a.transaction(hash)
+		if err != nil {
+			return "", err
+		}
+		if !tx.Successful || tx.MemoType != "text" {
+			return "", errors.New("transaction " + hash + " does not hold a root")
+		}
+		if a.Source != "" && tx.SourceAccount != a.Source {
+			return "", errors.New("transaction " + hash + " was made from " + tx.SourceAccount + ", not " + a.Source)
+		}
+		if source != "" && tx.SourceAccount != source {
+			return "", errors.New("transactions were made from different accounts")
+		}
+		source = tx.SourceAccount
+
+		var i, n int
+		var part string
+		_, err = fmt.Sscanf(tx.Memo, "%d/%d %s", &i, &n, &part)
+		if err != nil || n != len(parts) || i < 1 || i > n || parts[i-1] != "" {
+			return "", errors.New("transaction " + hash + " has an invalid memo")
+		}
+		parts[i-1] = part
+	}
+
+	b, err := base64.RawStdEncoding.DecodeString(strings.Join(parts, ""))
+	if err != nil || len(b) != 32 {
+		return "", errors.New("memos do not hold a valid root")
+	}
+	return hexutil.Encode(b), nil
+}
@@ -0,0 +1,97 @@
+package anchor
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestStellarMemos(t *testing.T) {
+	root := "0x" + strings.Repeat("ab", 32)
+	memos, err := stellarMemos(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(memos) != 2 || !strings.HasPrefix(memos[0], "1/2 ") || !strings.HasPrefix(memos[1], "2/2 ") {
+		t.Fatalf("stellarMemos = %q, want two numbered memos", memos)
+	}
+	for _, memo := range memos {
+		if len(memo) > stellarMemoLen {
+			t.Errorf("memo %q is longer than %d bytes", memo, stellarMemoLen)
+		}
+	}
+
+	for _, root := range []string{"", "0x1234", strings.Repeat("ab", 32)} {
+		if _, err := stellarMemos(root); err == nil {
+			t.Errorf("stellarMemos(%q) succeeded", root)
+		}
+	}
+}
+
+// newTestHorizon serves the transactions of the payments holding the memos,
+// hashed "tx1", "tx2", ..., made from source
+func newTestHorizon(memos []string, source string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hash := strings.TrimPrefix(r.URL.Path, "/transactions/")
+		for i, memo := range memos {
+			if hash == "tx"+strconv.Itoa(i+1) {
+				json.NewEncoder(w).Encode(stellarTx{
+					Successful:    true,
+					SourceAccount: source,
+					MemoType:      "text",
+					Memo:          memo,
+				})
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+}
+
+func TestStellarRoot(t *testing.T) {
+	root := "0x" + strings.Repeat("ab", 32)
+	memos, err := stellarMemos(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	horizon := newTestHorizon(memos, "GSOURCE")
+	defer horizon.Close()
+
+	a := NewStellarAnchor(horizon.URL, "", "")
+
+	// the memos are reassembled in order, whatever the order of the receipt
+	for _, hashes := range [][]string{{"tx1", "tx2"}, {"tx2", "tx1"}} {
+		stored, err := a.Root(Receipt{Ledger: LedgerStellar, TxHashes: hashes})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stored != root {
+			t.Errorf("Root of %v = %s, want %s", hashes, stored, root)
+		}
+	}
+
+	invalid := [][]string{
+		nil,                   // no transactions
+		{"tx1"},               // missing part
+		{"tx1", "tx1"},        // repeated part
+		{"tx1", "tx3"},        // unknown transaction
+		{"tx1", "tx2", "tx2"}, // too many parts
+	}
+	for _, hashes := range invalid {
+		if _, err := a.Root(Receipt{Ledger: LedgerStellar, TxHashes: hashes}); err == nil {
+			t.Errorf("Root of %v succeeded", hashes)
+		}
+	}
+
+	a.Source = "GSOURCE"
+	if _, err := a.Root(Receipt{Ledger: LedgerStellar, TxHashes: []string{"tx1", "tx2"}}); err != nil {
+		t.Errorf("Root of payments from the source account failed: %v", err)
+	}
+	a.Source = "GOTHER"
+	if _, err := a.Root(Receipt{Ledger: LedgerStellar, TxHashes: []string{"tx1", "tx2"}}); err == nil {
+		t.Error("Root accepted payments from another account")
+	}
+}
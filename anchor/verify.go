@@ -0,0 +1,119 @@
+package anchor
+
+import (
+	"github.com/pkg/errors"
+)
+
+// Verification is the inclusion proof of a CID in an anchored batch and
+// the outcome of checking it against the root stored on its ledger
+type Verification struct {
+	Hash  string // the CID the root is recomputed from
+	Leaf  Leaf
+	Batch Batch
+
+	ComputedRoot string // the root recomputed from the CID and its proof
+	ChainRoot    string // the root read back from the ledger the batch is anchored on
+
+	Verified bool
+	Error    string // why the CID could not be verified
+}
+
+// LatestLeaf returns the latest leaf of a CID and its batch, preferring
+// leaves whose batch is anchored. The batch is empty if the CID is waiting
+// for the next batch.
+func LatestLeaf(cid string) (Leaf, Batch, error) {
+	var leaf Leaf
+	var batch Batch
+	leaves, err := RetrieveLeaves(cid)
+	if err != nil {
+		return leaf, batch, err
+	}
+	if len(leaves) == 0 {
+		return leaf, batch, errors.New("hash " + cid + " was never committed")
+	}
+
+	leaf = leaves[len(leaves)-1]
+	for i := len(leaves) - 1; i >= 0; i-- {
+		if leaves[i].BatchID == 0 {
+			continue
+		}
+		b, err := RetrieveBatch(leaves[i].BatchID)
+		if err != nil {
+			return leaf, batch, err
+		}
+
+		leaf = leaves[i]
+		batch = b
+		if batch.Status == BatchAnchored {
+			break
+		}
+	}
+	return leaf, batch, nil
+}
+
+// Verify looks up the inclusion proof of a CID and checks it against the
+// root on the ledger its batch is anchored on. If the CID was committed more
+// than once, its latest anchored leaf is checked. CIDs that are still
+// waiting to be anchored are returned unverified, with the reason in Error.
+func Verify(cid string) (Verification, error) {
+	var v Verification
+	v.Hash = cid
+
+	var err error
+	v.Leaf, v.Batch, err = LatestLeaf(cid)
+	if err != nil {
+		return v, err
+	}
+
+	if v.Leaf.BatchID == 0 {
+		v.Error = "hash is waiting for the next batch"
+		return v, nil
+	}
+	if v.Batch.Status != BatchAnchored {
+		v.Error = "batch is not anchored yet"
+		return v, nil
+	}
+
+	v.Check()
+	return v, nil
+}
+
+// Check recomputes the root from the hash and the proof of the leaf and
+// compares it against the root read back from the ledger in the batch's
+// receipt, configured from globals. It doesn't rely on the root recorded in
+// the batch, so that it can be run on a verification received from an
+// untrusted source.
+func (v *Verification) Check() {
+	a, err := NewAnchor(v.Batch.Receipt.Ledger)
+	if err != nil {
+		v.Verified = false
+		v.Error = err.Error()
+		return
+	}
+	v.CheckWith(a)
+}
+
+// CheckWith checks the verification against the roots of an anchor
+func (v *Verification) CheckWith(a Anchor) {
+	v.Verified = false
+	v.Error = ""
+
+	var err error
+	v.ComputedRoot, err = ProofRoot(v.Hash, v.Leaf.Proof)
+	if err != nil {
+		v.Error = err.Error()
+		return
+	}
+
+	v.ChainRoot, err = a.Root(v.Batch.Receipt)
+	if err != nil {
+		v.Error = errors.Wrap(err, "could not read root from "+v.Batch.Receipt.Ledger).Error()
+		return
+	}
+
+	if !SameRoot(v.ComputedRoot, v.ChainRoot) {
+		v.Error = "recomputed root does not match the root on " + v.Batch.Receipt.Ledger
+		return
+	}
+	v.Verified = true
+}
@@ -1,124 +1,202 @@
 package blockchain
 
 import (
-	"bufio"
 	"context"
-	"fmt"
+	"math/big"
+	"time"
+
 	"github.com/YaleOpenLab/openclimate/blockchain/contracts/blockchain_storage"
-	"github.com/ethereum/go-ethereum/accounts/abi"
+	globals "github.com/YaleOpenLab/openclimate/globals"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
-	"github.com/ethereum/go-ethereum/accounts/keystore"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethclient"
-	"log"
-	"math/big"
-	"os"
-	"runtime"
-	"strings"
+	"github.com/pkg/errors"
 )
 
-const (
-	ipfsRootContractAddress = "0x1d0a334994a361111a193b98e6548bf0e8395879"
-)
+// Backend is the connection to an Ethereum network. *ethclient.Client
+// implements it, and so does go-ethereum's simulated backend.
+type Backend interface {
+	bind.ContractBackend
+	bind.DeployBackend
+}
 
+// headReader is implemented by backends that can report the latest block
+type headReader interface {
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+}
+
+// RootStorage is the root storage contract the roots of the data committed
+// to IPFS are anchored in
 type RootStorage struct {
-	client    *ethclient.Client
-	abi       abi.ABI
-	address   common.Address
-	opts      *bind.TransactOpts
-	timeStamp *big.Int
-	rootHash  [32]byte
+	backend Backend
+	address common.Address
 
 	root *blockchain.IpfsRoot
 }
 
-func NewRoot(address common.Address, client *ethclient.Client, timeStamp *big.Int, rootHash [32]byte) (*RootStorage, error) {
-	parsed, err := abi.JSON(strings.NewReader(blockchain.IpfsRootABI))
-	if err != nil {
-		return nil, err
-	}
-	Root, err := blockchain.NewIpfsRoot(address, client)
+func NewRootStorage(address common.Address, backend Backend) (*RootStorage, error) {
+	root, err := blockchain.NewIpfsRoot(address, backend)
 	if err != nil {
 		return nil, err
 	}
 	return &RootStorage{
-		client:    client,
-		abi:       parsed,
-		address:   address,
-		timeStamp: timeStamp,
-		rootHash:  rootHash,
+		backend: backend,
+		address: address,
 
-		root: Root,
+		root: root,
 	}, nil
 }
 
-func (root *RootStorage) commitRoot(keystore keystore.KeyStore, passphrase string) (*types.Transaction, error) {
-	input, err := root.abi.Pack("insertRoot", root.timeStamp, root.rootHash)
+// CommitRoot stores a root hash under a timestamp and waits until the
+// transaction has the given number of confirmations. It returns the hash of
+// the transaction. If the same root is already stored under the timestamp,
+// for instance because an earlier commit timed out but was mined anyway,
+// nothing is sent and the hash is empty.
+func (s *RootStorage) CommitRoot(ctx context.Context, signer *bind.TransactOpts, timeStamp *big.Int, rootHash string, confirmations int) (string, error) {
+	b, err := hexutil.Decode(rootHash)
+	if err != nil || len(b) != 32 {
+		return "", errors.New("invalid root hash " + rootHash)
+	}
+	var root [32]byte
+	copy(root[:], b)
+
+	stored, err := s.ReadRoot(ctx, timeStamp)
+	if err == nil {
+		if stored != hexutil.Encode(root[:]) {
+			return "", errors.New("a different root is stored under timestamp " + timeStamp.String())
+		}
+		return "", nil
+	}
+	if errors.Cause(err) != errNoRoot {
+		return "", err
+	}
+
+	opts := *signer
+	opts.Context = ctx
+	tx, err := s.root.InsertRoot(&opts, timeStamp, root)
 	if err != nil {
-		return nil, err
+		return "", errors.Wrap(err, "could not send transaction")
 	}
-	nonce, err := root.client.PendingNonceAt(context.Background(), keystore.Accounts()[0].Address)
+
+	err = WaitConfirmed(ctx, s.backend, tx, confirmations)
 	if err != nil {
-		return nil, err
+		return "", err
+	}
+	return tx.Hash().Hex(), nil
+}
+
+var errNoRoot = errors.New("no root stored")
+
+// ReadRoot reads the root hash stored under a timestamp
+func (s *RootStorage) ReadRoot(ctx context.Context, timeStamp *big.Int) (string, error) {
+	opts := &bind.CallOpts{Context: ctx}
+
+	// getRoot reverts if no root is stored under the timestamp
+	exists, err := s.root.CheckTimeStamp(opts, timeStamp)
+	if err != nil {
+		return "", err
 	}
-	fmt.Println("Nonce", nonce)
-	gasPrice, err := root.client.SuggestGasPrice(context.Background())
+	if !exists {
+		return "", errors.Wrap(errNoRoot, "timestamp "+timeStamp.String())
+	}
+
+	x, err := s.root.GetRoot(opts, timeStamp)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
-	fmt.Println("gasPrice")
+	return hexutil.Encode(x.RootHash[:]), nil
+}
 
-	rawTx := types.NewTransaction(nonce, root.address, big.NewInt(0), 300000, gasPrice, input)
-	fmt.Println("RawTx", rawTx)
+// WaitConfirmed waits until a transaction is mined and has the given number
+// of confirmations (the block it is in counts as the first). Receipts don't
+// say which block a transaction is in, so confirmations are counted from the
+// latest block when the receipt is first seen, which never undercounts them.
+// Backends that can't report the latest block, such as the simulated
+// backend, count a mined transaction as confirmed.
+func WaitConfirmed(ctx context.Context, backend Backend, tx *types.Transaction, confirmations int) error {
+	receipt, err := bind.WaitMined(ctx, backend, tx)
+	if err != nil {
+		return errors.Wrap(err, "transaction "+tx.Hash().Hex()+" was not mined")
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return errors.New("transaction " + tx.Hash().Hex() + " failed")
+	}
 
-	accounts := keystore.Accounts()
-	signedTx, err := keystore.SignTxWithPassphrase(accounts[0], passphrase, rawTx, big.NewInt(42))
+	heads, ok := backend.(headReader)
+	if !ok || confirmations <= 1 {
+		return nil
+	}
+
+	head, err := heads.HeaderByNumber(ctx, nil)
 	if err != nil {
-		return nil, err
+		return errors.Wrap(err, "could not read latest block")
+	}
+	target := new(big.Int).Add(head.Number, big.NewInt(int64(confirmations-1)))
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return errors.Wrap(ctx.Err(), "transaction "+tx.Hash().Hex()+" was not confirmed")
+		case <-ticker.C:
+		}
+
+		head, err = heads.HeaderByNumber(ctx, nil)
+		if err != nil || head.Number.Cmp(target) < 0 {
+			continue
+		}
+
+		// the block the transaction was in may have been reorganised away
+		receipt, err = backend.TransactionReceipt(ctx, tx.Hash())
+		if err != nil || receipt == nil {
+			return errors.New("transaction " + tx.Hash().Hex() + " was dropped from the chain")
+		}
+		return nil
 	}
-	return signedTx, err
 }
 
-func CommitToChain(timeStamp *big.Int, rootHash string) error {
-	// Connect to the chain
-	//New Ethereum Client
-	client, err := ethclient.Dial(rpcUrl)
+// CommitToChain stores a root hash in the root storage contract of the
+// configured network under a timestamp and returns the hash of the
+// transaction once it is confirmed
+func CommitToChain(timeStamp *big.Int, rootHash string) (string, error) {
+	signer, err := NewSigner()
 	if err != nil {
-		log.Fatal(err)
+		return "", err
 	}
 
-	// Unlock the account from JSON file using passphrase
-	wallet := keystore.NewKeyStore("./blockchain/wallet/", keystore.StandardScryptN, keystore.StandardScryptP)
-	reader := bufio.NewReader(os.Stdin)
-	fmt.Print("Enter passprase: ")
-	passphrase, _ := reader.ReadString('\n')
+	client, err := ethclient.Dial(globals.EthRpcUrl)
 	if err != nil {
-		log.Fatal(err)
+		return "", errors.Wrap(err, "could not connect to ethereum node")
 	}
-	rootHashBytes, err := hexutil.Decode(rootHash)
+	defer client.Close()
+
+	s, err := NewRootStorage(common.HexToAddress(globals.EthRootContract), client)
 	if err != nil {
-		log.Fatal(err)
+		return "", err
 	}
-	var rootHashBytes32 [32]byte
-	copy(rootHashBytes32[:], rootHashBytes)
-	// Send transaction
-	contractAddress := common.HexToAddress(ipfsRootContractAddress)
-	newRoot, err := NewRoot(contractAddress, client, timeStamp, rootHashBytes32)
+
+	ctx, cancel := context.WithTimeout(context.Background(), globals.EthTxTimeout)
+	defer cancel()
+	return s.CommitRoot(ctx, signer, timeStamp, rootHash, globals.EthConfirmations)
+}
+
+// RetrieveRoot reads the root hash stored in the root storage contract of
+// the configured network under a timestamp
+func RetrieveRoot(timeStamp *big.Int) (string, error) {
+	client, err := ethclient.Dial(globals.EthRpcUrl)
 	if err != nil {
-		_, fn, line, _ := runtime.Caller(1)
-		log.Printf("[error] %s:%d %v", fn, line, err)
+		return "", errors.Wrap(err, "could not connect to ethereum node")
 	}
+	defer client.Close()
 
-	newTx, err := newRoot.commitRoot(*wallet, strings.TrimSpace(passphrase))
+	s, err := NewRootStorage(common.HexToAddress(globals.EthRootContract), client)
 	if err != nil {
-		_, fn, line, _ := runtime.Caller(1)
-		log.Printf("[error] %s:%d %v", fn, line, err)
+		return "", err
 	}
-	client.SendTransaction(context.Background(), newTx)
-	fmt.Println("Successfuly commited new ipfs root.")
-	return nil
+	return s.ReadRoot(context.Background(), timeStamp)
 }
 
 func RetrieveActorEmissions(id int) (map[string]string, error) {
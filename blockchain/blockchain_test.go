@@ -0,0 +1,180 @@
+package blockchain
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	storage "github.com/YaleOpenLab/openclimate/blockchain/contracts/blockchain_storage"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// testChain is a root storage contract deployed on a simulated backend that
+// mines a block every 100ms
+type testChain struct {
+	sim     *backends.SimulatedBackend
+	storage *RootStorage
+	signer  *bind.TransactOpts
+	stop    chan bool
+}
+
+func newTestChain(t *testing.T) *testChain {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := crypto.PubkeyToAddress(key.PublicKey)
+	balance := new(big.Int).Exp(big.NewInt(10), big.NewInt(20), nil)
+	sim := backends.NewSimulatedBackend(core.GenesisAlloc{address: {Balance: balance}}, 8000000)
+
+	// the simulated backend only accepts transactions signed without
+	// replay protection
+	signer, err := KeySigner(hex.EncodeToString(crypto.FromECDSA(key)), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := ioutil.ReadFile("contracts/blockchain_storage/ipfs_hash_storage.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var bin struct {
+		Object string
+	}
+	err = json.Unmarshal(raw, &bin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := abi.JSON(strings.NewReader(storage.IpfsRootABI))
+	if err != nil {
+		t.Fatal(err)
+	}
+	contract, _, _, err := bind.DeployContract(signer, parsed, common.FromHex(bin.Object), sim)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sim.Commit()
+
+	s, err := NewRootStorage(contract, sim)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := &testChain{sim: sim, storage: s, signer: signer, stop: make(chan bool)}
+	go func() {
+		for {
+			select {
+			case <-c.stop:
+				return
+			case <-time.After(100 * time.Millisecond):
+				sim.Commit()
+			}
+		}
+	}()
+	return c
+}
+
+func (c *testChain) Close() {
+	close(c.stop)
+}
+
+func testContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), 30*time.Second)
+}
+
+func TestCommitRoot(t *testing.T) {
+	c := newTestChain(t)
+	defer c.Close()
+	ctx, cancel := testContext()
+	defer cancel()
+
+	timeStamp := big.NewInt(1565752648)
+	root := "0x" + strings.Repeat("ab", 32)
+
+	_, err := c.storage.ReadRoot(ctx, timeStamp)
+	if err == nil {
+		t.Fatal("ReadRoot succeeded before a root was committed")
+	}
+
+	txHash, err := c.storage.CommitRoot(ctx, c.signer, timeStamp, root, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	receipt, err := c.sim.TransactionReceipt(ctx, common.HexToHash(txHash))
+	if err != nil || receipt == nil {
+		t.Fatalf("no receipt for the returned transaction %s: %v", txHash, err)
+	}
+
+	stored, err := c.storage.ReadRoot(ctx, timeStamp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored != root {
+		t.Errorf("ReadRoot = %s, want %s", stored, root)
+	}
+
+	// committing the same root again sends nothing
+	txHash, err = c.storage.CommitRoot(ctx, c.signer, timeStamp, root, 1)
+	if err != nil || txHash != "" {
+		t.Errorf("recommitting the same root = %q, %v, want no transaction", txHash, err)
+	}
+
+	_, err = c.storage.CommitRoot(ctx, c.signer, timeStamp, "0x"+strings.Repeat("cd", 32), 1)
+	if err == nil || !strings.Contains(err.Error(), "different root") {
+		t.Errorf("committing a different root under the same timestamp returned %v", err)
+	}
+}
+
+func TestCommitInvalidRoot(t *testing.T) {
+	c := newTestChain(t)
+	defer c.Close()
+	ctx, cancel := testContext()
+	defer cancel()
+
+	for _, root := range []string{"", "0x1234", "0x" + strings.Repeat("zz", 32), strings.Repeat("ab", 32)} {
+		_, err := c.storage.CommitRoot(ctx, c.signer, big.NewInt(1), root, 1)
+		if err == nil || !strings.Contains(err.Error(), "invalid root hash") {
+			t.Errorf("CommitRoot(%q) returned %v, want an invalid root", root, err)
+		}
+	}
+}
+
+func TestWaitConfirmedReverted(t *testing.T) {
+	c := newTestChain(t)
+	defer c.Close()
+	ctx, cancel := testContext()
+	defer cancel()
+
+	timeStamp := big.NewInt(1)
+	var root [32]byte
+	root[0] = 1
+	_, err := c.storage.CommitRoot(ctx, c.signer, timeStamp, common.ToHex(root[:]), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// insertRoot reverts if the timestamp is taken. The gas limit is set so
+	// that the transaction is sent without being estimated first.
+	opts := *c.signer
+	opts.Context = ctx
+	opts.GasLimit = 200000
+	tx, err := c.storage.root.InsertRoot(&opts, timeStamp, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = WaitConfirmed(ctx, c.sim, tx, 1)
+	if err == nil || !strings.Contains(err.Error(), "failed") {
+		t.Errorf("WaitConfirmed of a reverted transaction returned %v", err)
+	}
+}
@@ -0,0 +1,93 @@
+package blockchain
+
+import (
+	"math/big"
+	"strings"
+
+	globals "github.com/YaleOpenLab/openclimate/globals"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/pkg/errors"
+)
+
+// NewSigner returns the signer of the transactions sent to the configured
+// network: the private key in globals.EthPrivateKey if it is set, otherwise
+// the account of the keystore in globals.EthKeystoreDir
+func NewSigner() (*bind.TransactOpts, error) {
+	var chainID *big.Int
+	if globals.EthChainID != 0 {
+		chainID = big.NewInt(globals.EthChainID)
+	}
+	if globals.EthPrivateKey != "" {
+		return KeySigner(globals.EthPrivateKey, chainID)
+	}
+	return KeystoreSigner(globals.EthKeystoreDir, globals.EthAccount, globals.EthPassphrase, chainID)
+}
+
+// KeySigner returns a signer for a hex encoded private key. Transactions are
+// signed for the chain ID so that they can't be replayed on other networks.
+// If the chain ID is nil they are signed without replay protection, which
+// go-ethereum's simulated backend requires.
+func KeySigner(hexKey string, chainID *big.Int) (*bind.TransactOpts, error) {
+	key, err := crypto.HexToECDSA(strings.TrimPrefix(hexKey, "0x"))
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid private key")
+	}
+
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	var signer types.Signer = types.HomesteadSigner{}
+	if chainID != nil {
+		signer = types.NewEIP155Signer(chainID)
+	}
+	return &bind.TransactOpts{
+		From: from,
+		Signer: func(_ types.Signer, address common.Address, tx *types.Transaction) (*types.Transaction, error) {
+			if address != from {
+				return nil, errors.New("not authorized to sign for " + address.Hex())
+			}
+			return types.SignTx(tx, signer, key)
+		},
+	}, nil
+}
+
+// KeystoreSigner returns a signer for an account of a keystore directory,
+// or its first account if no account is given. The chain ID is used as in
+// KeySigner.
+func KeystoreSigner(dir string, account string, passphrase string, chainID *big.Int) (*bind.TransactOpts, error) {
+	ks := keystore.NewKeyStore(dir, keystore.StandardScryptN, keystore.StandardScryptP)
+	if len(ks.Accounts()) == 0 {
+		return nil, errors.New("no accounts in keystore " + dir)
+	}
+
+	from := ks.Accounts()[0]
+	if account != "" {
+		if !common.IsHexAddress(account) {
+			return nil, errors.New("invalid account " + account)
+		}
+		var err error
+		from, err = ks.Find(accounts.Account{Address: common.HexToAddress(account)})
+		if err != nil {
+			return nil, errors.Wrap(err, "could not find account in keystore")
+		}
+	}
+
+	// fail here rather than when the first transaction is signed
+	err := ks.Unlock(from, passphrase)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not unlock keystore account")
+	}
+
+	return &bind.TransactOpts{
+		From: from.Address,
+		Signer: func(_ types.Signer, address common.Address, tx *types.Transaction) (*types.Transaction, error) {
+			if address != from.Address {
+				return nil, errors.New("not authorized to sign for " + address.Hex())
+			}
+			return ks.SignTx(from, tx, chainID)
+		},
+	}, nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"github.com/YaleOpenLab/openclimate/blockchain/contracts/token"
+	globals "github.com/YaleOpenLab/openclimate/globals"
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/ethclient"
@@ -16,9 +17,7 @@ import (
 // To interact with the token we are using Web3go library. Connection through infura endpoint.
 
 const (
-	contractAddress = "0xb19ac159b87a4491b3b8bef4554b59da2bf42555"
-	ownerAddress    = "0xfE1827f2F1C366c04d458b3c07B8Bd207D42eab4"
-	rpcUrl          = "https://kovan.infura.io/v3/def7370cf49d49d791b9df949986b9a0"
+	ownerAddress = "0xfE1827f2F1C366c04d458b3c07B8Bd207D42eab4"
 )
 
 type Token struct {
@@ -49,7 +48,7 @@ func NewToken(address common.Address, client *ethclient.Client) (*Token, error)
 
 func CheckTokenBalance() {
 	//New Ethereum Client
-	client, err := ethclient.Dial(rpcUrl)
+	client, err := ethclient.Dial(globals.EthRpcUrl)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -66,7 +65,7 @@ func CheckTokenBalance() {
 	fmt.Println(balance)
 
 	//Access the Token
-	contractAddress := common.HexToAddress(contractAddress)
+	contractAddress := common.HexToAddress(globals.EthTokenContract)
 	YToken, err := NewToken(contractAddress, client)
 	if err != nil {
 		log.Fatal(err)
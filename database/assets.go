@@ -2,10 +2,17 @@ package database
 
 import (
 	"encoding/json"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
 	edb "github.com/Varunram/essentials/database"
+	utils "github.com/Varunram/essentials/utils"
 	globals "github.com/YaleOpenLab/openclimate/globals"
 	"github.com/pkg/errors"
-	"log"
 )
 
 type Asset struct {
@@ -18,15 +25,50 @@ type Asset struct {
 	State      string
 	Type       string
 	ActionType []string
-	Capacity   float64
+	Capacity   float64 // nameplate capacity (MW)
+
+	// Production and emissions reported for the asset, one report per period
+	// sorted by period
+	Reports []AssetReport
+}
 
-	Reports []ReportsByYear
+// AssetReport is the production of an asset and the emissions of the fuel it
+// burned in a month (YYYY-MM) or a year (YYYY). Reporting a period again
+// replaces the report and keeps the values it replaced in Corrections.
+type AssetReport struct {
+	Period string
+
+	Generation       float64 // MWh
+	CapacityFactor   float64 // generation relative to running at nameplate capacity all period
+	AvoidedEmissions float64 // tCO2e
+	FuelUse          []AssetFuel
+	Emissions        float64 // tCO2e from the fuel burned
+
+	UserID      int // user who reported the values
+	Corrections []AssetCorrection
+	LastUpdated string
 }
 
-type ReportsByYear struct {
-	Year               int
-	GWh                int
-	MitigationOutcomes int
+// AssetFuel is the quantity of a fuel an asset burned and the emissions
+// computed from it with the emission factor library
+type AssetFuel struct {
+	Fuel     string // e.g. natural gas, diesel
+	Quantity float64
+	Unit     string // e.g. m3, litre, gallon
+	CO2e     float64
+}
+
+// AssetCorrection holds the values of an asset report before a correction
+type AssetCorrection struct {
+	Generation       float64
+	CapacityFactor   float64
+	AvoidedEmissions float64
+	FuelUse          []AssetFuel
+	Emissions        float64
+
+	UserID int // user who reported the replaced values
+	Reason string
+	Date   string
 }
 
 func NewAsset(name string, companyID int, location string, state string, type_ string) (Asset, error) {
@@ -50,19 +92,243 @@ func UpdateAsset(key int, info Asset) error {
 	asset.State = info.State
 	asset.Type = info.Type
 	asset.ActionType = info.ActionType
+	asset.Capacity = info.Capacity
 
 	return asset.Save()
 }
 
-func (a *Asset) ReportAssetData(year int, gwh int, mitOut int) error {
-	var data ReportsByYear
+/*
+	ReportAssetData records the production and emissions of an asset in a
+	period, replacing the report for the same period if there is one. The
+	replaced values are kept as a correction with the given reason. The
+	capacity factor is computed from the generation if it is not reported
+	and the asset's capacity is known. The asset's time series and the totals
+	of its company are updated.
+*/
+func (a *Asset) ReportAssetData(report AssetReport, reason string) (AssetReport, error) {
+	if !ValidPeriod(report.Period) {
+		return report, errors.New("period must be formatted as YYYY or YYYY-MM")
+	}
+	for _, x := range []float64{report.Generation, report.AvoidedEmissions, report.Emissions, report.CapacityFactor} {
+		if math.IsNaN(x) || math.IsInf(x, 0) {
+			return report, errors.New("asset data must be finite numbers")
+		}
+	}
+	if report.Generation < 0 || report.AvoidedEmissions < 0 || report.Emissions < 0 || report.CapacityFactor < 0 {
+		return report, errors.New("asset data cannot be negative")
+	}
+
+	if a.Capacity > 0 {
+		possible := a.Capacity * periodHours(report.Period)
+		if report.Generation > possible {
+			return report, errors.New("generation exceeds the asset's capacity")
+		}
+		if report.CapacityFactor == 0 {
+			report.CapacityFactor = report.Generation / possible
+		}
+	}
+	if report.CapacityFactor > 1 {
+		return report, errors.New("capacity factor cannot exceed 1")
+	}
+
+	report.Corrections = nil
+	report.LastUpdated = utils.Timestamp()
+
+	replaced := false
+	for i, prev := range a.Reports {
+		if prev.Period != report.Period {
+			continue
+		}
+
+		var correction AssetCorrection
+		correction.Generation = prev.Generation
+		correction.CapacityFactor = prev.CapacityFactor
+		correction.AvoidedEmissions = prev.AvoidedEmissions
+		correction.FuelUse = prev.FuelUse
+		correction.Emissions = prev.Emissions
+		correction.UserID = prev.UserID
+		correction.Reason = reason
+		correction.Date = report.LastUpdated
+
+		report.Corrections = append(prev.Corrections, correction)
+		a.Reports[i] = report
+		replaced = true
+	}
+	if !replaced {
+		a.Reports = append(a.Reports, report)
+	}
+
+	sort.Slice(a.Reports, func(i, j int) bool {
+		return a.Reports[i].Period < a.Reports[j].Period
+	})
+
+	err := a.Save()
+	if err != nil {
+		return report, errors.Wrap(err, "could not save asset")
+	}
+
+	err = recordAssetSeries(*a, report.Period)
+	if err != nil {
+		return report, err
+	}
+	return report, nil
+}
+
+// AssetTotals is the production and emissions of a company's assets in a period
+type AssetTotals struct {
+	CompanyID int
+	Period    string
 
-	data.Year = year
-	data.GWh = gwh
-	data.MitigationOutcomes = mitOut
+	Generation       float64 // MWh
+	AvoidedEmissions float64 // tCO2e
+	Emissions        float64 // tCO2e
 
-	a.Reports = append(a.Reports, data)
-	return a.Save()
+	// IDs of the assets that reported data for the period
+	Assets []int
+}
+
+/*
+	CompanyAssetTotals sums up the reports of a company's assets for a
+	period. For a year, assets that did not report the whole year count the
+	sum of their monthly reports instead.
+*/
+func CompanyAssetTotals(companyID int, period string) (AssetTotals, error) {
+	var totals AssetTotals
+	totals.CompanyID = companyID
+	totals.Period = period
+
+	assets, err := RetrieveAllAssets()
+	if err != nil {
+		return totals, errors.Wrap(err, "could not retrieve assets")
+	}
+
+	for _, asset := range assets {
+		if asset.CompanyID != companyID {
+			continue
+		}
+
+		report, exists := asset.PeriodTotal(period)
+		if !exists {
+			continue
+		}
+
+		totals.Generation += report.Generation
+		totals.AvoidedEmissions += report.AvoidedEmissions
+		totals.Emissions += report.Emissions
+		totals.Assets = append(totals.Assets, asset.Index)
+	}
+
+	sort.Ints(totals.Assets)
+	return totals, nil
+}
+
+// periodTotal returns the asset's report for a period, or for a year the sum
+// of its monthly reports if there's no annual one
+func (a Asset) PeriodTotal(period string) (AssetReport, bool) {
+	var total AssetReport
+	total.Period = period
+
+	found := false
+	for _, report := range a.Reports {
+		if report.Period == period {
+			return report, true
+		}
+		if !isMonthly(period) && isMonthly(report.Period) && strings.HasPrefix(report.Period, period) {
+			total.Generation += report.Generation
+			total.AvoidedEmissions += report.AvoidedEmissions
+			total.Emissions += report.Emissions
+			found = true
+		}
+	}
+	return total, found
+}
+
+// Years returns the years the asset reported data for
+func (a Asset) Years() []string {
+	var years []string
+	for _, report := range a.Reports {
+		year := report.Period[:4]
+		if len(years) == 0 || years[len(years)-1] != year {
+			years = append(years, year)
+		}
+	}
+	return years
+}
+
+// recordAssetSeries adds an asset's report for a period to the asset's time
+// series, and updates the totals of its company for the period (and the
+// year, if the period is a month)
+func recordAssetSeries(asset Asset, period string) error {
+	report, _ := asset.PeriodTotal(period)
+	points := assetPoints("asset", asset.Index, period, SourceSelfReported, report.Generation,
+		report.AvoidedEmissions, report.Emissions)
+
+	periods := []string{period}
+	if isMonthly(period) {
+		periods = append(periods, period[:4])
+	}
+
+	for _, p := range periods {
+		totals, err := CompanyAssetTotals(asset.CompanyID, p)
+		if err != nil {
+			return err
+		}
+		points = append(points, assetPoints("company", asset.CompanyID, p, SourceAssets, totals.Generation,
+			totals.AvoidedEmissions, totals.Emissions)...)
+	}
+
+	_, err := RecordDataPoints(points)
+	if err != nil {
+		return errors.Wrap(err, "could not record asset time series")
+	}
+	return nil
+}
+
+func assetPoints(actorType string, actorID int, period string, source string,
+	generation float64, avoided float64, emissions float64) []DataPoint {
+
+	values := []struct {
+		metric string
+		value  float64
+		unit   string
+	}{
+		{MetricGeneration, generation, "MWh"},
+		{MetricMitigation, avoided, "tCO2e"},
+		{MetricScope1, emissions, "tCO2e"},
+	}
+
+	var points []DataPoint
+	for _, v := range values {
+		if v.metric == MetricScope1 && v.value == 0 {
+			// assets that don't burn fuel don't report scope 1 emissions, a
+			// zero would be compared against the company's own reports
+			continue
+		}
+
+		var point DataPoint
+		point.ActorType = actorType
+		point.ActorID = actorID
+		point.Metric = v.metric
+		point.Period = period
+		point.Source = source
+		point.Value = v.value
+		point.Unit = v.unit
+		points = append(points, point)
+	}
+	return points
+}
+
+// periodHours returns the number of hours in a month (YYYY-MM) or a year (YYYY)
+func periodHours(period string) float64 {
+	year, _ := strconv.Atoi(period[:4])
+	start := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(1, 0, 0)
+	if isMonthly(period) {
+		month, _ := strconv.Atoi(period[5:])
+		start = time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
+		end = start.AddDate(0, 1, 0)
+	}
+	return end.Sub(start).Hours()
 }
 
 // Given a key of type int, retrieves the corresponding asset object
@@ -100,7 +100,7 @@ func RetrieveAllCities() ([]City, error) {
 
 	for _, val := range keys {
 		var city City
-		err = json.Unmarshal(val, city)
+		err = json.Unmarshal(val, &city)
 		if err != nil {
 			return cities, errors.Wrap(err, "could not unmarshal struct")
 		}
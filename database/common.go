@@ -79,7 +79,7 @@ func RetrieveActor(actorType string, actorID int) (Actor, error) {
 	}
 
 	if err != nil {
-		return actor, nil
+		return actor, err
 	}
 
 	return actor, nil
@@ -120,6 +120,47 @@ func (x *ConnectRequest) Save() error {
 	return Save(globals.DbPath, RequestBucket, x)
 }
 
+// Saves report object in reports bucket. Called by the New*Report functions
+func (x *Report) Save() error {
+	x.LastUpdated = utils.Timestamp()
+	return Save(globals.DbPath, ReportBucket, x)
+}
+
+// Saves discrepancy in discrepancies bucket. Called by FlagDiscrepancy
+func (x *Discrepancy) Save() error {
+	x.LastUpdated = utils.Timestamp()
+	return Save(globals.DbPath, DiscrepancyBucket, x)
+}
+
+// Saves disclosure policy in disclosure bucket. Called by SetDisclosurePolicy
+func (x *DisclosurePolicy) Save() error {
+	x.LastUpdated = utils.Timestamp()
+	return Save(globals.DbPath, DisclosureBucket, x)
+}
+
+// Saves activity in activities bucket. Called by recordActivity
+func (x *Activity) Save() error {
+	return Save(globals.DbPath, ActivityBucket, x)
+}
+
+// Saves file in files bucket. Called by NewFile
+func (x *File) Save() error {
+	x.LastUpdated = utils.Timestamp()
+	return Save(globals.DbPath, FileBucket, x)
+}
+
+// Saves entity key in keys bucket. Called by newEntityKey
+func (x *EntityKey) Save() error {
+	x.LastUpdated = utils.Timestamp()
+	return Save(globals.DbPath, KeyBucket, x)
+}
+
+// Saves data point in series bucket. Called by RecordDataPoint
+func (x *DataPoint) Save() error {
+	x.LastUpdated = utils.Timestamp()
+	return Save(globals.DbPath, SeriesBucket, x)
+}
+
 // Saves state object in states bucket. Called by NewState
 func (x *State) Save() error {
 	x.LastUpdated = utils.Timestamp()
@@ -177,6 +218,34 @@ func (x *ConnectRequest) SetID(id int) {
 	x.Index = id
 }
 
+func (x *Report) SetID(id int) {
+	x.Index = id
+}
+
+func (x *Discrepancy) SetID(id int) {
+	x.Index = id
+}
+
+func (x *DataPoint) SetID(id int) {
+	x.Index = id
+}
+
+func (x *DisclosurePolicy) SetID(id int) {
+	x.Index = id
+}
+
+func (x *Activity) SetID(id int) {
+	x.Index = id
+}
+
+func (x *File) SetID(id int) {
+	x.Index = id
+}
+
+func (x *EntityKey) SetID(id int) {
+	x.Index = id
+}
+
 func (x *State) SetID(id int) {
 	x.Index = id
 }
@@ -224,6 +293,34 @@ func (x *ConnectRequest) GetID() int {
 	return x.Index
 }
 
+func (x *Report) GetID() int {
+	return x.Index
+}
+
+func (x *Discrepancy) GetID() int {
+	return x.Index
+}
+
+func (x *DataPoint) GetID() int {
+	return x.Index
+}
+
+func (x *DisclosurePolicy) GetID() int {
+	return x.Index
+}
+
+func (x *Activity) GetID() int {
+	return x.Index
+}
+
+func (x *File) GetID() int {
+	return x.Index
+}
+
+func (x *EntityKey) GetID() int {
+	return x.Index
+}
+
 func (x *State) GetID() int {
 	return x.Index
 }
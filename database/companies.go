@@ -70,12 +70,54 @@ type Company struct {
 	Files       []string // list of ipfs hashes to be stored for verification or something similar
 }
 
+// Certificate is an energy attribute certificate (REC, GO, I-REC) or other
+// contractual instrument a company holds. Retired certificates are applied
+// to the market-based scope 2 emissions of the year they were generated in.
 type Certificate struct {
 	ID            int
 	CertificateID int
-	Type          string
-	Unit          string
-	Status        string
+	Type          string // REC, GO, I-REC or PPA
+	Unit          string // kWh, MWh or GWh
+	Status        string // active or retired
+	Volume        float64
+
+	Year           int     // vintage, the year the electricity was generated
+	Market         string  // grid region or country the certificate was issued in
+	EmissionFactor float64 // tCO2e/MWh of the generation, 0 for renewables
+}
+
+// Certificate status
+const (
+	CertificateActive  = "active"
+	CertificateRetired = "retired"
+)
+
+// ErrDuplicateCertificate is returned when a certificate is already held by
+// a company, so that it can't be claimed twice
+var ErrDuplicateCertificate = errors.New("certificate is already registered")
+
+// AddCertificate adds a certificate to the company's holdings. A certificate
+// of a type can only be registered once, by any company.
+func (c *Company) AddCertificate(cert Certificate) (Certificate, error) {
+	companies, err := RetrieveAllCompanies()
+	if err != nil {
+		return cert, errors.Wrap(err, "could not retrieve companies")
+	}
+	for _, company := range companies {
+		holdings := company.Certificates
+		if company.Index == c.Index {
+			holdings = c.Certificates
+		}
+		for _, x := range holdings {
+			if x.CertificateID == cert.CertificateID && x.Type == cert.Type {
+				return cert, ErrDuplicateCertificate
+			}
+		}
+	}
+
+	cert.ID = len(c.Certificates) + 1
+	c.Certificates = append(c.Certificates, cert)
+	return cert, c.Save()
 }
 
 type ClimateReport struct {
@@ -6,7 +6,9 @@ import (
 	"os"
 
 	edb "github.com/Varunram/essentials/database"
+	"github.com/YaleOpenLab/openclimate/anchor"
 	"github.com/YaleOpenLab/openclimate/globals"
+	"github.com/YaleOpenLab/openclimate/ipfs"
 	"github.com/boltdb/bolt"
 )
 
@@ -20,6 +22,13 @@ var OversightBucket = []byte("Oversight")
 var AssetBucket = []byte("Assets")
 var RequestBucket = []byte("Requests")
 var PledgeBucket = []byte("Pledges")
+var ReportBucket = []byte("Reports")
+var SeriesBucket = []byte("Series")
+var DiscrepancyBucket = []byte("Discrepancies")
+var DisclosureBucket = []byte("Disclosure")
+var ActivityBucket = []byte("Activities")
+var FileBucket = []byte("Files")
+var KeyBucket = []byte("EntityKeys")
 
 // CreateHomeDir creates a home directory
 func CreateHomeDir() error {
@@ -47,7 +56,18 @@ func CreateHomeDir() error {
 			StateBucket,
 			OversightBucket,
 			AssetBucket,
-			PledgeBucket)
+			PledgeBucket,
+			ReportBucket,
+			SeriesBucket,
+			DiscrepancyBucket,
+			DisclosureBucket,
+			ActivityBucket,
+			FileBucket,
+			KeyBucket,
+			ipfs.IndexBucket,
+			ipfs.PinBucket,
+			anchor.LeafBucket,
+			anchor.BatchBucket)
 		if err != nil {
 			return errors.Wrap(err, "could not create database")
 		}
@@ -80,7 +100,18 @@ func OpenDB() (*bolt.DB, error) {
 		OversightBucket,
 		AssetBucket,
 		RequestBucket,
-		PledgeBucket)
+		PledgeBucket,
+		ReportBucket,
+		SeriesBucket,
+		DiscrepancyBucket,
+		DisclosureBucket,
+		ActivityBucket,
+		FileBucket,
+		KeyBucket,
+		ipfs.IndexBucket,
+		ipfs.PinBucket,
+		anchor.LeafBucket,
+		anchor.BatchBucket)
 }
 
 // DeleteKeyFromBucket deletes a given key from the bucket bucketName but doesn
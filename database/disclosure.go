@@ -0,0 +1,196 @@
+package database
+
+import (
+	"encoding/json"
+
+	edb "github.com/Varunram/essentials/database"
+	globals "github.com/YaleOpenLab/openclimate/globals"
+	"github.com/pkg/errors"
+)
+
+/*
+
+	Climate actors choose who sees the data they report. A disclosure policy
+	sets a disclosure level for each field (category of data) of an actor:
+
+	- public: anyone sees the data in full
+	- aggregate-only: anyone sees totals, but not the breakdowns behind them
+		(the activities, gases, children and assets a total is made of)
+	- private: only the actor's own users see the data
+	- shared: like private, but the oversight organizations the actor names
+		see the data in full as well
+
+	Named oversight organizations see fields that are aggregate-only in full
+	too. Actors without a policy disclose everything publicly.
+
+*/
+
+// Disclosure levels
+const (
+	DisclosurePublic    = "public"
+	DisclosureAggregate = "aggregate-only"
+	DisclosurePrivate   = "private"
+	DisclosureShared    = "shared"
+)
+
+// Fields disclosure levels are set for
+const (
+	FieldEmissions  = "emissions"  // emissions reports, time series and discrepancies
+	FieldMitigation = "mitigation" // mitigation reports and time series
+	FieldAdaptation = "adaptation" // adaptation reports
+	FieldAssets     = "assets"     // data reported by a company's individual assets
+	FieldPledges    = "pledges"
+)
+
+// Access a viewer has to a field of an actor
+const (
+	AccessNone      = 0
+	AccessAggregate = 1
+	AccessFull      = 2
+)
+
+// DisclosureLevels are the valid disclosure levels
+var DisclosureLevels = []string{DisclosurePublic, DisclosureAggregate, DisclosurePrivate, DisclosureShared}
+
+// DisclosureFields are the fields disclosure levels can be set for
+var DisclosureFields = []string{FieldEmissions, FieldMitigation, FieldAdaptation, FieldAssets, FieldPledges}
+
+// DisclosurePolicy is the disclosure policy of a climate actor
+type DisclosurePolicy struct {
+	Index     int
+	ActorType string
+	ActorID   int
+
+	Default string            // level of the fields that don't have their own
+	Fields  map[string]string // level by field
+
+	SharedWith []int // IDs of the oversight organizations data is shared with
+
+	UserID      int // the user who last changed the policy
+	LastUpdated string
+}
+
+// Level returns the disclosure level of a field
+func (p DisclosurePolicy) Level(field string) string {
+	if level, ok := p.Fields[field]; ok {
+		return level
+	}
+	if p.Default == "" {
+		return DisclosurePublic
+	}
+	return p.Default
+}
+
+// Access returns how much of a field a user can see. The actor's own users
+// see everything, viewers that aren't logged in are passed as an empty user.
+// Admins are only admins of their own entity, so they don't see more of
+// other actors.
+func (p DisclosurePolicy) Access(field string, viewer User) int {
+	if viewer.Index != 0 && viewer.EntityType == p.ActorType && viewer.EntityID == p.ActorID {
+		return AccessFull
+	}
+
+	level := p.Level(field)
+	if level == DisclosurePublic {
+		return AccessFull
+	}
+
+	// private fields stay hidden even from the organizations the actor names
+	sharable := level == DisclosureShared || level == DisclosureAggregate
+	if sharable && viewer.Index != 0 && viewer.EntityType == "oversight" {
+		for _, id := range p.SharedWith {
+			if id == viewer.EntityID {
+				return AccessFull
+			}
+		}
+	}
+
+	if level == DisclosureAggregate {
+		return AccessAggregate
+	}
+	return AccessNone
+}
+
+// Validate checks the levels and fields of a policy and that the oversight
+// organizations it is shared with exist
+func (p DisclosurePolicy) Validate() error {
+	if p.Default != "" && !validOption(DisclosureLevels, p.Default) {
+		return errors.New("invalid disclosure level " + p.Default)
+	}
+	for field, level := range p.Fields {
+		if !validOption(DisclosureFields, field) {
+			return errors.New("invalid disclosure field " + field)
+		}
+		if !validOption(DisclosureLevels, level) {
+			return errors.New("invalid disclosure level " + level)
+		}
+	}
+	for _, id := range p.SharedWith {
+		_, err := RetrieveOsOrg(id)
+		if err != nil {
+			return errors.Wrap(err, "could not retrieve oversight organization")
+		}
+	}
+	return nil
+}
+
+func validOption(options []string, x string) bool {
+	for _, option := range options {
+		if option == x {
+			return true
+		}
+	}
+	return false
+}
+
+// RetrieveDisclosurePolicy returns the disclosure policy of an actor. Actors
+// that haven't set a policy disclose everything publicly.
+func RetrieveDisclosurePolicy(actorType string, actorID int) (DisclosurePolicy, error) {
+	var policy DisclosurePolicy
+	policy.ActorType = actorType
+	policy.ActorID = actorID
+	policy.Default = DisclosurePublic
+
+	keys, err := edb.RetrieveAllKeys(globals.DbPath, DisclosureBucket)
+	if err != nil {
+		return policy, errors.Wrap(err, "could not retrieve disclosure policies")
+	}
+
+	for _, val := range keys {
+		var x DisclosurePolicy
+		err = json.Unmarshal(val, &x)
+		if err != nil {
+			return policy, errors.Wrap(err, "could not unmarshal json")
+		}
+		if x.ActorType == actorType && x.ActorID == actorID {
+			return x, nil
+		}
+	}
+	return policy, nil
+}
+
+// SetDisclosurePolicy validates and stores the disclosure policy of an
+// actor, replacing its earlier policy
+func SetDisclosurePolicy(policy DisclosurePolicy) (DisclosurePolicy, error) {
+	err := policy.Validate()
+	if err != nil {
+		return policy, err
+	}
+
+	prev, err := RetrieveDisclosurePolicy(policy.ActorType, policy.ActorID)
+	if err != nil {
+		return policy, err
+	}
+
+	policy.Index = prev.Index
+	return policy, policy.Save()
+}
+
+// FieldAccess returns how much of a field of an actor a user can see
+func FieldAccess(actorType string, actorID int, field string, viewer User) (int, error) {
+	policy, err := RetrieveDisclosurePolicy(actorType, actorID)
+	if err != nil {
+		return AccessNone, err
+	}
+	return policy.Access(field, viewer), nil
+}
@@ -0,0 +1,185 @@
+package database
+
+import (
+	"encoding/json"
+	"math"
+	"sort"
+
+	edb "github.com/Varunram/essentials/database"
+	"github.com/Varunram/essentials/utils"
+	globals "github.com/YaleOpenLab/openclimate/globals"
+	"github.com/pkg/errors"
+)
+
+// Review status of discrepancies
+const (
+	DiscrepancyOpen      = "open"      // flagged, waiting for review
+	DiscrepancyConfirmed = "confirmed" // a reviewer confirmed the self-reported value is wrong
+	DiscrepancyDismissed = "dismissed" // a reviewer accepted the self-reported value
+	DiscrepancyResolved  = "resolved"  // the values agree again
+)
+
+// Discrepancy is a self-reported value that differs from the value another
+// source reports for the same actor, metric and period by more than the
+// reconciliation tolerance. Discrepancies are flagged by the oracle and
+// reviewed by oversight organizations.
+type Discrepancy struct {
+	Index     int
+	ActorType string
+	ActorID   int
+	Metric    string
+	Period    string
+
+	// the source the self-reported value was compared against
+	Source string
+
+	Reported           float64 // the self-reported value
+	Reference          float64 // the value reported by Source
+	Difference         float64 // Reported - Reference
+	RelativeDifference float64 // Difference relative to Reference
+
+	Status     string
+	ReviewerID int // ID of the oversight organization that reviewed the discrepancy
+	Comment    string
+
+	FirstFlagged string
+	LastUpdated  string
+}
+
+// FlagDiscrepancy stores a discrepancy. If the same discrepancy has already
+// been flagged and the values haven't changed since, its review is kept,
+// otherwise it is reopened.
+func FlagDiscrepancy(d Discrepancy) (Discrepancy, error) {
+	existing, found, err := findDiscrepancy(d.ActorType, d.ActorID, d.Metric, d.Period, d.Source)
+	if err != nil {
+		return d, errors.Wrap(err, "FlagDiscrepancy() failed")
+	}
+
+	d.Index = 0
+	d.Status = DiscrepancyOpen
+	d.FirstFlagged = utils.Timestamp()
+
+	if found {
+		d.Index = existing.Index
+		d.FirstFlagged = existing.FirstFlagged
+		if existing.Reported == d.Reported && existing.Reference == d.Reference &&
+			existing.Status != DiscrepancyResolved {
+			d.Status = existing.Status
+			d.ReviewerID = existing.ReviewerID
+			d.Comment = existing.Comment
+		}
+	}
+
+	return d, d.Save()
+}
+
+// ClearDiscrepancy marks a flagged discrepancy as resolved once the values
+// agree again. It does nothing if the discrepancy was never flagged.
+func ClearDiscrepancy(actorType string, actorID int, metric string, period string, source string) error {
+	existing, found, err := findDiscrepancy(actorType, actorID, metric, period, source)
+	if err != nil {
+		return errors.Wrap(err, "ClearDiscrepancy() failed")
+	}
+
+	if !found || existing.Status == DiscrepancyResolved {
+		return nil
+	}
+
+	existing.Status = DiscrepancyResolved
+	return existing.Save()
+}
+
+// ReviewDiscrepancy records an oversight organization's review of a discrepancy
+func ReviewDiscrepancy(key int, reviewerID int, status string, comment string) (Discrepancy, error) {
+	d, err := RetrieveDiscrepancy(key)
+	if err != nil {
+		return d, errors.Wrap(err, "ReviewDiscrepancy() failed")
+	}
+
+	if status != DiscrepancyConfirmed && status != DiscrepancyDismissed {
+		return d, errors.New("status must be confirmed or dismissed")
+	}
+
+	d.Status = status
+	d.ReviewerID = reviewerID
+	d.Comment = comment
+	return d, d.Save()
+}
+
+func findDiscrepancy(actorType string, actorID int, metric string, period string, source string) (Discrepancy, bool, error) {
+	var empty Discrepancy
+	all, err := RetrieveAllDiscrepancies()
+	if err != nil {
+		return empty, false, err
+	}
+
+	for _, d := range all {
+		if d.ActorType == actorType && d.ActorID == actorID && d.Metric == metric &&
+			d.Period == period && d.Source == source {
+			return d, true, nil
+		}
+	}
+	return empty, false, nil
+}
+
+// Given a key of type int, retrieves the corresponding discrepancy object
+// from the database discrepancies bucket.
+func RetrieveDiscrepancy(key int) (Discrepancy, error) {
+	var d Discrepancy
+	dBytes, err := edb.Retrieve(globals.DbPath, DiscrepancyBucket, key)
+	if err != nil {
+		return d, errors.Wrap(err, "error while retrieving key from bucket")
+	}
+	err = json.Unmarshal(dBytes, &d)
+	return d, err
+}
+
+// RetrieveAllDiscrepancies gets a list of all discrepancies in the database
+func RetrieveAllDiscrepancies() ([]Discrepancy, error) {
+	var arr []Discrepancy
+	keys, err := edb.RetrieveAllKeys(globals.DbPath, DiscrepancyBucket)
+	if err != nil {
+		return arr, errors.Wrap(err, "error while retrieving all discrepancies")
+	}
+
+	for _, val := range keys {
+		var d Discrepancy
+		err = json.Unmarshal(val, &d)
+		if err != nil {
+			return arr, errors.Wrap(err, "could not unmarshal json")
+		}
+		arr = append(arr, d)
+	}
+
+	return arr, nil
+}
+
+// FilterDiscrepancies returns the discrepancies with the given status for
+// the given actor, sorted by the size of the relative difference. Empty
+// filters match all discrepancies, and an actorID of 0 matches all actors of
+// actorType.
+func FilterDiscrepancies(status string, actorType string, actorID int) ([]Discrepancy, error) {
+	var arr []Discrepancy
+	all, err := RetrieveAllDiscrepancies()
+	if err != nil {
+		return arr, errors.Wrap(err, "FilterDiscrepancies() failed")
+	}
+
+	for _, d := range all {
+		if status != "" && d.Status != status {
+			continue
+		}
+		if actorType != "" && d.ActorType != actorType {
+			continue
+		}
+		if actorID != 0 && d.ActorID != actorID {
+			continue
+		}
+		arr = append(arr, d)
+	}
+
+	sort.Slice(arr, func(i, j int) bool {
+		return math.Abs(arr[i].RelativeDifference) > math.Abs(arr[j].RelativeDifference)
+	})
+	return arr, nil
+}
@@ -0,0 +1,223 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	edb "github.com/Varunram/essentials/database"
+	"github.com/Varunram/essentials/utils"
+	globals "github.com/YaleOpenLab/openclimate/globals"
+	"github.com/pkg/errors"
+)
+
+/*
+
+	Users follow climate actors and pledges. Every new report, new or amended
+	pledge and verification of a report is recorded as an activity of the
+	actor it concerns, and a user's feed lists the activities of the actors
+	and pledges they follow, newest first.
+
+*/
+
+// Types of activities
+const (
+	ActivityReport        = "report"         // the actor submitted a report
+	ActivityPledge        = "pledge"         // the actor made a pledge
+	ActivityPledgeAmended = "pledge amended" // the actor changed the terms of a pledge
+	ActivityVerification  = "verification"   // an oversight organization verified a report of the actor
+)
+
+// Follow is a climate actor or pledge a user follows
+type Follow struct {
+	ActorType string
+	ActorID   int
+	PledgeID  int // 0 if the user follows the actor itself
+}
+
+// Activity is an event in the feed of a climate actor
+type Activity struct {
+	Index     int
+	Type      string
+	ActorType string
+	ActorID   int
+
+	PledgeID    int    // pledge activities
+	ReportID    int    // report and verification activities
+	ReportType  string // report and verification activities
+	Year        int    // report and verification activities
+	OversightID int    // verification activities
+
+	Description string
+	Date        string
+}
+
+// recordActivity stores an activity with the current date
+func recordActivity(a Activity) error {
+	a.Index = 0
+	a.Date = utils.Timestamp()
+	return a.Save()
+}
+
+// reportActivity records the submission or verification of a report
+func reportActivity(activityType string, report Report, oversightID int) error {
+	var a Activity
+	a.Type = activityType
+	a.ActorType = report.ActorType
+	a.ActorID = report.ActorID
+	a.ReportID = report.Index
+	a.ReportType = report.ReportType
+	a.Year = report.Year
+	a.OversightID = oversightID
+
+	a.Description = fmt.Sprintf("%s report for %d submitted", report.ReportType, report.Year)
+	if activityType == ActivityVerification {
+		a.Description = fmt.Sprintf("%s report for %d verified by oversight organization %d",
+			report.ReportType, report.Year, oversightID)
+	}
+	return recordActivity(a)
+}
+
+// pledgeActivity records a new or amended pledge
+func pledgeActivity(activityType string, pledge Pledge) error {
+	var a Activity
+	a.Type = activityType
+	a.ActorType = pledge.ActorType
+	a.ActorID = pledge.ActorID
+	a.PledgeID = pledge.ID
+	a.Description = fmt.Sprintf("%s pledge of %g by %g from %g", pledge.PledgeType, pledge.Goal, pledge.TargetYear, pledge.BaseYear)
+	if activityType == ActivityPledgeAmended {
+		a.Description += " (amended)"
+	}
+	return recordActivity(a)
+}
+
+// Follow adds an actor, or a pledge if pledgeID isn't 0, to the ones the
+// user follows. Following something twice has no effect.
+func (u *User) Follow(actorType string, actorID int, pledgeID int) error {
+	f, err := newFollow(actorType, actorID, pledgeID)
+	if err != nil {
+		return err
+	}
+
+	for _, x := range u.Following {
+		if x == f {
+			return nil
+		}
+	}
+
+	u.Following = append(u.Following, f)
+	return u.Save()
+}
+
+// Unfollow removes an actor or pledge from the ones the user follows
+func (u *User) Unfollow(actorType string, actorID int, pledgeID int) error {
+	var following []Follow
+	for _, x := range u.Following {
+		if pledgeID != 0 && x.PledgeID == pledgeID {
+			continue
+		}
+		if pledgeID == 0 && x.PledgeID == 0 && x.ActorType == actorType && x.ActorID == actorID {
+			continue
+		}
+		following = append(following, x)
+	}
+
+	u.Following = following
+	return u.Save()
+}
+
+// newFollow checks that the actor or pledge to follow exists. Pledges are
+// followed together with the actor that made them.
+func newFollow(actorType string, actorID int, pledgeID int) (Follow, error) {
+	var f Follow
+	if pledgeID != 0 {
+		pledge, err := RetrievePledge(pledgeID)
+		if err != nil || pledge.ID == 0 {
+			return f, errors.New("pledge not found")
+		}
+		f.ActorType = pledge.ActorType
+		f.ActorID = pledge.ActorID
+		f.PledgeID = pledge.ID
+		return f, nil
+	}
+
+	_, err := RetrieveActor(actorType, actorID)
+	if err != nil {
+		return f, errors.Wrap(err, "actor not found")
+	}
+	f.ActorType = actorType
+	f.ActorID = actorID
+	return f, nil
+}
+
+// FollowerCount returns the number of users following an actor. Users who
+// only follow some of its pledges aren't counted.
+func FollowerCount(actorType string, actorID int) (int, error) {
+	users, err := RetrieveAllUsers()
+	if err != nil {
+		return 0, errors.Wrap(err, "could not retrieve users")
+	}
+
+	var count int
+	for _, user := range users {
+		for _, f := range user.Following {
+			if f.PledgeID == 0 && f.ActorType == actorType && f.ActorID == actorID {
+				count++
+				break
+			}
+		}
+	}
+	return count, nil
+}
+
+// RetrieveFeed returns the activities of the actors and pledges a user
+// follows, newest first. Following a pledge includes the activities of
+// that pledge only.
+func (u *User) RetrieveFeed() ([]Activity, error) {
+	var feed []Activity
+	if len(u.Following) == 0 {
+		return feed, nil
+	}
+
+	all, err := RetrieveAllActivities()
+	if err != nil {
+		return feed, err
+	}
+
+	for _, a := range all {
+		for _, f := range u.Following {
+			if f.ActorType != a.ActorType || f.ActorID != a.ActorID {
+				continue
+			}
+			if f.PledgeID == 0 || f.PledgeID == a.PledgeID {
+				feed = append(feed, a)
+				break
+			}
+		}
+	}
+
+	sort.Slice(feed, func(i, j int) bool {
+		return feed[i].Index > feed[j].Index
+	})
+	return feed, nil
+}
+
+// RetrieveAllActivities gets a list of all activities in the database
+func RetrieveAllActivities() ([]Activity, error) {
+	var arr []Activity
+	keys, err := edb.RetrieveAllKeys(globals.DbPath, ActivityBucket)
+	if err != nil {
+		return arr, errors.Wrap(err, "error while retrieving all activities")
+	}
+
+	for _, val := range keys {
+		var a Activity
+		err = json.Unmarshal(val, &a)
+		if err != nil {
+			return arr, errors.Wrap(err, "could not unmarshal json")
+		}
+		arr = append(arr, a)
+	}
+	return arr, nil
+}
@@ -0,0 +1,447 @@
+package database
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"hash"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	cc20 "github.com/Varunram/essentials/chacha20poly1305"
+	edb "github.com/Varunram/essentials/database"
+	"github.com/Varunram/essentials/utils"
+	globals "github.com/YaleOpenLab/openclimate/globals"
+	"github.com/YaleOpenLab/openclimate/ipfs"
+	"github.com/pkg/errors"
+)
+
+/*
+
+	Files double as the document registry: every file an actor uploads, e.g.
+	the audit report or NDC backing a report or pledge, is recorded with its
+	metadata and the report or pledge it is evidence for. Replacing a
+	document uploads a new version that links to the one it replaces, and
+	earlier versions are kept so that past verifications can be checked
+	against the documents they were based on.
+
+*/
+
+// Types of documents
+const (
+	DocAuditReport  = "audit report"
+	DocNDC          = "NDC"
+	DocInventory    = "inventory" // greenhouse gas inventory
+	DocVerification = "verification statement"
+	DocOther        = "other"
+)
+
+// DocTypes are the types of documents that can be uploaded
+var DocTypes = []string{DocAuditReport, DocNDC, DocInventory, DocVerification, DocOther}
+
+// File is a file an entity uploaded, encrypted and kept in the content store
+// (see keys.go for how files are encrypted)
+type File struct {
+	Index     int
+	Hash      string // CID of the encrypted file
+	ActorType string
+	ActorID   int
+
+	UploaderID int // the user who uploaded the file
+
+	KeyVersion int    // version of the entity key the data key is wrapped with
+	WrappedKey []byte // the file's data key, encrypted with the entity key
+	Chunked    bool   // encrypted in chunks (see stream.go) rather than as a whole
+
+	Name     string // name of the uploaded file
+	Title    string
+	DocType  string // one of DocTypes
+	Period   string // the period the document covers (e.g. 2018), empty if it doesn't cover one
+	MimeType string
+	Size     int64  // bytes of the unencrypted file
+	Checksum string // sha256 of the unencrypted file, hex encoded
+
+	ReportID int // the report the document is evidence for, 0 if none
+	PledgeID int // the pledge the document is evidence for, 0 if none
+
+	Version    int // 1 for the first upload, incremented with every replacement
+	Replaces   int // index of the file this one replaced, 0 for the first version
+	ReplacedBy int // index of the file that replaced this one, 0 for the current version
+
+	Created     string
+	LastUpdated string
+}
+
+// NewFile encrypts the file read from r with a new data key as it is read,
+// stores and pins it in the content store and records it for the entity that
+// owns it. f holds the owner, uploader and metadata of the file.
+func NewFile(f File, r io.Reader) (File, error) {
+	err := f.validate()
+	if err != nil {
+		return f, err
+	}
+
+	f.Index = 0
+	f.Version = 1
+	f.Replaces = 0
+	f.ReplacedBy = 0
+	return storeFile(f, r)
+}
+
+// Replace uploads the file read from r as a new version of the file.
+// Metadata left empty in next is taken from the current version.
+func (f File) Replace(next File, r io.Reader) (File, error) {
+	if f.ReplacedBy != 0 {
+		return next, errors.New("only the current version of a file can be replaced")
+	}
+
+	next.Index = 0
+	next.ActorType = f.ActorType
+	next.ActorID = f.ActorID
+	next.Version = f.Version + 1
+	next.Replaces = f.Index
+	next.ReplacedBy = 0
+	if next.Title == "" {
+		next.Title = f.Title
+	}
+	if next.DocType == "" {
+		next.DocType = f.DocType
+	}
+	if next.Period == "" {
+		next.Period = f.Period
+	}
+	if next.ReportID == 0 {
+		next.ReportID = f.ReportID
+	}
+	if next.PledgeID == 0 {
+		next.PledgeID = f.PledgeID
+	}
+
+	err := next.validate()
+	if err != nil {
+		return next, err
+	}
+
+	next, err = storeFile(next, r)
+	if err != nil {
+		return next, err
+	}
+
+	f.ReplacedBy = next.Index
+	return next, f.Save()
+}
+
+// validate checks the metadata of a file and that the report or pledge it
+// is evidence for belongs to its owner
+func (f *File) validate() error {
+	_, err := RetrieveActor(f.ActorType, f.ActorID)
+	if err != nil {
+		return errors.Wrap(err, "actor not found")
+	}
+
+	if f.DocType == "" {
+		f.DocType = DocOther
+	}
+	valid := false
+	for _, x := range DocTypes {
+		if f.DocType == x {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return errors.New("invalid document type " + f.DocType)
+	}
+
+	if f.ReportID != 0 {
+		report, err := RetrieveReport(f.ReportID)
+		if err != nil || report.ActorType != f.ActorType || report.ActorID != f.ActorID {
+			return errors.New("report not found")
+		}
+	}
+	if f.PledgeID != 0 {
+		pledge, err := RetrievePledge(f.PledgeID)
+		if err != nil || pledge.ActorType != f.ActorType || pledge.ActorID != f.ActorID {
+			return errors.New("pledge not found")
+		}
+	}
+	return nil
+}
+
+// storeFile encrypts, stores and records a file, without holding it in
+// memory. Files larger than globals.MaxUploadSize are rejected.
+func storeFile(f File, r io.Reader) (File, error) {
+	entityKey, key, err := activeEntityKey(f.ActorType, f.ActorID)
+	if err != nil {
+		return f, err
+	}
+
+	dataKey, err := newKey()
+	if err != nil {
+		return f, err
+	}
+
+	f.KeyVersion = entityKey.Version
+	f.WrappedKey, err = cc20.Encrypt([]byte(dataKey), key)
+	if err != nil {
+		return f, errors.Wrap(err, "could not wrap data key")
+	}
+	f.Chunked = true
+
+	// the file is encrypted as the content store reads it
+	info := newFileInfo()
+	pr, pw := io.Pipe()
+	defer pr.Close()
+	go func() {
+		enc, err := newEncryptWriter(pw, dataKey)
+		if err == nil {
+			_, err = io.Copy(enc, io.TeeReader(&limitedReader{r, globals.MaxUploadSize}, info))
+		}
+		if err == nil {
+			err = enc.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+
+	f.Hash, err = ipfs.PutStream(pr)
+	if err != nil {
+		return f, errors.Wrap(err, "could not store file")
+	}
+
+	f.Size = info.size
+	f.Checksum = hex.EncodeToString(info.hash.Sum(nil))
+	f.MimeType = detectMimeType(info.head, f.MimeType, f.Name)
+	f.Created = utils.Timestamp()
+
+	_, err = ipfs.TrackPin(f.Hash, f.ActorType, f.ActorID, ipfs.PinFile)
+	if err != nil {
+		return f, errors.Wrap(err, "could not track pin")
+	}
+	return f, f.Save()
+}
+
+// fileInfo collects the size, checksum and first bytes of a file as it is
+// read
+type fileInfo struct {
+	size int64
+	hash hash.Hash
+	head []byte
+}
+
+func newFileInfo() *fileInfo {
+	return &fileInfo{hash: sha256.New()}
+}
+
+func (i *fileInfo) Write(p []byte) (int, error) {
+	i.size += int64(len(p))
+	i.hash.Write(p)
+	if k := sniffLen - len(i.head); k > 0 {
+		if k > len(p) {
+			k = len(p)
+		}
+		i.head = append(i.head, p[:k]...)
+	}
+	return len(p), nil
+}
+
+// limitedReader fails once more than n bytes are read
+type limitedReader struct {
+	r io.Reader
+	n int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	if l.n < 0 {
+		return n, errors.New("file is larger than the upload limit")
+	}
+	return n, err
+}
+
+// sniffLen is the number of bytes http.DetectContentType looks at
+const sniffLen = 512
+
+// detectMimeType returns the MIME type of a file from its first bytes. Types
+// that are too generic to be useful (e.g. zip for office documents, or plain
+// text for CSV files) are refined from the extension of the file name, or
+// the type the uploader declared.
+func detectMimeType(head []byte, declared string, name string) string {
+	detected := http.DetectContentType(head)
+	switch strings.SplitN(detected, ";", 2)[0] {
+	case "application/octet-stream", "application/zip", "text/plain":
+		if x := mime.TypeByExtension(strings.ToLower(filepath.Ext(name))); x != "" {
+			return x
+		}
+		if declared != "" {
+			return declared
+		}
+	}
+	return detected
+}
+
+// Open retrieves the file from the content store and returns a reader of the
+// decrypted file, which the caller has to close
+func (f File) Open() (io.ReadCloser, error) {
+	key, err := entityKeyVersion(f.ActorType, f.ActorID, f.KeyVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	dataKey, err := cc20.Decrypt(f.WrappedKey, key)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not unwrap data key")
+	}
+
+	if !f.Chunked {
+		data, err := f.decryptWhole(string(dataKey))
+		if err != nil {
+			return nil, err
+		}
+		return ioutil.NopCloser(bytes.NewReader(data)), nil
+	}
+
+	encrypted, err := ipfs.GetStream(f.Hash)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not retrieve file")
+	}
+
+	dec, err := newDecryptReader(encrypted, string(dataKey))
+	if err != nil {
+		encrypted.Close()
+		return nil, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{dec, encrypted}, nil
+}
+
+// decryptWhole decrypts a file that wasn't encrypted in chunks
+func (f File) decryptWhole(dataKey string) ([]byte, error) {
+	encrypted, err := ipfs.Get(f.Hash)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not retrieve file")
+	}
+
+	data, err := cc20.Decrypt(encrypted, dataKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not decrypt file")
+	}
+	return data, nil
+}
+
+// Decrypt retrieves the file from the content store and decrypts it
+func (f File) Decrypt() ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	data, err := ioutil.ReadAll(rc)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not decrypt file")
+	}
+	return data, nil
+}
+
+// CanDecrypt checks whether a user may decrypt the file: verified users of
+// the entity that owns it, and of the oversight organizations the entity
+// shares its data with (see DisclosurePolicy)
+func (f File) CanDecrypt(user User) (bool, error) {
+	return CanAccessFiles(user, f.ActorType, f.ActorID)
+}
+
+// CanAccessFiles checks whether a user may list and decrypt the files of an
+// entity, see CanDecrypt
+func CanAccessFiles(user User, actorType string, actorID int) (bool, error) {
+	if user.Index == 0 || !user.Verified {
+		return false, nil
+	}
+	if user.EntityType == actorType && user.EntityID == actorID {
+		return true, nil
+	}
+	if user.EntityType != "oversight" {
+		return false, nil
+	}
+
+	policy, err := RetrieveDisclosurePolicy(actorType, actorID)
+	if err != nil {
+		return false, err
+	}
+	for _, id := range policy.SharedWith {
+		if id == user.EntityID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// RetrieveFile retrieves a file from the database
+func RetrieveFile(key int) (File, error) {
+	var f File
+	fileBytes, err := edb.Retrieve(globals.DbPath, FileBucket, key)
+	if err != nil {
+		return f, errors.Wrap(err, "error while retrieving key from bucket")
+	}
+	err = json.Unmarshal(fileBytes, &f)
+	return f, err
+}
+
+// RetrieveAllFiles gets a list of all files in the database
+func RetrieveAllFiles() ([]File, error) {
+	var arr []File
+	keys, err := edb.RetrieveAllKeys(globals.DbPath, FileBucket)
+	if err != nil {
+		return arr, errors.Wrap(err, "error while retrieving all files")
+	}
+
+	for _, val := range keys {
+		var f File
+		err = json.Unmarshal(val, &f)
+		if err != nil {
+			return arr, errors.Wrap(err, "could not unmarshal json")
+		}
+		arr = append(arr, f)
+	}
+	return arr, nil
+}
+
+// RetrieveEntityFiles returns the files an entity uploaded, all versions
+// included
+func RetrieveEntityFiles(actorType string, actorID int) ([]File, error) {
+	var arr []File
+	all, err := RetrieveAllFiles()
+	if err != nil {
+		return arr, err
+	}
+
+	for _, f := range all {
+		if f.ActorType == actorType && f.ActorID == actorID {
+			arr = append(arr, f)
+		}
+	}
+	return arr, nil
+}
+
+// RetrieveFileByHash returns the file stored under a CID
+func RetrieveFileByHash(hash string) (File, error) {
+	var empty File
+	all, err := RetrieveAllFiles()
+	if err != nil {
+		return empty, err
+	}
+
+	for _, f := range all {
+		if f.Hash == hash {
+			return f, nil
+		}
+	}
+	return empty, errors.New("file not found")
+}
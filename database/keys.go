@@ -0,0 +1,214 @@
+package database
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+
+	cc20 "github.com/Varunram/essentials/chacha20poly1305"
+	edb "github.com/Varunram/essentials/database"
+	"github.com/Varunram/essentials/utils"
+	globals "github.com/YaleOpenLab/openclimate/globals"
+	"github.com/pkg/errors"
+)
+
+/*
+
+	Files are encrypted with envelope encryption. Each file is encrypted with
+	its own random data key, and the data key is stored with the file's
+	record, encrypted (wrapped) with the key of the entity that owns the file.
+	Entity keys are stored wrapped with globals.MasterKey, which is read from
+	OPENCLIMATE_MASTER_KEY.
+
+	Rotating an entity's key creates a new version of it and wraps the data
+	keys of all the entity's files with the new version. The files themselves
+	don't need to be encrypted again, and earlier versions are destroyed.
+
+*/
+
+// EntityKey is a version of the key that wraps the data keys of the files of
+// an entity
+type EntityKey struct {
+	Index     int
+	ActorType string
+	ActorID   int
+	Version   int
+
+	WrappedKey []byte // the key encrypted with globals.MasterKey, empty once retired
+	Active     bool   // new files are wrapped with the active version
+	Retired    bool   // the key was rotated out and destroyed
+
+	Created     string
+	LastUpdated string
+}
+
+// errNoMasterKey is returned when entity keys are used without a master key
+var errNoMasterKey = errors.New("master key is not set, see OPENCLIMATE_MASTER_KEY")
+
+// newKey generates a random 256 bit key, hex encoded to be used as a passphrase
+func newKey() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", errors.Wrap(err, "could not generate key")
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// unwrap decrypts the entity key
+func (k EntityKey) unwrap() (string, error) {
+	if k.Retired {
+		return "", errors.New("entity key was retired")
+	}
+	if globals.MasterKey == "" {
+		return "", errNoMasterKey
+	}
+	key, err := cc20.Decrypt(k.WrappedKey, globals.MasterKey)
+	if err != nil {
+		return "", errors.Wrap(err, "could not unwrap entity key")
+	}
+	return string(key), nil
+}
+
+// newEntityKey creates a new active version of an entity's key
+func newEntityKey(actorType string, actorID int, version int) (EntityKey, string, error) {
+	var k EntityKey
+	if globals.MasterKey == "" {
+		return k, "", errNoMasterKey
+	}
+	k.ActorType = actorType
+	k.ActorID = actorID
+	k.Version = version
+	k.Active = true
+	k.Created = utils.Timestamp()
+
+	key, err := newKey()
+	if err != nil {
+		return k, "", err
+	}
+
+	k.WrappedKey, err = cc20.Encrypt([]byte(key), globals.MasterKey)
+	if err != nil {
+		return k, "", errors.Wrap(err, "could not wrap entity key")
+	}
+	return k, key, k.Save()
+}
+
+// RetrieveEntityKeys returns all versions of an entity's key
+func RetrieveEntityKeys(actorType string, actorID int) ([]EntityKey, error) {
+	var arr []EntityKey
+	keys, err := edb.RetrieveAllKeys(globals.DbPath, KeyBucket)
+	if err != nil {
+		return arr, errors.Wrap(err, "error while retrieving entity keys")
+	}
+
+	for _, val := range keys {
+		var k EntityKey
+		err = json.Unmarshal(val, &k)
+		if err != nil {
+			return arr, errors.Wrap(err, "could not unmarshal json")
+		}
+		if k.ActorType == actorType && k.ActorID == actorID {
+			arr = append(arr, k)
+		}
+	}
+	return arr, nil
+}
+
+// activeEntityKey returns the active version of an entity's key and the
+// unwrapped key, creating the first version if the entity has none
+func activeEntityKey(actorType string, actorID int) (EntityKey, string, error) {
+	keys, err := RetrieveEntityKeys(actorType, actorID)
+	if err != nil {
+		var empty EntityKey
+		return empty, "", err
+	}
+
+	for _, k := range keys {
+		if k.Active {
+			key, err := k.unwrap()
+			return k, key, err
+		}
+	}
+	return newEntityKey(actorType, actorID, len(keys)+1)
+}
+
+// entityKeyVersion returns a version of an entity's key, unwrapped
+func entityKeyVersion(actorType string, actorID int, version int) (string, error) {
+	keys, err := RetrieveEntityKeys(actorType, actorID)
+	if err != nil {
+		return "", err
+	}
+
+	for _, k := range keys {
+		if k.Version == version {
+			return k.unwrap()
+		}
+	}
+	return "", errors.New("entity key version not found")
+}
+
+// RotateEntityKey creates a new version of an entity's key, wraps the data
+// keys of all its files with it and destroys the earlier versions
+func RotateEntityKey(actorType string, actorID int) (EntityKey, error) {
+	var empty EntityKey
+	keys, err := RetrieveEntityKeys(actorType, actorID)
+	if err != nil {
+		return empty, err
+	}
+
+	// unwrap the current versions before they are retired
+	unwrapped := make(map[int]string)
+	for _, k := range keys {
+		if k.Retired {
+			continue
+		}
+		unwrapped[k.Version], err = k.unwrap()
+		if err != nil {
+			return empty, err
+		}
+	}
+
+	rotated, key, err := newEntityKey(actorType, actorID, len(keys)+1)
+	if err != nil {
+		return empty, err
+	}
+
+	files, err := RetrieveEntityFiles(actorType, actorID)
+	if err != nil {
+		return rotated, err
+	}
+
+	for _, f := range files {
+		dataKey, err := cc20.Decrypt(f.WrappedKey, unwrapped[f.KeyVersion])
+		if err != nil {
+			return rotated, errors.Wrap(err, "could not unwrap data key")
+		}
+
+		f.WrappedKey, err = cc20.Encrypt(dataKey, key)
+		if err != nil {
+			return rotated, errors.Wrap(err, "could not wrap data key")
+		}
+		f.KeyVersion = rotated.Version
+
+		err = f.Save()
+		if err != nil {
+			return rotated, err
+		}
+	}
+
+	// the earlier versions are only destroyed once no file depends on them
+	for _, k := range keys {
+		if k.Retired {
+			continue
+		}
+		k.Active = false
+		k.Retired = true
+		k.WrappedKey = nil
+		err = k.Save()
+		if err != nil {
+			return rotated, err
+		}
+	}
+	return rotated, nil
+}
@@ -59,6 +59,11 @@ func NewPledge(pledgeType string, baseYear float64, targetYear float64, goal flo
 		return p, errors.Wrap(err, "NewPledge() failed")
 	}
 
+	err = pledgeActivity(ActivityPledge, p)
+	if err != nil {
+		return p, errors.Wrap(err, "NewPledge() failed")
+	}
+
 	return p, nil
 }
 
@@ -75,7 +80,12 @@ func UpdatePledge(key int, updated Pledge) error {
 	pledge.TargetYear = updated.TargetYear
 	pledge.Goal = updated.Goal
 	pledge.Regulatory = updated.Regulatory
-	return pledge.Save()
+
+	err = pledge.Save()
+	if err != nil {
+		return errors.Wrap(err, "UpdatePledge() failed")
+	}
+	return pledgeActivity(ActivityPledgeAmended, pledge)
 }
 
 func RetrievePledge(key int) (Pledge, error) {
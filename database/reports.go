@@ -0,0 +1,337 @@
+package database
+
+import (
+	"encoding/json"
+	"sort"
+
+	edb "github.com/Varunram/essentials/database"
+	"github.com/Varunram/essentials/utils"
+	"github.com/YaleOpenLab/openclimate/anchor"
+	globals "github.com/YaleOpenLab/openclimate/globals"
+	"github.com/YaleOpenLab/openclimate/ipfs"
+	"github.com/pkg/errors"
+)
+
+// Report is a climate action report (emissions, mitigation or adaptation)
+// that was validated by the oracle and committed to IPFS. The report data is
+// also kept in the database so that it can be retrieved and aggregated
+// without querying IPFS. Exactly one of Emissions, Mitigation and Adaptation
+// is set, depending on ReportType.
+type Report struct {
+	Index      int
+	ActorType  string
+	ActorID    int
+	ReportType string
+	Year       int
+	IpfsHash   string
+
+	Emissions  *ipfs.Emissions  `json:",omitempty"`
+	Mitigation *ipfs.Mitigation `json:",omitempty"`
+	Adaptation *ipfs.Adaptation `json:",omitempty"`
+
+	// Oversight organizations that reviewed the report and vouch for it
+	Verifications []Verification
+
+	// The batch the report's hash is anchored in and the receipt of where
+	// its root is stored, once the batch is anchored (see RecordAnchors)
+	AnchorBatch   int             `json:",omitempty"`
+	AnchorReceipt *anchor.Receipt `json:",omitempty"`
+
+	LastUpdated string
+}
+
+// Verification records an oversight organization's review of a report
+type Verification struct {
+	OversightID int
+	UserID      int // the member of the organization who reviewed the report
+	Date        string
+}
+
+// ReportSummary aggregates all the reports of a climate actor for one year
+type ReportSummary struct {
+	Year int
+
+	// Emissions (tCO2e)
+	Scope1CO2e       float64
+	Scope2CO2e       float64 // location-based
+	Scope2MarketCO2e float64
+	Scope3CO2e       float64
+
+	// Emissions of each gas by scope (tonnes), converted to CO2e with the
+	// global warming potentials the report chose
+	Inventory []ipfs.GasEmissions
+
+	// Mitigation (tCO2e and MWh)
+	CarbonOffset float64
+	EnergySaved  float64
+	EnergyGen    float64
+
+	// Adaptation
+	AdaptationInvestment float64
+	Beneficiaries        int
+	ExposedPopulation    int
+	Hazards              map[string]int // number of adaptation actions by hazard type
+
+	IpfsHashes []string // the reports the summary was computed from
+}
+
+// NewEmissionsReport stores a validated emissions report and adds its scope
+// totals to the actor's time series
+func NewEmissionsReport(actorType string, actorID int, ipfsHash string, data ipfs.Emissions) (Report, error) {
+	var report Report
+	report.ActorType = actorType
+	report.ActorID = actorID
+	report.ReportType = "Emissions"
+	report.Year = data.Year
+	report.IpfsHash = ipfsHash
+	report.Emissions = &data
+
+	err := report.Save()
+	if err != nil {
+		return report, errors.Wrap(err, "could not save report")
+	}
+
+	err = reportActivity(ActivityReport, report, 0)
+	if err != nil {
+		return report, errors.Wrap(err, "could not record activity")
+	}
+	return report, RecordEmissionsSeries(actorType, actorID, ipfsHash, data)
+}
+
+// NewMitigationReport stores a validated mitigation report and adds its
+// totals to the actor's time series
+func NewMitigationReport(actorType string, actorID int, ipfsHash string, data ipfs.Mitigation) (Report, error) {
+	var report Report
+	report.ActorType = actorType
+	report.ActorID = actorID
+	report.ReportType = "Mitigation"
+	report.Year = data.Year
+	report.IpfsHash = ipfsHash
+	report.Mitigation = &data
+
+	err := report.Save()
+	if err != nil {
+		return report, errors.Wrap(err, "could not save report")
+	}
+
+	err = reportActivity(ActivityReport, report, 0)
+	if err != nil {
+		return report, errors.Wrap(err, "could not record activity")
+	}
+	return report, RecordMitigationSeries(actorType, actorID, ipfsHash, data)
+}
+
+// NewAdaptationReport stores a validated adaptation report
+func NewAdaptationReport(actorType string, actorID int, ipfsHash string, data ipfs.Adaptation) (Report, error) {
+	var report Report
+	report.ActorType = actorType
+	report.ActorID = actorID
+	report.ReportType = "Adaptation"
+	report.Year = data.Year
+	report.IpfsHash = ipfsHash
+	report.Adaptation = &data
+
+	err := report.Save()
+	if err != nil {
+		return report, errors.Wrap(err, "could not save report")
+	}
+	return report, reportActivity(ActivityReport, report, 0)
+}
+
+// Verify records that an oversight organization reviewed the report. An
+// organization that reviews a report again replaces its earlier verification.
+// Every review is recorded in the actor's activity feed.
+func (report *Report) Verify(oversightID int, userID int) error {
+	_, err := RetrieveOsOrg(oversightID)
+	if err != nil {
+		return errors.Wrap(err, "could not retrieve oversight organization")
+	}
+
+	var v Verification
+	v.OversightID = oversightID
+	v.UserID = userID
+	v.Date = utils.Timestamp()
+
+	replaced := false
+	for i, prev := range report.Verifications {
+		if prev.OversightID == oversightID {
+			report.Verifications[i] = v
+			replaced = true
+		}
+	}
+	if !replaced {
+		report.Verifications = append(report.Verifications, v)
+	}
+
+	err = report.Save()
+	if err != nil {
+		return err
+	}
+	return reportActivity(ActivityVerification, *report, oversightID)
+}
+
+// RecordAnchors stores the anchor receipt with every report whose hash was
+// anchored since it was last called. If a hash was anchored more than once,
+// the latest receipt is stored.
+func RecordAnchors() error {
+	batches, err := anchor.RetrieveAllBatches()
+	if err != nil {
+		return err
+	}
+	anchored := make(map[int]anchor.Batch)
+	for _, b := range batches {
+		if b.Status == anchor.BatchAnchored {
+			anchored[b.Index] = b
+		}
+	}
+
+	leaves, err := anchor.RetrieveAllLeaves()
+	if err != nil {
+		return err
+	}
+	latest := make(map[string]anchor.Batch)
+	for _, x := range leaves {
+		b, ok := anchored[x.BatchID]
+		if ok {
+			latest[x.Hash] = b
+		}
+	}
+
+	reports, err := RetrieveAllReports()
+	if err != nil {
+		return err
+	}
+	for _, report := range reports {
+		b, ok := latest[report.IpfsHash]
+		if !ok || report.AnchorBatch == b.Index {
+			continue
+		}
+
+		receipt := b.Receipt
+		report.AnchorBatch = b.Index
+		report.AnchorReceipt = &receipt
+		err = report.Save()
+		if err != nil {
+			return errors.Wrap(err, "could not save report")
+		}
+	}
+	return nil
+}
+
+// Given a key of type int, retrieves the corresponding report object
+// from the database reports bucket.
+func RetrieveReport(key int) (Report, error) {
+	var report Report
+	reportBytes, err := edb.Retrieve(globals.DbPath, ReportBucket, key)
+	if err != nil {
+		return report, errors.Wrap(err, "error while retrieving key from bucket")
+	}
+	err = json.Unmarshal(reportBytes, &report)
+	return report, err
+}
+
+// RetrieveAllReports gets a list of all reports in the database
+func RetrieveAllReports() ([]Report, error) {
+	var reports []Report
+	keys, err := edb.RetrieveAllKeys(globals.DbPath, ReportBucket)
+	if err != nil {
+		return reports, errors.Wrap(err, "error while retrieving all reports")
+	}
+
+	for _, val := range keys {
+		var report Report
+		err = json.Unmarshal(val, &report)
+		if err != nil {
+			return reports, errors.Wrap(err, "could not unmarshal json")
+		}
+		reports = append(reports, report)
+	}
+
+	return reports, nil
+}
+
+// RetrieveActorReports gets all reports of the given type submitted by a
+// climate actor, sorted by year. If reportType is empty, reports of all
+// types are returned.
+func RetrieveActorReports(actorType string, actorID int, reportType string) ([]Report, error) {
+	var reports []Report
+	all, err := RetrieveAllReports()
+	if err != nil {
+		return reports, errors.Wrap(err, "RetrieveActorReports() failed")
+	}
+
+	for _, report := range all {
+		if report.ActorType != actorType || report.ActorID != actorID {
+			continue
+		}
+		if reportType != "" && report.ReportType != reportType {
+			continue
+		}
+		reports = append(reports, report)
+	}
+
+	sort.SliceStable(reports, func(i, j int) bool {
+		return reports[i].Year < reports[j].Year
+	})
+	return reports, nil
+}
+
+// SummarizeReports aggregates emissions, mitigation and adaptation reports
+// by year. If an actor submitted more than one report of a type for the same
+// year, the latest one replaces the earlier ones.
+func SummarizeReports(reports []Report) []ReportSummary {
+	latest := make(map[int]map[string]Report)
+	for _, report := range reports {
+		if latest[report.Year] == nil {
+			latest[report.Year] = make(map[string]Report)
+		}
+		prev, exists := latest[report.Year][report.ReportType]
+		if !exists || report.Index > prev.Index {
+			latest[report.Year][report.ReportType] = report
+		}
+	}
+
+	var summaries []ReportSummary
+	for year, byType := range latest {
+		var summary ReportSummary
+		summary.Year = year
+		summary.Hazards = make(map[string]int)
+
+		for _, report := range byType {
+			if report.IpfsHash != "" {
+				summary.IpfsHashes = append(summary.IpfsHashes, report.IpfsHash)
+			}
+
+			if report.Emissions != nil {
+				summary.Scope1CO2e += float64(report.Emissions.TotalScope1CO2e)
+				summary.Scope2CO2e += float64(report.Emissions.TotalScope2CO2e)
+				summary.Scope2MarketCO2e += float64(report.Emissions.TotalScope2MarketCO2e)
+				summary.Scope3CO2e += float64(report.Emissions.TotalScope3CO2e)
+				summary.Inventory = append(summary.Inventory, report.Emissions.Inventory...)
+			}
+
+			if report.Mitigation != nil {
+				summary.CarbonOffset += report.Mitigation.TotalCarbonOffset
+				summary.EnergySaved += report.Mitigation.TotalEnergySaved
+				summary.EnergyGen += report.Mitigation.TotalEnergyGen
+			}
+
+			if report.Adaptation != nil {
+				summary.AdaptationInvestment += report.Adaptation.TotalInvestment
+				summary.Beneficiaries += report.Adaptation.TotalBeneficiaries
+				for _, child := range report.Adaptation.ByChild {
+					summary.ExposedPopulation += child.ExposedPopulation
+					summary.Hazards[child.HazardType]++
+				}
+			}
+		}
+
+		sort.Strings(summary.IpfsHashes)
+		summaries = append(summaries, summary)
+	}
+
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].Year < summaries[j].Year
+	})
+	return summaries
+}
@@ -0,0 +1,89 @@
+package database
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/YaleOpenLab/openclimate/anchor"
+	globals "github.com/YaleOpenLab/openclimate/globals"
+	"github.com/YaleOpenLab/openclimate/ipfs"
+)
+
+func TestRecordAnchors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "openclimate-db-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	dbPath := globals.DbPath
+	globals.DbPath = filepath.Join(dir, "openclimate.db")
+	defer func() {
+		globals.DbPath = dbPath
+	}()
+	db, err := OpenDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	report, err := NewAdaptationReport("company", 1, "QmA", ipfs.Adaptation{Year: 2019})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := NewAdaptationReport("company", 1, "QmC", ipfs.Adaptation{Year: 2020})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, cid := range []string{"QmA", "QmB"} {
+		_, err = anchor.Enqueue(cid, "Adaptation", "company", 1, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	a := anchor.NewLocalAnchor(filepath.Join(dir, "anchors.log"))
+	batch, err := anchor.AnchorPending(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if batch.Status != anchor.BatchAnchored || batch.Receipt.Ledger != anchor.LedgerLocal {
+		t.Fatalf("AnchorPending returned batch %+v", batch)
+	}
+
+	err = RecordAnchors()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err = RetrieveReport(report.Index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.AnchorBatch != batch.Index || report.AnchorReceipt == nil ||
+		report.AnchorReceipt.Ledger != anchor.LedgerLocal || report.AnchorReceipt.Timestamp != batch.Timestamp {
+		t.Errorf("report anchored in batch %d, receipt %+v, want batch %d, receipt %+v",
+			report.AnchorBatch, report.AnchorReceipt, batch.Index, batch.Receipt)
+	}
+
+	// reports whose hash wasn't anchored have no receipt
+	other, err = RetrieveReport(other.Index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.AnchorBatch != 0 || other.AnchorReceipt != nil {
+		t.Errorf("report that wasn't anchored has batch %d, receipt %+v", other.AnchorBatch, other.AnchorReceipt)
+	}
+
+	// the receipt lets the hash be verified against the log
+	v, err := anchor.Verify("QmA")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v.CheckWith(a)
+	if !v.Verified {
+		t.Errorf("verifying an anchored hash failed: %s", v.Error)
+	}
+}
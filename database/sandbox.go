@@ -143,6 +143,21 @@ func populateAvangridCompany() {
 		log.Println(err)
 		return
 	}
+
+	// Add renewable energy certificates
+	var rec Certificate
+	rec.CertificateID = 1001
+	rec.Type = "REC"
+	rec.Unit = "MWh"
+	rec.Status = CertificateRetired
+	rec.Volume = 10000
+	rec.Year = 2018
+	rec.Market = "NEWE"
+	_, err = avangrid.AddCertificate(rec)
+	if err != nil {
+		log.Println(err)
+		return
+	}
 }
 
 func populateAvangridAssets() {
@@ -158,7 +173,7 @@ func populateAvangridAssets() {
 		return
 	}
 
-	err = bfc.ReportAssetData(2018, 206, 19164)
+	_, err = bfc.ReportAssetData(AssetReport{Period: "2018", Generation: 206000, AvoidedEmissions: 19164}, "")
 	if err != nil {
 		log.Println(err)
 		return
@@ -182,6 +197,23 @@ func populateAvangridAssets() {
 		log.Println(err)
 		return
 	}
+	bs.Capacity = 2.2
+	err = UpdateAsset(bs.Index, bs)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	bs, err = RetrieveAsset(bs.Index)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	_, err = bs.ReportAssetData(AssetReport{Period: "2018", Generation: 2891}, "")
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
 	wh, err := NewAsset("Woodbridge High", avangrid.GetID(), "Woodbridge", "Connecticut", "Gas Fuel Cell")
 	if err != nil {
 		log.Println(err)
@@ -0,0 +1,407 @@
+package database
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+
+	edb "github.com/Varunram/essentials/database"
+	globals "github.com/YaleOpenLab/openclimate/globals"
+	"github.com/YaleOpenLab/openclimate/ipfs"
+	"github.com/pkg/errors"
+)
+
+/*
+
+	Time series of the values reported for each climate actor. Every data
+	point is keyed by actor, metric, period and source, so that values for the
+	same metric from different sources (self-reported, static datasets, the
+	oracle) can be compared side by side.
+
+	Metrics are named after the scope they belong to and optionally the gas
+	or sector they are broken down by, e.g. "scope1", "scope1/CH4",
+	"scope2/Energy". Periods are either years ("2018") or months ("2018-03").
+	All metrics are flows, so monthly values are summed up into annual ones.
+
+*/
+
+// Emissions and mitigation metrics
+const (
+	MetricScope1            = "scope1"
+	MetricScope2            = "scope2"
+	MetricScope2Market      = "scope2/market" // market-based scope 2, scope2 is location-based
+	MetricScope3            = "scope3"
+	MetricMitigation        = "mitigation"         // carbon offset by mitigation actions
+	MetricMitigationActions = "mitigation/actions" // number of mitigation actions
+	MetricGeneration        = "generation"         // electricity generated (MWh)
+)
+
+// Sources of data points. Values imported from static datasets use the
+// dataset's own source (e.g. CDIAC, UNFCCC, BP for territorial emissions).
+const (
+	SourceSelfReported = "self-reported"
+	SourceOracle       = "oracle"
+	SourceCDP          = "CDP"
+	SourceAssets       = "assets" // company totals rolled up from their assets' reports
+)
+
+// Granularities that time series can be queried at
+const (
+	Annual  = "annual"
+	Monthly = "monthly"
+)
+
+// DataPoint is a single value in a climate actor's time series
+type DataPoint struct {
+	Index     int
+	ActorType string
+	ActorID   int
+	Metric    string
+	Period    string
+	Source    string
+	Value     float64
+	Unit      string
+
+	// IPFS hash of the report the value was taken from, if any
+	IpfsHash string
+
+	LastUpdated string
+}
+
+// SeriesValue is the value of a metric in a period
+type SeriesValue struct {
+	Period string
+	Value  float64
+	Unit   string
+}
+
+// SeriesQuery selects the data points returned by QuerySeries
+type SeriesQuery struct {
+	ActorType   string
+	ActorID     int
+	Metric      string
+	From        string   // first period to return (inclusive), optional
+	To          string   // last period to return (inclusive), optional
+	Granularity string   // Annual or Monthly
+	Sources     []string // sources to return, all sources if empty
+}
+
+// GasMetric returns the name of the metric tracking a single gas in a scope
+func GasMetric(scope string, gas string) string {
+	return scope + "/" + gas
+}
+
+// SectorMetric returns the name of the metric tracking a single sector in a scope
+func SectorMetric(scope string, sector string) string {
+	return scope + "/" + sector
+}
+
+// ValidPeriod checks whether period is a year (YYYY) or a month (YYYY-MM)
+func ValidPeriod(period string) bool {
+	parts := strings.Split(period, "-")
+	if len(parts) > 2 || len(parts[0]) != 4 {
+		return false
+	}
+
+	if _, err := strconv.Atoi(parts[0]); err != nil {
+		return false
+	}
+
+	if len(parts) == 2 {
+		month, err := strconv.Atoi(parts[1])
+		if err != nil || len(parts[1]) != 2 || month < 1 || month > 12 {
+			return false
+		}
+	}
+	return true
+}
+
+func isMonthly(period string) bool {
+	return len(period) == len("2006-01")
+}
+
+// RecordDataPoint adds a value to an actor's time series. If a value has
+// already been recorded for the same actor, metric, period and source, it
+// is replaced.
+func RecordDataPoint(point DataPoint) (DataPoint, error) {
+	points, err := RecordDataPoints([]DataPoint{point})
+	if err != nil {
+		return point, errors.Wrap(err, "RecordDataPoint() failed")
+	}
+	return points[0], nil
+}
+
+// RecordDataPoints adds several values to the time series, see RecordDataPoint
+func RecordDataPoints(points []DataPoint) ([]DataPoint, error) {
+	for _, point := range points {
+		if !ValidPeriod(point.Period) {
+			return points, errors.New("period must be formatted as YYYY or YYYY-MM")
+		}
+		if point.Metric == "" || point.Source == "" {
+			return points, errors.New("data points must have a metric and a source")
+		}
+	}
+
+	all, err := RetrieveAllDataPoints()
+	if err != nil {
+		return points, errors.Wrap(err, "RecordDataPoints() failed")
+	}
+
+	indices := make(map[string]int)
+	for _, existing := range all {
+		indices[pointKey(existing)] = existing.Index
+	}
+
+	for i := range points {
+		points[i].Index = indices[pointKey(points[i])]
+		err = points[i].Save()
+		if err != nil {
+			return points, errors.Wrap(err, "could not save data point")
+		}
+		indices[pointKey(points[i])] = points[i].Index
+	}
+	return points, nil
+}
+
+func pointKey(point DataPoint) string {
+	return point.ActorType + "/" + strconv.Itoa(point.ActorID) + "/" + point.Metric + "/" +
+		point.Period + "/" + point.Source
+}
+
+// RecordEmissionsSeries adds the scope totals and the emissions of each gas
+// of an emissions report to the reporting actor's time series
+func RecordEmissionsSeries(actorType string, actorID int, ipfsHash string, data ipfs.Emissions) error {
+	totals := map[string]int{
+		MetricScope1:       data.TotalScope1CO2e,
+		MetricScope2:       data.TotalScope2CO2e,
+		MetricScope2Market: data.TotalScope2MarketCO2e,
+		MetricScope3:       data.TotalScope3CO2e,
+	}
+
+	var points []DataPoint
+	for metric, value := range totals {
+		var point DataPoint
+		point.ActorType = actorType
+		point.ActorID = actorID
+		point.Metric = metric
+		point.Period = strconv.Itoa(data.Year)
+		point.Source = SourceSelfReported
+		point.Value = float64(value)
+		point.Unit = data.Unit
+		point.IpfsHash = ipfsHash
+		points = append(points, point)
+	}
+
+	// gases are recorded in tonnes so that they can be converted to CO2e
+	// with any set of global warming potentials
+	for _, gas := range data.Inventory {
+		var point DataPoint
+		point.ActorType = actorType
+		point.ActorID = actorID
+		point.Metric = GasMetric("scope"+strconv.Itoa(gas.Scope), gas.Gas)
+		point.Period = strconv.Itoa(data.Year)
+		point.Source = SourceSelfReported
+		point.Value = gas.Quantity
+		point.Unit = "t"
+		point.IpfsHash = ipfsHash
+		points = append(points, point)
+	}
+
+	_, err := RecordDataPoints(points)
+	if err != nil {
+		return errors.Wrap(err, "RecordEmissionsSeries() failed")
+	}
+	return nil
+}
+
+// RecordMitigationSeries adds the carbon offset and the number of mitigation
+// actions of a mitigation report to the reporting actor's time series
+func RecordMitigationSeries(actorType string, actorID int, ipfsHash string, data ipfs.Mitigation) error {
+	var offset, actions DataPoint
+	offset.Metric = MetricMitigation
+	offset.Value = data.TotalCarbonOffset
+	offset.Unit = data.Unit
+	actions.Metric = MetricMitigationActions
+	actions.Value = float64(len(data.ByChild))
+	actions.Unit = "actions"
+
+	points := []DataPoint{offset, actions}
+	for i := range points {
+		points[i].ActorType = actorType
+		points[i].ActorID = actorID
+		points[i].Period = strconv.Itoa(data.Year)
+		points[i].Source = SourceSelfReported
+		points[i].IpfsHash = ipfsHash
+	}
+
+	_, err := RecordDataPoints(points)
+	if err != nil {
+		return errors.Wrap(err, "RecordMitigationSeries() failed")
+	}
+	return nil
+}
+
+// RetrieveAllDataPoints gets a list of all data points in the database
+func RetrieveAllDataPoints() ([]DataPoint, error) {
+	var points []DataPoint
+	keys, err := edb.RetrieveAllKeys(globals.DbPath, SeriesBucket)
+	if err != nil {
+		return points, errors.Wrap(err, "error while retrieving all data points")
+	}
+
+	for _, val := range keys {
+		var point DataPoint
+		err = json.Unmarshal(val, &point)
+		if err != nil {
+			return points, errors.Wrap(err, "could not unmarshal json")
+		}
+		points = append(points, point)
+	}
+
+	return points, nil
+}
+
+// RetrieveActorDataPoints gets all data points recorded for a climate actor
+func RetrieveActorDataPoints(actorType string, actorID int) ([]DataPoint, error) {
+	var points []DataPoint
+	all, err := RetrieveAllDataPoints()
+	if err != nil {
+		return points, errors.Wrap(err, "RetrieveActorDataPoints() failed")
+	}
+
+	for _, point := range all {
+		if point.ActorType == actorType && point.ActorID == actorID {
+			points = append(points, point)
+		}
+	}
+	return points, nil
+}
+
+/*
+	QuerySeries returns the time series of a metric for a climate actor,
+	keyed by source. Values are sorted by period.
+
+	At annual granularity, monthly values are summed up for every year. If a
+	source reported an annual value as well, the annual value is returned
+	instead of the sum. At monthly granularity only monthly values are
+	returned since annual values can't be split up.
+*/
+func QuerySeries(q SeriesQuery) (map[string][]SeriesValue, error) {
+	result := make(map[string][]SeriesValue)
+
+	if q.Granularity == "" {
+		q.Granularity = Annual
+	}
+	if q.Granularity != Annual && q.Granularity != Monthly {
+		return result, errors.New("granularity must be annual or monthly")
+	}
+	if (q.From != "" && !ValidPeriod(q.From)) || (q.To != "" && !ValidPeriod(q.To)) {
+		return result, errors.New("period must be formatted as YYYY or YYYY-MM")
+	}
+
+	points, err := RetrieveActorDataPoints(q.ActorType, q.ActorID)
+	if err != nil {
+		return result, errors.Wrap(err, "QuerySeries() failed")
+	}
+
+	// source -> period -> value
+	annual := make(map[string]map[string]SeriesValue)
+	summed := make(map[string]map[string]SeriesValue)
+	monthly := make(map[string]map[string]SeriesValue)
+
+	for _, point := range points {
+		if point.Metric != q.Metric {
+			continue
+		}
+		if len(q.Sources) != 0 && !containsString(q.Sources, point.Source) {
+			continue
+		}
+
+		value := SeriesValue{Period: point.Period, Value: point.Value, Unit: point.Unit}
+		if !isMonthly(point.Period) {
+			addValue(annual, point.Source, value)
+			continue
+		}
+
+		addValue(monthly, point.Source, value)
+		year := SeriesValue{Period: point.Period[:4], Value: point.Value, Unit: point.Unit}
+		if prev, exists := summed[point.Source][year.Period]; exists {
+			year.Value += prev.Value
+		}
+		addValue(summed, point.Source, year)
+	}
+
+	selected := monthly
+	if q.Granularity == Annual {
+		// reported annual values take precedence over the sum of monthly values
+		for source, periods := range annual {
+			for _, value := range periods {
+				addValue(summed, source, value)
+			}
+		}
+		selected = summed
+	}
+
+	for source, periods := range selected {
+		var values []SeriesValue
+		for _, value := range periods {
+			if !inRange(value.Period, q.From, q.To) {
+				continue
+			}
+			values = append(values, value)
+		}
+
+		sort.Slice(values, func(i, j int) bool {
+			return values[i].Period < values[j].Period
+		})
+
+		if len(values) != 0 {
+			result[source] = values
+		}
+	}
+
+	return result, nil
+}
+
+func addValue(m map[string]map[string]SeriesValue, source string, value SeriesValue) {
+	if m[source] == nil {
+		m[source] = make(map[string]SeriesValue)
+	}
+	m[source][value.Period] = value
+}
+
+// inRange checks whether period falls between from and to. Years and months
+// are compared by their common prefix, so that the month 2018-03 is within
+// the range 2018 - 2019 and the year 2018 is within 2018-06 - 2019-06.
+func inRange(period string, from string, to string) bool {
+	if from != "" {
+		n := minLen(period, from)
+		if period[:n] < from[:n] {
+			return false
+		}
+	}
+	if to != "" {
+		n := minLen(period, to)
+		if period[:n] > to[:n] {
+			return false
+		}
+	}
+	return true
+}
+
+func minLen(a string, b string) int {
+	if len(a) < len(b) {
+		return len(a)
+	}
+	return len(b)
+}
+
+func containsString(arr []string, x string) bool {
+	for _, elem := range arr {
+		if elem == x {
+			return true
+		}
+	}
+	return false
+}
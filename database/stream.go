@@ -0,0 +1,199 @@
+package database
+
+import (
+	"bufio"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+/*
+
+	Files are encrypted in chunks so that they can be uploaded and downloaded
+	without holding them in memory. An encrypted file is a random nonce
+	prefix followed by the chunks of the file, each sealed with
+	XChaCha20-Poly1305 under the file's data key. The nonce of a chunk is the
+	prefix, the number of the chunk and a flag set on the last chunk only, so
+	that chunks can't be reordered, dropped or the file truncated without
+	decryption failing.
+
+	prefix (16 bytes) | chunk 0 | chunk 1 | ... | last chunk
+	nonce = prefix | chunk number (7 bytes, big endian) | last (1 byte)
+
+*/
+
+const (
+	streamChunkSize = 65536 // bytes of the file in a chunk
+	streamPrefixLen = 16
+)
+
+// streamCipher seals and opens the chunks of a file
+type streamCipher struct {
+	aead   cipher.AEAD
+	prefix []byte
+	count  uint64
+}
+
+func newStreamCipher(key string, prefix []byte) (*streamCipher, error) {
+	k, err := hex.DecodeString(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid data key")
+	}
+	aead, err := chacha20poly1305.NewX(k)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid data key")
+	}
+	return &streamCipher{aead: aead, prefix: prefix}, nil
+}
+
+func (c *streamCipher) nonce(last bool) []byte {
+	var count [8]byte
+	binary.BigEndian.PutUint64(count[:], c.count)
+	c.count++
+
+	nonce := make([]byte, 0, chacha20poly1305.NonceSizeX)
+	nonce = append(nonce, c.prefix...)
+	nonce = append(nonce, count[1:]...)
+	if last {
+		return append(nonce, 1)
+	}
+	return append(nonce, 0)
+}
+
+// encryptWriter encrypts the data written to it and writes it to w. Close
+// has to be called to write the last chunk.
+type encryptWriter struct {
+	w      io.Writer
+	cipher *streamCipher
+	chunk  []byte
+}
+
+func newEncryptWriter(w io.Writer, key string) (*encryptWriter, error) {
+	prefix := make([]byte, streamPrefixLen)
+	_, err := rand.Read(prefix)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not generate nonce")
+	}
+
+	c, err := newStreamCipher(key, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = w.Write(prefix)
+	if err != nil {
+		return nil, err
+	}
+	return &encryptWriter{w: w, cipher: c, chunk: make([]byte, 0, streamChunkSize)}, nil
+}
+
+func (e *encryptWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		// a full chunk is only sealed once more data follows, since the
+		// last chunk is sealed differently
+		if len(e.chunk) == streamChunkSize {
+			err := e.seal(false)
+			if err != nil {
+				return n - len(p), err
+			}
+		}
+
+		k := streamChunkSize - len(e.chunk)
+		if k > len(p) {
+			k = len(p)
+		}
+		e.chunk = append(e.chunk, p[:k]...)
+		p = p[k:]
+	}
+	return n, nil
+}
+
+// Close seals and writes the last chunk
+func (e *encryptWriter) Close() error {
+	return e.seal(true)
+}
+
+func (e *encryptWriter) seal(last bool) error {
+	sealed := e.cipher.aead.Seal(nil, e.cipher.nonce(last), e.chunk, nil)
+	e.chunk = e.chunk[:0]
+	_, err := e.w.Write(sealed)
+	return err
+}
+
+// decryptReader decrypts a file encrypted by encryptWriter as it is read
+type decryptReader struct {
+	r      *bufio.Reader
+	cipher *streamCipher
+	sealed []byte
+	chunk  []byte // decrypted data not read yet
+	done   bool
+}
+
+func newDecryptReader(r io.Reader, key string) (*decryptReader, error) {
+	prefix := make([]byte, streamPrefixLen)
+	_, err := io.ReadFull(r, prefix)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read encrypted file")
+	}
+
+	c, err := newStreamCipher(key, prefix)
+	if err != nil {
+		return nil, err
+	}
+	return &decryptReader{
+		r:      bufio.NewReader(r),
+		cipher: c,
+		sealed: make([]byte, streamChunkSize+c.aead.Overhead()),
+	}, nil
+}
+
+func (d *decryptReader) Read(p []byte) (int, error) {
+	for len(d.chunk) == 0 {
+		if d.done {
+			return 0, io.EOF
+		}
+		err := d.open()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, d.chunk)
+	d.chunk = d.chunk[n:]
+	return n, nil
+}
+
+// open reads and decrypts the next chunk
+func (d *decryptReader) open() error {
+	n, err := io.ReadFull(d.r, d.sealed)
+	last := false
+	switch err {
+	case nil:
+		// a full chunk is the last one if nothing follows it
+		_, err = d.r.Peek(1)
+		last = err == io.EOF
+		if err != nil && err != io.EOF {
+			return errors.Wrap(err, "could not read encrypted file")
+		}
+	case io.ErrUnexpectedEOF:
+		last = true
+	case io.EOF:
+		return errors.New("encrypted file is truncated")
+	default:
+		return errors.Wrap(err, "could not read encrypted file")
+	}
+
+	chunk, err := d.cipher.aead.Open(nil, d.cipher.nonce(last), d.sealed[:n], nil)
+	if err != nil {
+		return errors.New("encrypted file is corrupted or was tampered with")
+	}
+	d.chunk = chunk
+	d.done = last
+	return nil
+}
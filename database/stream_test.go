@@ -0,0 +1,122 @@
+package database
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// encryptTest encrypts data, writing it in pieces of the given size
+func encryptTest(t *testing.T, key string, data []byte, piece int) []byte {
+	var buf bytes.Buffer
+	e, err := newEncryptWriter(&buf, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < len(data); i += piece {
+		end := i + piece
+		if end > len(data) {
+			end = len(data)
+		}
+		_, err = e.Write(data[i:end])
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = e.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func decryptTest(encrypted []byte, key string) ([]byte, error) {
+	d, err := newDecryptReader(bytes.NewReader(encrypted), key)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(d)
+}
+
+func testData(n int) []byte {
+	data := make([]byte, n)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+	return data
+}
+
+func TestStreamRoundTrip(t *testing.T) {
+	key, err := newKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sizes := []int{0, 1, streamChunkSize - 1, streamChunkSize, streamChunkSize + 1, 3 * streamChunkSize}
+	for _, size := range sizes {
+		data := testData(size)
+		for _, piece := range []int{1000, streamChunkSize, size + 1} {
+			encrypted := encryptTest(t, key, data, piece)
+			decrypted, err := decryptTest(encrypted, key)
+			if err != nil {
+				t.Errorf("%d bytes written in pieces of %d: %v", size, piece, err)
+				continue
+			}
+			if !bytes.Equal(decrypted, data) {
+				t.Errorf("%d bytes written in pieces of %d decrypted to %d different bytes", size, piece, len(decrypted))
+			}
+		}
+	}
+}
+
+func TestStreamTampered(t *testing.T) {
+	key, err := newKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := newKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// prefix | 3 full chunks | last chunk of 100 bytes
+	encrypted := encryptTest(t, key, testData(3*streamChunkSize+100), 1000)
+	sealed := streamChunkSize + chacha20poly1305.Overhead
+	chunk := func(i int) []byte {
+		start := streamPrefixLen + i*sealed
+		end := start + sealed
+		if end > len(encrypted) {
+			end = len(encrypted)
+		}
+		return encrypted[start:end]
+	}
+	join := func(parts ...[]byte) []byte {
+		return bytes.Join(parts, nil)
+	}
+	prefix := encrypted[:streamPrefixLen]
+
+	flipped := append([]byte{}, encrypted...)
+	flipped[streamPrefixLen+10] ^= 1
+
+	cases := map[string][]byte{
+		"last chunk dropped":     join(prefix, chunk(0), chunk(1), chunk(2)),
+		"chunks reordered":       join(prefix, chunk(1), chunk(0), chunk(2), chunk(3)),
+		"last chunks reordered":  join(prefix, chunk(0), chunk(1), chunk(3), chunk(2)),
+		"truncated in a chunk":   encrypted[:len(encrypted)-50],
+		"truncated to a chunk":   encrypted[:streamPrefixLen+sealed+10],
+		"truncated to no chunks": prefix,
+		"truncated prefix":       encrypted[:streamPrefixLen-1],
+		"byte flipped":           flipped,
+	}
+	for name, tampered := range cases {
+		if _, err := decryptTest(tampered, key); err == nil {
+			t.Errorf("%s: decryption succeeded", name)
+		}
+	}
+
+	if _, err := decryptTest(encrypted, other); err == nil {
+		t.Error("decryption with another key succeeded")
+	}
+}
@@ -38,8 +38,7 @@ type User struct {
 	Admin      bool   // is the user an admin for its entity?
 
 	EthereumWallet EthWallet
-	Liked          []string // array of liked projects
-	NotVisible     []string // array of visible projects
+	Following      []Follow // actors and pledges the user follows
 	//CosmosWallet   CosmWallet
 }
 
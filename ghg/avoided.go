@@ -0,0 +1,71 @@
+package ghg
+
+import (
+	"strings"
+
+	"github.com/YaleOpenLab/openclimate/database"
+	"github.com/pkg/errors"
+)
+
+// Asset types, matched in the asset's type, whose generation displaces
+// electricity from the grid without emitting greenhouse gases
+var renewableTypes = []string{"solar", "wind", "hydro", "geothermal"}
+
+// Avoided is the emissions avoided by the electricity an asset generated
+type Avoided struct {
+	AssetID    int
+	GridRegion string
+	Factor     float64 // tCO2e/MWh
+
+	Generation       float64 // GWh
+	AvoidedEmissions float64 // tCO2e
+}
+
+// IsRenewable checks whether an asset generates renewable electricity
+func IsRenewable(asset database.Asset) bool {
+	assetType := strings.ToLower(asset.Type)
+	for _, t := range renewableTypes {
+		if strings.Contains(assetType, t) {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+	AvoidedEmissions computes the emissions a renewable asset avoided by
+	generating electricity (in GWh) in the given year, assuming that it
+	displaced generation at the average emission factor of the grid region
+	that supplies the asset's state.
+*/
+func AvoidedEmissions(asset database.Asset, generation float64, year int, gwp GWPSet) (Avoided, error) {
+	var avoided Avoided
+	avoided.AssetID = asset.Index
+	avoided.Generation = generation
+
+	if !IsRenewable(asset) {
+		return avoided, errors.New(asset.Name + " is not a renewable energy asset")
+	}
+	if generation < 0 {
+		return avoided, errors.New("generation cannot be negative")
+	}
+
+	company, err := database.RetrieveCompany(asset.CompanyID)
+	if err != nil {
+		return avoided, errors.Wrap(err, "could not retrieve the asset's company")
+	}
+
+	region, err := RetrieveStateGridRegion(asset.State, company.Country)
+	if err != nil {
+		return avoided, err
+	}
+
+	avoided.GridRegion = region.Name
+	avoided.Factor, err = region.LocationFactor(year, gwp)
+	if err != nil {
+		return avoided, err
+	}
+
+	avoided.AvoidedEmissions = generation * 1e3 * avoided.Factor
+	return avoided, nil
+}
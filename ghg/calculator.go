@@ -0,0 +1,127 @@
+package ghg
+
+import (
+	"fmt"
+
+	"github.com/YaleOpenLab/openclimate/database"
+	"github.com/YaleOpenLab/openclimate/ipfs"
+	"github.com/pkg/errors"
+)
+
+// Conversion factors from the units activity data can be reported in to the
+// unit of the emission factor
+var activityUnits = map[string]map[string]float64{
+	"litre": {
+		"litre":  1,
+		"m3":     1e3,
+		"gallon": 3.78541,
+	},
+	"m3": {
+		"m3":  1,
+		"scf": 0.0283168,
+	},
+	"kg": {
+		"kg": 1,
+		"t":  1e3,
+		"lb": 0.453592,
+	},
+	"kWh": {
+		"kWh": 1,
+		"MWh": 1e3,
+		"GWh": 1e6,
+	},
+	"km": {
+		"km":   1,
+		"mile": 1.609344,
+	},
+	"passenger-km": {
+		"passenger-km":   1,
+		"passenger-mile": 1.609344,
+	},
+}
+
+// Calculation is the emissions of an activity
+type Calculation struct {
+	Factor   EmissionFactor
+	Quantity float64 // in the unit of the emission factor
+	GWP      GWPSet
+
+	Gases map[string]float64 // tonnes of each gas
+	CO2e  float64            // tCO2e
+}
+
+/*
+	Calculate converts activity data to the quantity of each gas emitted and
+	their CO2 equivalent, using the emission factor for the activity in the
+	given year (from source, if set) and the given global warming potentials.
+*/
+func Calculate(category string, activity string, quantity float64, unit string,
+	year int, source string, gwp GWPSet) (Calculation, error) {
+
+	var result Calculation
+	if quantity < 0 {
+		return result, errors.New("quantity cannot be negative")
+	}
+
+	factor, err := LookupEmissionFactor(category, activity, year, source)
+	if err != nil {
+		return result, err
+	}
+
+	conversion, ok := activityUnits[factor.Unit][unit]
+	if !ok {
+		return result, errors.New(fmt.Sprintf("%s can't be converted to %s", unit, factor.Unit))
+	}
+
+	result.Factor = factor
+	result.Quantity = quantity * conversion
+	result.GWP = gwp
+	result.Gases = make(map[string]float64)
+	for gas, kg := range factor.Gases {
+		// factors are in kg per unit, emissions are reported in tonnes
+		result.Gases[gas] = result.Quantity * kg / 1e3
+	}
+
+	result.CO2e, err = CO2e(result.Gases, gwp)
+	if err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// ComputeActivities calculates the emissions of the activities reported for
+// the given year, filling in the factor used, the gases and the CO2e of each
+func ComputeActivities(activities []ipfs.Activity, year int, gwp GWPSet) ([]ipfs.Activity, error) {
+	computed := make([]ipfs.Activity, len(activities))
+	for i, activity := range activities {
+		c, err := Calculate(activity.Category, activity.Activity, activity.Quantity, activity.Unit,
+			year, activity.FactorSource, gwp)
+		if err != nil {
+			return computed, errors.Wrap(err, "could not calculate emissions of "+activity.Activity)
+		}
+
+		activity.FactorID = c.Factor.ID
+		activity.Gases = c.Gases
+		activity.CO2e = c.CO2e
+		computed[i] = activity
+	}
+	return computed, nil
+}
+
+// ComputeFuelUse calculates the emissions of the fuel an asset burned in the
+// given year, returning the fuels with their CO2e and the total
+func ComputeFuelUse(fuels []database.AssetFuel, year int, gwp GWPSet) ([]database.AssetFuel, float64, error) {
+	var total float64
+	computed := make([]database.AssetFuel, len(fuels))
+	for i, fuel := range fuels {
+		c, err := Calculate(CategoryFuel, fuel.Fuel, fuel.Quantity, fuel.Unit, year, "", gwp)
+		if err != nil {
+			return computed, total, errors.Wrap(err, "could not calculate emissions of "+fuel.Fuel)
+		}
+
+		fuel.CO2e = c.CO2e
+		total += c.CO2e
+		computed[i] = fuel
+	}
+	return computed, total, nil
+}
@@ -0,0 +1,110 @@
+package ghg
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"strconv"
+
+	"github.com/YaleOpenLab/openclimate/globals"
+	"github.com/pkg/errors"
+)
+
+// Categories of activities in the emission factor library
+const (
+	CategoryFuel        = "fuel"        // stationary and mobile fuel combustion
+	CategoryElectricity = "electricity" // grid electricity, by grid region
+	CategoryTransport   = "transport"   // distance travelled
+	CategoryRefrigerant = "refrigerant" // refrigerant and other fluorinated gas leaks
+)
+
+// EmissionFactor is the quantity of each greenhouse gas emitted per unit of
+// an activity. The library is read from globals.EmissionFactorsPath and can
+// hold several versions of the factor for an activity, published by
+// different sources for different years.
+type EmissionFactor struct {
+	ID       string // Source/Year/Category/Activity, set when the library is loaded
+	Category string
+	Activity string // e.g. diesel, NEWE, passenger car, R-410A
+	Unit     string // unit of the activity data, e.g. litre, kWh, km, kg
+
+	// kg of each gas emitted per unit of activity
+	Gases map[string]float64
+
+	Year   int
+	Source string
+}
+
+// LoadEmissionFactors reads the emission factor library
+func LoadEmissionFactors() ([]EmissionFactor, error) {
+	var factors []EmissionFactor
+
+	data, err := ioutil.ReadFile(globals.EmissionFactorsPath)
+	if err != nil {
+		return factors, errors.Wrap(err, "could not read emission factors")
+	}
+
+	err = json.Unmarshal(data, &factors)
+	if err != nil {
+		return factors, errors.Wrap(err, "could not parse emission factors")
+	}
+
+	for i, factor := range factors {
+		factors[i].ID = factor.Source + "/" + strconv.Itoa(factor.Year) + "/" + factor.Category + "/" + factor.Activity
+	}
+	return factors, nil
+}
+
+/*
+	FilterEmissionFactors returns the factors in the library that match the
+	given category, activity and source. Empty arguments match all factors.
+*/
+func FilterEmissionFactors(category string, activity string, source string) ([]EmissionFactor, error) {
+	var arr []EmissionFactor
+
+	factors, err := LoadEmissionFactors()
+	if err != nil {
+		return arr, err
+	}
+
+	for _, factor := range factors {
+		if (category == "" || factor.Category == category) &&
+			(activity == "" || factor.Activity == activity) &&
+			(source == "" || factor.Source == source) {
+			arr = append(arr, factor)
+		}
+	}
+	return arr, nil
+}
+
+/*
+	LookupEmissionFactor returns the factor to use for an activity in the
+	given year: the latest one published for that year or before, or the
+	earliest one if they were all published later. If source is set, only
+	factors from that source are considered.
+*/
+func LookupEmissionFactor(category string, activity string, year int, source string) (EmissionFactor, error) {
+	var empty EmissionFactor
+
+	factors, err := FilterEmissionFactors(category, activity, source)
+	if err != nil {
+		return empty, err
+	}
+	if len(factors) == 0 {
+		msg := "no " + category + " emission factor for " + activity
+		if source != "" {
+			msg += " from " + source
+		}
+		return empty, errors.New(msg)
+	}
+
+	best := factors[0]
+	for _, factor := range factors[1:] {
+		switch {
+		case factor.Year <= year && (best.Year > year || factor.Year > best.Year):
+			best = factor
+		case factor.Year > year && best.Year > year && factor.Year < best.Year:
+			best = factor
+		}
+	}
+	return best, nil
+}
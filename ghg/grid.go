@@ -0,0 +1,109 @@
+package ghg
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+
+	"github.com/YaleOpenLab/openclimate/globals"
+	"github.com/pkg/errors"
+)
+
+// GridRegion is an electricity grid region and the residual mix emission
+// factor of the electricity consumed in it, read from globals.GridRegionsPath.
+// The average emission factors of the grid are in the emission factor
+// library, under the electricity category and the region's name.
+type GridRegion struct {
+	Name     string
+	FullName string
+	Country  string
+	Year     int
+
+	// States the grid region supplies. Regions named after their country
+	// cover the whole country and are used for states not listed elsewhere.
+	States []string
+
+	// Emission factor of the generation left once all energy attribute
+	// certificates have been claimed (tCO2e/MWh), used for market-based
+	// scope 2 emissions. 0 if no residual mix has been published for the
+	// region, in which case the location factor is used instead.
+	ResidualMixFactor float64
+	ResidualMixSource string
+}
+
+// LoadGridRegions reads the grid regions and their residual mix factors
+func LoadGridRegions() ([]GridRegion, error) {
+	var regions []GridRegion
+
+	data, err := ioutil.ReadFile(globals.GridRegionsPath)
+	if err != nil {
+		return regions, errors.Wrap(err, "could not read grid regions")
+	}
+
+	err = json.Unmarshal(data, &regions)
+	if err != nil {
+		return regions, errors.Wrap(err, "could not parse grid regions")
+	}
+	return regions, nil
+}
+
+// RetrieveGridRegion returns the grid region with the given name
+func RetrieveGridRegion(name string) (GridRegion, error) {
+	var empty GridRegion
+	regions, err := LoadGridRegions()
+	if err != nil {
+		return empty, err
+	}
+
+	for _, region := range regions {
+		if region.Name == name {
+			return region, nil
+		}
+	}
+	return empty, errors.New("grid region " + name + " not found")
+}
+
+// RetrieveStateGridRegion returns the grid region that supplies a state,
+// falling back to the country's national grid region
+func RetrieveStateGridRegion(state string, country string) (GridRegion, error) {
+	var empty GridRegion
+	regions, err := LoadGridRegions()
+	if err != nil {
+		return empty, err
+	}
+
+	for _, region := range regions {
+		for _, s := range region.States {
+			if strings.EqualFold(s, state) && strings.EqualFold(region.Country, country) {
+				return region, nil
+			}
+		}
+	}
+
+	for _, region := range regions {
+		if region.Name == region.Country && strings.EqualFold(region.Country, country) {
+			return region, nil
+		}
+	}
+	return empty, errors.New("no grid region for " + state + ", " + country)
+}
+
+// LocationFactor returns the average emission factor of the generation in
+// the grid region in the given year (tCO2e/MWh), used for location-based
+// scope 2 emissions
+func (g GridRegion) LocationFactor(year int, gwp GWPSet) (float64, error) {
+	c, err := Calculate(CategoryElectricity, g.Name, 1, "MWh", year, "", gwp)
+	if err != nil {
+		return 0, err
+	}
+	return c.CO2e, nil
+}
+
+// residualMix returns the factor used for consumption that isn't covered by
+// certificates or a supplier-specific factor
+func (g GridRegion) residualMix(locationFactor float64) float64 {
+	if g.ResidualMixFactor == 0 {
+		return locationFactor
+	}
+	return g.ResidualMixFactor
+}
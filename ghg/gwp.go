@@ -0,0 +1,148 @@
+package ghg
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/YaleOpenLab/openclimate/globals"
+	"github.com/pkg/errors"
+)
+
+// Assessment reports of the IPCC that global warming potentials are taken from
+const (
+	AR4 = "AR4"
+	AR5 = "AR5"
+	AR6 = "AR6"
+)
+
+// Greenhouse gases covered by the emission factor library
+const (
+	CO2     = "CO2"
+	CH4     = "CH4"
+	N2O     = "N2O"
+	SF6     = "SF6"
+	NF3     = "NF3"
+	HFC23   = "HFC-23"
+	HFC32   = "HFC-32"
+	HFC125  = "HFC-125"
+	HFC134a = "HFC-134a"
+	HFC143a = "HFC-143a"
+	CF4     = "CF4"
+	C2F6    = "C2F6"
+)
+
+// Kyoto gas groups that inventories are broken down by, the same ones the
+// Paris agreement contract (ndcs.sol) tracks
+const (
+	HFCs = "HFCs"
+	PFCs = "PFCs"
+)
+
+// KyotoGases lists the gas groups in the order inventories report them
+var KyotoGases = []string{CO2, CH4, N2O, HFCs, PFCs, SF6, NF3}
+
+// Time horizons global warming potentials are integrated over (years)
+const (
+	Horizon20  = 20
+	Horizon100 = 100
+)
+
+// GWPSet selects the global warming potentials used to convert gases to CO2e
+type GWPSet struct {
+	Report  string // AR4, AR5 or AR6
+	Horizon int    // 20 or 100 years
+}
+
+// Global warming potentials of each gas relative to CO2, by assessment
+// report and time horizon. AR6 uses the values for methane of non-fossil
+// origin.
+var gwps = map[GWPSet]map[string]float64{
+	{AR4, Horizon100}: {
+		CO2: 1, CH4: 25, N2O: 298, SF6: 22800, NF3: 17200,
+		HFC23: 14800, HFC32: 675, HFC125: 3500, HFC134a: 1430, HFC143a: 4470,
+		CF4: 7390, C2F6: 12200,
+	},
+	{AR4, Horizon20}: {
+		CO2: 1, CH4: 72, N2O: 289, SF6: 16300, NF3: 12300,
+		HFC23: 12000, HFC32: 2330, HFC125: 6350, HFC134a: 3830, HFC143a: 5890,
+		CF4: 5210, C2F6: 8630,
+	},
+	{AR5, Horizon100}: {
+		CO2: 1, CH4: 28, N2O: 265, SF6: 23500, NF3: 16100,
+		HFC23: 12400, HFC32: 677, HFC125: 3170, HFC134a: 1300, HFC143a: 4800,
+		CF4: 6630, C2F6: 11100,
+	},
+	{AR5, Horizon20}: {
+		CO2: 1, CH4: 84, N2O: 264, SF6: 17500, NF3: 12800,
+		HFC23: 10800, HFC32: 2430, HFC125: 6090, HFC134a: 3710, HFC143a: 6940,
+		CF4: 4880, C2F6: 8210,
+	},
+	{AR6, Horizon100}: {
+		CO2: 1, CH4: 27, N2O: 273, SF6: 24300, NF3: 17400,
+		HFC23: 14600, HFC32: 771, HFC125: 3740, HFC134a: 1530, HFC143a: 5810,
+		CF4: 7380, C2F6: 12400,
+	},
+	{AR6, Horizon20}: {
+		CO2: 1, CH4: 79.7, N2O: 273, SF6: 18300, NF3: 13400,
+		HFC23: 12400, HFC32: 2690, HFC125: 6740, HFC134a: 4140, HFC143a: 7840,
+		CF4: 5300, C2F6: 8940,
+	},
+}
+
+// DefaultGWPSet returns the 100-year GWPs of the assessment report set in
+// globals.DefaultGWP
+func DefaultGWPSet() GWPSet {
+	return GWPSet{globals.DefaultGWP, Horizon100}
+}
+
+// ValidGWP checks whether global warming potentials are available for an
+// assessment report and time horizon
+func ValidGWP(set GWPSet) bool {
+	_, exists := gwps[set]
+	return exists
+}
+
+// KnownGas checks whether global warming potentials are available for a gas
+func KnownGas(gas string) bool {
+	_, exists := gwps[DefaultGWPSet()][gas]
+	return exists
+}
+
+// KyotoGroup returns the Kyoto gas group a gas belongs to
+func KyotoGroup(gas string) string {
+	switch {
+	case strings.HasPrefix(gas, "HFC-"):
+		return HFCs
+	case gas == CF4 || gas == C2F6:
+		return PFCs
+	}
+	return gas
+}
+
+// GWP returns the global warming potential of a gas
+func GWP(set GWPSet, gas string) (float64, error) {
+	values, exists := gwps[set]
+	if !exists {
+		return 0, errors.New("unknown GWP set " + set.Report + "/" + strconv.Itoa(set.Horizon) +
+			", must be AR4, AR5 or AR6 over 20 or 100 years")
+	}
+
+	value, exists := values[gas]
+	if !exists {
+		return 0, errors.New("no GWP for " + gas + " in " + set.Report)
+	}
+	return value, nil
+}
+
+// CO2e converts quantities of each gas to their CO2 equivalent
+func CO2e(gases map[string]float64, set GWPSet) (float64, error) {
+	var total float64
+	for gas, quantity := range gases {
+		gwp, err := GWP(set, gas)
+		if err != nil {
+			return 0, err
+		}
+		total += quantity * gwp
+	}
+	return total, nil
+}
@@ -0,0 +1,110 @@
+package ghg
+
+import (
+	"sort"
+
+	"github.com/YaleOpenLab/openclimate/ipfs"
+)
+
+// ConvertGases computes the CO2e of each gas quantity with the given global
+// warming potentials
+func ConvertGases(gases []ipfs.GasEmissions, set GWPSet) ([]ipfs.GasEmissions, error) {
+	converted := make([]ipfs.GasEmissions, len(gases))
+	for i, gas := range gases {
+		gwp, err := GWP(set, gas.Gas)
+		if err != nil {
+			return converted, err
+		}
+
+		gas.CO2e = gas.Quantity * gwp
+		converted[i] = gas
+	}
+	return converted, nil
+}
+
+/*
+	Inventory combines the gases reported individually, the gases emitted by
+	activities and the location-based scope 2 gases of the electricity
+	consumed (see Scope2.Gases) into the emissions of each gas by scope,
+	sorted by scope and gas.
+*/
+func Inventory(gases []ipfs.GasEmissions, activities []ipfs.Activity, scope2 []ipfs.GasEmissions, set GWPSet) ([]ipfs.GasEmissions, error) {
+	combined := append([]ipfs.GasEmissions{}, gases...)
+	for _, activity := range activities {
+		for gas, quantity := range activity.Gases {
+			combined = append(combined, ipfs.GasEmissions{Scope: activity.Scope, Gas: gas, Quantity: quantity})
+		}
+	}
+	combined = append(combined, scope2...)
+
+	return sumGases(combined, set, sameGas)
+}
+
+// sameGas groups each gas on its own
+func sameGas(gas string) string {
+	return gas
+}
+
+// Breakdown groups the emissions of each gas into the Kyoto gas groups
+// (HFCs and PFCs are reported together), converted with the given global
+// warming potentials
+func Breakdown(inventory []ipfs.GasEmissions, set GWPSet) ([]ipfs.GasEmissions, error) {
+	return sumGases(inventory, set, KyotoGroup)
+}
+
+// sumGases sums up the quantities and CO2e of gases by scope and by the key
+// group returns for each gas
+func sumGases(gases []ipfs.GasEmissions, set GWPSet, group func(string) string) ([]ipfs.GasEmissions, error) {
+	type key struct {
+		scope int
+		gas   string
+	}
+
+	var keys []key
+	sums := make(map[key]ipfs.GasEmissions)
+	for _, gas := range gases {
+		gwp, err := GWP(set, gas.Gas)
+		if err != nil {
+			return nil, err
+		}
+
+		k := key{gas.Scope, group(gas.Gas)}
+		sum, exists := sums[k]
+		if !exists {
+			keys = append(keys, k)
+			sum.Scope = k.scope
+			sum.Gas = k.gas
+		}
+		sum.Quantity += gas.Quantity
+		sum.CO2e += gas.Quantity * gwp
+		sums[k] = sum
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].scope != keys[j].scope {
+			return keys[i].scope < keys[j].scope
+		}
+		a, b := gasOrder(keys[i].gas), gasOrder(keys[j].gas)
+		if a != b {
+			return a < b
+		}
+		return keys[i].gas < keys[j].gas
+	})
+
+	result := make([]ipfs.GasEmissions, len(keys))
+	for i, k := range keys {
+		result[i] = sums[k]
+	}
+	return result, nil
+}
+
+// gasOrder returns the position of a gas' group in KyotoGases
+func gasOrder(gas string) int {
+	group := KyotoGroup(gas)
+	for i, g := range KyotoGases {
+		if g == group {
+			return i
+		}
+	}
+	return len(KyotoGases)
+}
@@ -0,0 +1,174 @@
+package ghg
+
+import (
+	"github.com/YaleOpenLab/openclimate/database"
+	"github.com/YaleOpenLab/openclimate/ipfs"
+	"github.com/pkg/errors"
+)
+
+/*
+
+	Scope 2 dual reporting following the GHG Protocol Scope 2 Guidance.
+
+	Location-based emissions multiply the electricity consumed in each grid
+	region with the grid's average emission factor, which is also broken
+	down into the gases it is made of for the gas inventory. Market-based emissions
+	follow the hierarchy of contractual instruments: consumption is first
+	covered by the energy attribute certificates the company retired for the
+	reporting year, then by the supplier-specific factor if there is one,
+	and the rest is counted at the grid region's residual mix factor.
+
+*/
+
+// Conversion factors from the certificate units to MWh
+var certificateUnits = map[string]float64{
+	"kWh": 1e-3,
+	"MWh": 1,
+	"GWh": 1e3,
+}
+
+// Scope2 is the location-based and market-based scope 2 emissions computed
+// from a climate actor's electricity consumption
+type Scope2 struct {
+	LocationBased float64 // tCO2e
+	MarketBased   float64 // tCO2e
+	Electricity   []ipfs.ElectricityUse
+
+	// Location-based emissions of each gas, with scope 2. Market-based
+	// factors of certificates, suppliers and residual mixes are only known
+	// as CO2e, so there is no market-based breakdown.
+	Gases []ipfs.GasEmissions
+
+	// MWh of retired certificates left over once all consumption is covered
+	UnusedCertificateMWh float64
+}
+
+/*
+	ComputeScope2 computes location-based and market-based scope 2 emissions
+	from the electricity consumed in each grid region (in MWh) in the given
+	year. Only retired certificates generated in that year and issued in the
+	same grid region or country as the consumption are applied, each at most
+	up to its volume. Grid emission factors are converted to CO2e with the
+	given global warming potentials.
+*/
+func ComputeScope2(uses []ipfs.ElectricityUse, certificates []database.Certificate, year int, gwp GWPSet) (Scope2, error) {
+	var result Scope2
+
+	regions, err := LoadGridRegions()
+	if err != nil {
+		return result, err
+	}
+
+	var gases []ipfs.GasEmissions
+
+	// MWh left on each certificate
+	remaining := make([]float64, len(certificates))
+	for i, cert := range certificates {
+		if cert.Status != database.CertificateRetired || cert.Year != year {
+			continue
+		}
+		factor, ok := certificateUnits[cert.Unit]
+		if !ok {
+			return result, errors.New("certificate unit must be kWh, MWh or GWh")
+		}
+		remaining[i] = cert.Volume * factor
+	}
+
+	for _, use := range uses {
+		if use.Consumption < 0 {
+			return result, errors.New("consumption cannot be negative")
+		}
+
+		var region GridRegion
+		found := false
+		for _, r := range regions {
+			if r.Name == use.GridRegion {
+				region = r
+				found = true
+			}
+		}
+		if !found {
+			return result, errors.New("grid region " + use.GridRegion + " not found")
+		}
+
+		use.LocationFactor, err = region.LocationFactor(year, gwp)
+		if err != nil {
+			return result, err
+		}
+		use.LocationBasedCO2e = use.Consumption * use.LocationFactor
+
+		c, err := Calculate(CategoryElectricity, region.Name, use.Consumption, "MWh", year, "", gwp)
+		if err != nil {
+			return result, err
+		}
+		for gas, quantity := range c.Gases {
+			gases = append(gases, ipfs.GasEmissions{Scope: 2, Gas: gas, Quantity: quantity})
+		}
+
+		use.CertificateMWh = 0
+		use.CertificateCO2e = 0
+		use.Certificates = nil
+		uncovered := use.Consumption
+		for i, cert := range certificates {
+			if uncovered <= 0 {
+				break
+			}
+			if remaining[i] <= 0 || (cert.Market != region.Name && cert.Market != region.Country) {
+				continue
+			}
+
+			applied := remaining[i]
+			if applied > uncovered {
+				applied = uncovered
+			}
+			remaining[i] -= applied
+			uncovered -= applied
+
+			use.CertificateMWh += applied
+			use.CertificateCO2e += applied * cert.EmissionFactor
+			use.Certificates = append(use.Certificates, cert.ID)
+		}
+
+		use.SupplierMWh = 0
+		use.ResidualMWh = 0
+		use.ResidualMixFactor = region.residualMix(use.LocationFactor)
+		use.MarketBasedCO2e = use.CertificateCO2e
+		if use.SupplierFactor != nil {
+			if *use.SupplierFactor < 0 {
+				return result, errors.New("supplier emission factor cannot be negative")
+			}
+			use.SupplierMWh = uncovered
+			use.MarketBasedCO2e += uncovered * *use.SupplierFactor
+		} else {
+			use.ResidualMWh = uncovered
+			use.MarketBasedCO2e += uncovered * use.ResidualMixFactor
+		}
+
+		result.LocationBased += use.LocationBasedCO2e
+		result.MarketBased += use.MarketBasedCO2e
+		result.Electricity = append(result.Electricity, use)
+	}
+
+	for _, mwh := range remaining {
+		result.UnusedCertificateMWh += mwh
+	}
+
+	result.Gases, err = sumGases(gases, gwp, sameGas)
+	return result, err
+}
+
+// ComputeActorScope2 computes scope 2 emissions for a climate actor, applying
+// the certificates it holds if it is a company
+func ComputeActorScope2(actorType string, actorID int, uses []ipfs.ElectricityUse, year int, gwp GWPSet) (Scope2, error) {
+	var certificates []database.Certificate
+	if actorType == "company" {
+		company, err := database.RetrieveCompany(actorID)
+		if err != nil {
+			var empty Scope2
+			return empty, errors.Wrap(err, "could not retrieve company")
+		}
+		certificates = company.Certificates
+	}
+
+	return ComputeScope2(uses, certificates, year, gwp)
+}
@@ -2,13 +2,77 @@ package globals
 
 import (
 	"os"
+	"time"
 )
 
 var (
-	HomeDir        = os.Getenv("HOME") + "/.openclimate"
-	DbDir          = HomeDir + "/database"
-	DbPath         = DbDir + "/openclimate.db"
-	StDataDir      = "staticdata/json_data"
-	DefaultRpcPort = 8001
-	IpfsMasterPwd  = "topsecret"
+	HomeDir             = os.Getenv("HOME") + "/.openclimate"
+	DbDir               = HomeDir + "/database"
+	DbPath              = DbDir + "/openclimate.db"
+	StDataDir           = "staticdata/json_data"
+	EarthDataDir        = "staticdata/earth_data"
+	TemperatureDataPath = "staticdata/nasa_temperature.csv"
+	GridRegionsPath     = "staticdata/ghg/grid_regions.json"
+	EmissionFactorsPath = "staticdata/ghg/emission_factors.json"
+	ScoreWeightsPath    = "staticdata/score/weights.json"
+	DefaultRpcPort      = 8001
+	ReconcileTolerance  = 0.1   // relative difference above which reported values are flagged
+	DefaultGWP          = "AR5" // assessment report used for GWPs if a report doesn't choose one
+
+	// Where data committed to IPFS and uploaded files are stored (options:
+	// ipfs, local, s3). All stores address data by the CID an IPFS node
+	// would assign to it.
+	ContentStore  = "ipfs"
+	IpfsApiUrl    = "http://localhost:5001"              // HTTP API of the IPFS node
+	LocalStoreDir = HomeDir + "/store"                   // directory of the local store
+	S3Endpoint    = os.Getenv("OPENCLIMATE_S3_ENDPOINT") // e.g. https://s3.us-east-1.amazonaws.com
+	S3Region      = os.Getenv("OPENCLIMATE_S3_REGION")
+	S3Bucket      = os.Getenv("OPENCLIMATE_S3_BUCKET")
+	S3AccessKey   = os.Getenv("OPENCLIMATE_S3_ACCESS_KEY")
+	S3SecretKey   = os.Getenv("OPENCLIMATE_S3_SECRET_KEY")
+
+	// Key the keys of entities are encrypted with. The platform doesn't
+	// start without it.
+	MasterKey = os.Getenv("OPENCLIMATE_MASTER_KEY")
+
+	// Largest file that can be uploaded, in bytes
+	MaxUploadSize int64 = 100 << 20
+
+	// How often the oracle checks that pinned data is still available (cron
+	// spec, see github.com/robfig/cron)
+	PinCheckSchedule = "@every 6h"
+
+	// Window over which the CIDs of committed data are collected before the
+	// root of their Merkle tree is anchored on chain (cron spec)
+	AnchorSchedule = "@every 1h"
+
+	// Ledger the roots are anchored on (options: ethereum, stellar, local).
+	// The local ledger only appends roots to AnchorLogPath.
+	AnchorLedger  = "ethereum"
+	AnchorLogPath = HomeDir + "/anchors.log"
+
+	// Ethereum network roots are anchored on. Transactions are signed with
+	// EthPrivateKey (hex) if it is set, otherwise with the account
+	// EthAccount (or the first account) of the keystore in EthKeystoreDir,
+	// unlocked with EthPassphrase.
+	EthRpcUrl        = "https://kovan.infura.io/v3/def7370cf49d49d791b9df949986b9a0"
+	EthChainID       = int64(42) // Kovan; 0 signs without replay protection
+	EthRootContract  = "0x1d0a334994a361111a193b98e6548bf0e8395879"
+	EthTokenContract = "0xb19ac159b87a4491b3b8bef4554b59da2bf42555"
+	EthPrivateKey    = os.Getenv("OPENCLIMATE_ETH_PRIVATE_KEY")
+	EthKeystoreDir   = "./blockchain/wallet/"
+	EthAccount       = os.Getenv("OPENCLIMATE_ETH_ACCOUNT")
+	EthPassphrase    = os.Getenv("OPENCLIMATE_ETH_PASSPHRASE")
+
+	// Blocks mined on top of a transaction before it is considered final,
+	// and how long to wait for that before giving up
+	EthConfirmations = 3
+	EthTxTimeout     = 10 * time.Minute
+
+	// Stellar roots are anchored on: the memos of payments from the account
+	// of StellarSeed to StellarAccount hold the roots, and are read back
+	// from StellarHorizonUrl
+	StellarHorizonUrl = "https://horizon-testnet.stellar.org"
+	StellarSeed       = os.Getenv("OPENCLIMATE_STELLAR_SEED")
+	StellarAccount    = os.Getenv("OPENCLIMATE_STELLAR_ACCOUNT")
 )
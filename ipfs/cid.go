@@ -0,0 +1,193 @@
+package ipfs
+
+import (
+	"crypto/sha256"
+	"math/big"
+)
+
+/*
+
+	Computes the CID IPFS assigns to data added with its default settings
+	(CIDv0, 256KiB chunks, balanced DAG of UnixFS file nodes without raw
+	leaves), so that stores that aren't backed by an IPFS node address data
+	by the same CID an IPFS node would.
+
+	The data is split into chunks, each stored in a leaf node. If there is
+	more than one chunk, the leaves are linked from internal nodes of at most
+	174 links each, layer by layer, until a single root node is left. Nodes
+	are dag-pb protobuf messages holding a UnixFS protobuf message:
+
+	PBNode { repeated PBLink Links = 2; optional bytes Data = 1; }
+	PBLink { optional bytes Hash = 1; optional string Name = 2; optional uint64 Tsize = 3; }
+	UnixFS { required Type = 1; optional bytes Data = 2; optional uint64 filesize = 3; repeated uint64 blocksizes = 4; }
+
+*/
+
+const (
+	chunkSize     = 262144
+	linksPerBlock = 174
+	unixfsFile    = 2
+)
+
+// dagNode is a node of the DAG of a file
+type dagNode struct {
+	hash     []byte // multihash
+	fileSize uint64 // bytes of the file below the node
+	tsize    uint64 // encoded size of the node and all nodes below it
+}
+
+// ComputeCID returns the CIDv0 IPFS assigns to data
+func ComputeCID(data []byte) string {
+	var b cidBuilder
+	b.Write(data)
+	return b.CID()
+}
+
+// cidBuilder computes the CID of data written to it chunk by chunk, so that
+// the CID of a stream can be computed without holding all of it in memory.
+// Only the current chunk and the hashes of the leaves are kept.
+type cidBuilder struct {
+	chunk  []byte
+	leaves []dagNode
+}
+
+func (b *cidBuilder) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		k := chunkSize - len(b.chunk)
+		if k > len(p) {
+			k = len(p)
+		}
+		b.chunk = append(b.chunk, p[:k]...)
+		p = p[k:]
+
+		if len(b.chunk) == chunkSize {
+			b.leaves = append(b.leaves, leafNode(b.chunk))
+			b.chunk = b.chunk[:0]
+		}
+	}
+	return n, nil
+}
+
+// CID returns the CID of the data written so far
+func (b *cidBuilder) CID() string {
+	leaves := b.leaves
+	if len(b.chunk) > 0 || len(leaves) == 0 {
+		leaves = append(leaves[:len(leaves):len(leaves)], leafNode(b.chunk))
+	}
+	return base58(dagRoot(leaves).hash)
+}
+
+// dagRoot links the leaves of a file into a balanced DAG and returns its root
+func dagRoot(leaves []dagNode) dagNode {
+	if len(leaves) == 1 {
+		return leaves[0]
+	}
+
+	// the balanced layout fills every internal node before starting the
+	// next, so each layer groups the nodes of the layer below in order
+	layer := leaves
+	for len(layer) > 1 {
+		var next []dagNode
+		for i := 0; i < len(layer); i += linksPerBlock {
+			end := i + linksPerBlock
+			if end > len(layer) {
+				end = len(layer)
+			}
+			next = append(next, internalNode(layer[i:end]))
+		}
+		layer = next
+	}
+	return layer[0]
+}
+
+func leafNode(chunk []byte) dagNode {
+	var unixfs []byte
+	unixfs = appendVarintField(unixfs, 1, unixfsFile)
+	if len(chunk) > 0 {
+		unixfs = appendBytesField(unixfs, 2, chunk)
+	}
+	unixfs = appendVarintField(unixfs, 3, uint64(len(chunk)))
+
+	encoded := appendBytesField(nil, 1, unixfs)
+	return newDagNode(encoded, uint64(len(chunk)), 0)
+}
+
+func internalNode(children []dagNode) dagNode {
+	var fileSize, childrenTsize uint64
+	var unixfs, encoded []byte
+
+	for _, child := range children {
+		var link []byte
+		link = appendBytesField(link, 1, child.hash)
+		link = appendBytesField(link, 2, nil)
+		link = appendVarintField(link, 3, child.tsize)
+		encoded = appendBytesField(encoded, 2, link)
+
+		fileSize += child.fileSize
+		childrenTsize += child.tsize
+	}
+
+	unixfs = appendVarintField(unixfs, 1, unixfsFile)
+	unixfs = appendVarintField(unixfs, 3, fileSize)
+	for _, child := range children {
+		unixfs = appendVarintField(unixfs, 4, child.fileSize)
+	}
+	encoded = appendBytesField(encoded, 1, unixfs)
+
+	return newDagNode(encoded, fileSize, childrenTsize)
+}
+
+func newDagNode(encoded []byte, fileSize uint64, childrenTsize uint64) dagNode {
+	var n dagNode
+	sum := sha256.Sum256(encoded)
+	n.hash = append([]byte{0x12, 0x20}, sum[:]...) // sha2-256 multihash
+	n.fileSize = fileSize
+	n.tsize = uint64(len(encoded)) + childrenTsize
+	return n
+}
+
+func appendVarint(b []byte, x uint64) []byte {
+	for x >= 0x80 {
+		b = append(b, byte(x)|0x80)
+		x >>= 7
+	}
+	return append(b, byte(x))
+}
+
+func appendVarintField(b []byte, field int, x uint64) []byte {
+	b = appendVarint(b, uint64(field<<3))
+	return appendVarint(b, x)
+}
+
+func appendBytesField(b []byte, field int, x []byte) []byte {
+	b = appendVarint(b, uint64(field<<3|2))
+	b = appendVarint(b, uint64(len(x)))
+	return append(b, x...)
+}
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// base58 encodes bytes with the bitcoin alphabet IPFS uses for CIDv0
+func base58(b []byte) string {
+	x := new(big.Int).SetBytes(b)
+	base := big.NewInt(58)
+	mod := new(big.Int)
+
+	var out []byte
+	for x.Sign() > 0 {
+		x.DivMod(x, base, mod)
+		out = append(out, base58Alphabet[mod.Int64()])
+	}
+	for _, c := range b {
+		if c != 0 {
+			break
+		}
+		out = append(out, base58Alphabet[0])
+	}
+
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return string(out)
+}
@@ -0,0 +1,43 @@
+package ipfs
+
+import (
+	"bytes"
+	"testing"
+)
+
+// CIDs assigned by `ipfs add` with its default settings
+func TestComputeCID(t *testing.T) {
+	vectors := []struct {
+		data string
+		cid  string
+	}{
+		{"", "QmbFMke1KXqnYyBBWxB74N4c5SBnJMVAiMNRcGu6x1AwQH"},
+		{"hello world\n", "QmT78zSuBmuS4z925WZfrqQ1qHaJ56DQaTfyMUF7F8ff5o"},
+	}
+
+	for _, v := range vectors {
+		if cid := ComputeCID([]byte(v.data)); cid != v.cid {
+			t.Errorf("ComputeCID(%q) = %s, want %s", v.data, cid, v.cid)
+		}
+	}
+}
+
+// Data written in pieces of any size has the CID of the whole data
+func TestCIDBuilderChunks(t *testing.T) {
+	data := bytes.Repeat([]byte("openclimate"), 3*chunkSize/11)
+	want := ComputeCID(data)
+
+	for _, size := range []int{1000, chunkSize - 1, chunkSize, chunkSize + 1} {
+		var b cidBuilder
+		for i := 0; i < len(data); i += size {
+			end := i + size
+			if end > len(data) {
+				end = len(data)
+			}
+			b.Write(data[i:end])
+		}
+		if cid := b.CID(); cid != want {
+			t.Errorf("writes of %d bytes: CID = %s, want %s", size, cid, want)
+		}
+	}
+}
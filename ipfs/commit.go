@@ -2,16 +2,39 @@ package ipfs
 
 import (
 	"encoding/json"
-	"github.com/Varunram/essentials/ipfs"
+	"github.com/pkg/errors"
 	"log"
 )
 
-// Commits data to IPFS and returns IPFS hash
-func IpfsCommitData(data interface{}) (string, error) {
+// Commits data to the content store, records its hash in the content index under the
+// actor, report type and period it belongs to, pins it and returns the hash
+func IpfsCommitData(reportType string, actorType string, actorID int, period string, data interface{}) (string, error) {
 	dataBytes, err := json.Marshal(data)
 	if err != nil {
 		log.Println(err)
 		return "", err
 	}
-	return ipfs.IpfsAddBytes(dataBytes)
+
+	hash, err := Put(dataBytes)
+	if err != nil {
+		return "", err
+	}
+
+	var x IndexEntry
+	x.ActorType = actorType
+	x.ActorID = actorID
+	x.ReportType = reportType
+	x.Period = period
+	x.Hash = hash
+
+	_, err = addIndexEntry(x)
+	if err != nil {
+		return hash, errors.Wrap(err, "could not index ipfs hash")
+	}
+
+	_, err = TrackPin(hash, actorType, actorID, reportType)
+	if err != nil {
+		return hash, errors.Wrap(err, "could not track pin")
+	}
+	return hash, nil
 }
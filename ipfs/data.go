@@ -25,10 +25,49 @@ type Emissions struct {
 
 	DateReported string
 
+	// Unit the emissions are reported in (options: tCO2e, ktCO2e, MtCO2e).
+	// Reports are normalized to tCO2e when they are ingested.
+	Unit string
+
 	TotalScope1CO2e int
 	TotalScope2CO2e int
 	TotalScope3CO2e int
 
+	// Scope 2 emissions are reported both location-based (TotalScope2CO2e)
+	// and market-based, following the GHG Protocol Scope 2 Guidance. If
+	// Electricity is reported, both are computed from it when the report is
+	// ingested. Otherwise the market-based total defaults to the
+	// location-based one.
+	TotalScope2MarketCO2e int
+
+	// Unit the electricity consumption is reported in (options: kWh, MWh, GWh).
+	// Reports are normalized to MWh when they are ingested.
+	EnergyUnit  string
+	Electricity []ElectricityUse
+
+	// Activity data (fuel burned, distance travelled, refrigerant leaked)
+	// is converted to emissions with the emission factor library when the
+	// report is ingested, and added to the pre-computed scope totals. The
+	// totals should only cover emissions not reported as activity data.
+	Activities []Activity
+
+	// Emissions of individual gases (e.g. CH4 from a landfill, SF6 leaked
+	// from switchgear) are converted to CO2e when the report is ingested, and
+	// added to the pre-computed scope totals like activity data.
+	Gases []GasEmissions
+
+	// Emissions of each gas by scope, combining Gases, the gases emitted by
+	// Activities and the location-based scope 2 gases of Electricity.
+	// Computed when the report is ingested.
+	Inventory []GasEmissions
+
+	// Assessment report the global warming potentials used to convert gases
+	// to CO2e are taken from (options: AR4, AR5, AR6). Defaults to AR5.
+	GWP string
+	// Time horizon of the global warming potentials in years (options: 20,
+	// 100). Defaults to 100.
+	GWPHorizon int
+
 	// Where is the report and its data from?
 	// (options: internally conducted report, consulting group, etc.)
 	Source string
@@ -41,17 +80,74 @@ type Emissions struct {
 	// Country children: regions
 	// Region children: companies & cities
 	// Company children: assets
-	ByChild []struct {
-		ChildID    int
-		ChildName  string
-		Scope1CO2e float64
-		Scope2CO2e float64
-		Scope3CO2e float64
-
-		// // "verified" represents if the data is sufficiently reviewed
-		// // and confirmed/corroborated (from oracle, third-party auditor, etc)
-		// Verified string
-	}
+	ByChild []EmissionsChild
+}
+
+type EmissionsChild struct {
+	ChildID    int
+	ChildName  string
+	Scope1CO2e float64
+	Scope2CO2e float64
+	Scope3CO2e float64
+
+	// // "verified" represents if the data is sufficiently reviewed
+	// // and confirmed/corroborated (from oracle, third-party auditor, etc)
+	// Verified string
+}
+
+// ElectricityUse is the electricity consumed in a grid region and the
+// scope 2 emissions attributed to it. Reporters fill in the consumption
+// (and the supplier's emission factor if known), the remaining fields are
+// computed when the report is ingested.
+type ElectricityUse struct {
+	GridRegion  string
+	Consumption float64
+
+	// Emission factor of the electricity supplied (tCO2e/MWh), if the
+	// supplier discloses one
+	SupplierFactor *float64 `json:",omitempty"`
+
+	// Location-based: all consumption at the grid average factor
+	LocationFactor    float64
+	LocationBasedCO2e float64
+
+	// Market-based: consumption covered by energy attribute certificates at
+	// the certificates' factor, then by the supplier's factor, and the rest
+	// at the residual mix factor of the grid region
+	CertificateMWh    float64
+	CertificateCO2e   float64
+	Certificates      []int // IDs of the certificates applied
+	SupplierMWh       float64
+	ResidualMWh       float64
+	ResidualMixFactor float64
+	MarketBasedCO2e   float64
+}
+
+// Activity is a quantity of an emitting activity reported in an emissions
+// report. Reporters fill in the activity and its quantity, the remaining
+// fields are computed when the report is ingested.
+type Activity struct {
+	Scope    int    // 1 or 3, electricity is reported in Electricity
+	Category string // fuel, transport or refrigerant
+	Activity string // e.g. diesel, natural gas, passenger car, R-410A
+	Quantity float64
+	Unit     string // e.g. litre, gallon, m3, km, mile, kg
+
+	// Source of the emission factor to use (e.g. EPA GHG Emission Factors
+	// Hub). The latest factor for the reporting year is used if not set.
+	FactorSource string `json:",omitempty"`
+
+	FactorID string
+	Gases    map[string]float64 // tonnes of each gas
+	CO2e     float64            // tCO2e
+}
+
+// GasEmissions is the quantity of a greenhouse gas emitted in a scope
+type GasEmissions struct {
+	Scope    int     // 1 or 3, or 2 in the computed inventory
+	Gas      string  // e.g. CO2, CH4, N2O, SF6, NF3, HFC-134a or CF4
+	Quantity float64 // tonnes
+	CO2e     float64 // tCO2e, computed when the report is ingested
 }
 
 /***************************/
@@ -72,30 +168,44 @@ type Mitigation struct {
 	// verification of the mitigation data?
 	Methodology string
 
-	// Emissions data (by asset)
+	DateReported string
+
+	// Units the carbon offsets (options: tCO2e, ktCO2e, MtCO2e) and energy
+	// figures (options: kWh, MWh, GWh) are reported in. Reports are normalized
+	// to tCO2e and MWh when they are ingested.
+	Unit       string
+	EnergyUnit string
+
+	TotalCarbonOffset float64
+	TotalEnergySaved  float64
+	TotalEnergyGen    float64
+
+	// Mitigation data (by asset)
 	// Country children: regions
 	// Region children: companies & cities
 	// Company children: assets
-	ByChild []struct {
-		ChildID      int
-		ChildName    string
-		CarbonOffset float64
-		EnergySaved  float64
-		EnergyGen    float64
-
-		// Options:
-		// - Renewable energy
-		// - Energy efficiency
-		// - Agriculture, Forestry & Other
-		// - Carbon sequestrations
-		Type string
-
-		// Options:
-		// - Reduction of GHG sources
-		// - Enhancement of GHG sinks
-		// - Both
-		Category string
-	}
+	ByChild []MitigationChild
+}
+
+type MitigationChild struct {
+	ChildID      int
+	ChildName    string
+	CarbonOffset float64
+	EnergySaved  float64
+	EnergyGen    float64
+
+	// Options:
+	// - Renewable energy
+	// - Energy efficiency
+	// - Agriculture, Forestry & Other
+	// - Carbon sequestrations
+	Type string
+
+	// Options:
+	// - Reduction of GHG sources
+	// - Enhancement of GHG sinks
+	// - Both
+	Category string
 }
 
 /***************************/
@@ -103,9 +213,74 @@ type Mitigation struct {
 /***************************/
 
 type Adaptation struct {
+	// Meta-data
+	UserID     int
+	EntityType string
+	Year       int
+
+	DateReported string
+
+	// Where is the report and its data from?
+	// (options: internally conducted report, consulting group, etc.)
+	Source string
+
+	// what methodology was used in the reporting and
+	// verification of the adaptation data?
+	Methodology string
+
+	// ISO 4217 code of the currency investments are reported in
+	Currency string
+
+	TotalInvestment    float64
+	TotalBeneficiaries int
+
+	// Adaptation data (by action)
+	// Country children: regions
+	// Region children: companies & cities
+	// Company children: assets
+	ByChild []AdaptationChild
 }
 
 type AdaptationChild struct {
+	ChildID   int
+	ChildName string
+
+	// Name and description of the adaptation action
+	Action      string
+	Description string
+
+	// Options:
+	// - Flooding
+	// - Sea level rise
+	// - Extreme heat
+	// - Drought
+	// - Wildfire
+	// - Storms
+	// - Water scarcity
+	// - Other
+	HazardType string
+
+	// number of people exposed to the hazard in the area the action covers
+	ExposedPopulation int
+
+	// number of people who benefit from the action
+	Beneficiaries int
+
+	// amount invested in the action during the reporting year
+	Investment float64
+
+	ResilienceIndicators []ResilienceIndicator
+}
+
+// ResilienceIndicator tracks a measure of resilience to a hazard (e.g.
+// "homes in the 100 year floodplain", "heat related hospital admissions")
+// against its value before the adaptation action started
+type ResilienceIndicator struct {
+	Name     string
+	Unit     string
+	Baseline float64
+	Value    float64
+	Target   float64
 }
 
 /**********************/
@@ -0,0 +1,76 @@
+package ipfs
+
+import (
+	"encoding/json"
+	"sort"
+
+	edb "github.com/Varunram/essentials/database"
+	"github.com/Varunram/essentials/utils"
+	globals "github.com/YaleOpenLab/openclimate/globals"
+	"github.com/pkg/errors"
+)
+
+/*
+
+	The content index keeps track of everything committed to IPFS. Every
+	call to IpfsCommitData adds an entry mapping the actor the data belongs
+	to, the type of data and the period it covers to the IPFS hash of the
+	data, so that an actor's data can be found and retrieved again without
+	scanning the chain.
+
+	The index is stored in the platform's database (see database/database.go
+	for the buckets it creates).
+
+*/
+
+// IndexBucket is the database bucket the content index is stored in
+var IndexBucket = []byte("IpfsIndex")
+
+// IndexEntry maps a piece of data committed to IPFS to its hash
+type IndexEntry struct {
+	Index      int
+	ActorType  string
+	ActorID    int
+	ReportType string // e.g. Emissions, Mitigation, Adaptation, Atmospheric CO2
+	Period     string // the period the data covers (e.g. 2018), empty if it doesn't cover one
+	Hash       string
+	Date       string // when the data was committed
+}
+
+// addIndexEntry stores an entry in the content index
+func addIndexEntry(x IndexEntry) (IndexEntry, error) {
+	x.Index = 0
+	x.Date = utils.Timestamp()
+	err := save(IndexBucket, &x)
+	return x, err
+}
+
+// RetrieveIndex returns the index entries of an actor, oldest first. An empty
+// reportType matches data of all types.
+func RetrieveIndex(reportType string, actorType string, actorID int) ([]IndexEntry, error) {
+	var arr []IndexEntry
+	keys, err := edb.RetrieveAllKeys(globals.DbPath, IndexBucket)
+	if err != nil {
+		return arr, errors.Wrap(err, "error while retrieving the content index")
+	}
+
+	for _, val := range keys {
+		var x IndexEntry
+		err = json.Unmarshal(val, &x)
+		if err != nil {
+			return arr, errors.Wrap(err, "could not unmarshal json")
+		}
+		if x.ActorType != actorType || x.ActorID != actorID {
+			continue
+		}
+		if reportType != "" && x.ReportType != reportType {
+			continue
+		}
+		arr = append(arr, x)
+	}
+
+	sort.Slice(arr, func(i, j int) bool {
+		return arr[i].Index < arr[j].Index
+	})
+	return arr, nil
+}
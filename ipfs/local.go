@@ -0,0 +1,126 @@
+package ipfs
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// LocalStore stores data as files named after their CID in a directory. It
+// needs no IPFS node, which makes it suited for development and tests. Data
+// is never removed, so all of it counts as pinned.
+type LocalStore struct {
+	Dir string
+}
+
+// NewLocalStore returns a store in dir, creating the directory if needed
+func NewLocalStore(dir string) (*LocalStore, error) {
+	err := os.MkdirAll(dir, os.ModePerm)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create store directory")
+	}
+	return &LocalStore{Dir: dir}, nil
+}
+
+func (s *LocalStore) path(cid string) (string, error) {
+	if cid == "" || filepath.Base(cid) != cid {
+		return "", errors.New("invalid cid " + cid)
+	}
+	return filepath.Join(s.Dir, cid), nil
+}
+
+// Put writes data to the file named after its CID
+func (s *LocalStore) Put(data []byte) (string, error) {
+	return s.PutStream(bytes.NewReader(data))
+}
+
+// PutStream writes the data read from r to the file named after its CID
+func (s *LocalStore) PutStream(r io.Reader) (string, error) {
+	// write to a temporary file first so that readers never see partial
+	// data, and since the CID is only known once all data is read
+	tmp, err := ioutil.TempFile(s.Dir, ".put-")
+	if err != nil {
+		return "", errors.Wrap(err, "could not create file")
+	}
+	defer os.Remove(tmp.Name())
+
+	var b cidBuilder
+	_, err = io.Copy(io.MultiWriter(tmp, &b), r)
+	closeErr := tmp.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", errors.Wrap(err, "could not write file")
+	}
+
+	cid := b.CID()
+	path, err := s.path(cid)
+	if err != nil {
+		return "", err
+	}
+
+	err = os.Rename(tmp.Name(), path)
+	if err != nil {
+		return "", errors.Wrap(err, "could not write file")
+	}
+	return cid, nil
+}
+
+// Get reads the file named after a CID
+func (s *LocalStore) Get(cid string) ([]byte, error) {
+	path, err := s.path(cid)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not retrieve "+cid)
+	}
+	return data, nil
+}
+
+// GetStream opens the file named after a CID
+func (s *LocalStore) GetStream(cid string) (io.ReadCloser, error) {
+	path, err := s.path(cid)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not retrieve "+cid)
+	}
+	return file, nil
+}
+
+// Pin checks that the data is stored, since data is never removed
+func (s *LocalStore) Pin(cid string) error {
+	has, err := s.Has(cid)
+	if err != nil {
+		return err
+	}
+	if !has {
+		return errors.New("can't pin missing " + cid)
+	}
+	return nil
+}
+
+// Has checks that the file named after a CID exists
+func (s *LocalStore) Has(cid string) (bool, error) {
+	path, err := s.path(cid)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = os.Stat(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
@@ -0,0 +1,64 @@
+package ipfs
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLocalStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "openclimate-store-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := NewLocalStore(filepath.Join(dir, "store"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := []byte("hello world\n")
+	cid, err := store.Put(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cid != ComputeCID(data) {
+		t.Errorf("Put returned %s, want %s", cid, ComputeCID(data))
+	}
+
+	has, err := store.Has(cid)
+	if err != nil || !has {
+		t.Errorf("Has(%s) = %v, %v, want true", cid, has, err)
+	}
+	if err = store.Pin(cid); err != nil {
+		t.Errorf("Pin(%s): %v", cid, err)
+	}
+
+	stored, err := store.Get(cid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(stored, data) {
+		t.Errorf("Get(%s) = %q, want %q", cid, stored, data)
+	}
+
+	missing := ComputeCID([]byte("missing"))
+	has, err = store.Has(missing)
+	if err != nil || has {
+		t.Errorf("Has(%s) = %v, %v, want false", missing, has, err)
+	}
+	if _, err = store.Get(missing); err == nil {
+		t.Errorf("Get(%s) succeeded for missing data", missing)
+	}
+	if err = store.Pin(missing); err == nil {
+		t.Errorf("Pin(%s) succeeded for missing data", missing)
+	}
+
+	// CIDs can't point outside the store
+	if _, err = store.Get("../" + cid); err == nil {
+		t.Error("Get accepted a path outside the store")
+	}
+}
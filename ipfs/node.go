@@ -0,0 +1,165 @@
+package ipfs
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// NodeStore stores data on an IPFS node through its HTTP API
+type NodeStore struct {
+	ApiUrl string // e.g. http://localhost:5001
+	Client *http.Client
+}
+
+// NewNodeStore returns a store backed by the IPFS node at apiUrl
+func NewNodeStore(apiUrl string) *NodeStore {
+	return &NodeStore{
+		ApiUrl: strings.TrimSuffix(apiUrl, "/"),
+		Client: &http.Client{Timeout: 2 * time.Minute},
+	}
+}
+
+// nodeError is an error the IPFS node responded with, as opposed to an error
+// reaching the node
+type nodeError string
+
+func (e nodeError) Error() string {
+	return string(e)
+}
+
+// request posts a request to an endpoint of the IPFS HTTP API (all endpoints
+// take POST requests) and returns the response, whose body the caller has to
+// close
+func (s *NodeStore) request(endpoint string, args url.Values, body io.Reader, contentType string) (*http.Response, error) {
+	u := s.ApiUrl + "/api/v0/" + endpoint + "?" + args.Encode()
+	if body == nil {
+		body = http.NoBody
+	}
+
+	req, err := http.NewRequest("POST", u, body)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create request")
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not reach ipfs node")
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		data, _ := ioutil.ReadAll(resp.Body)
+		var x struct{ Message string }
+		json.Unmarshal(data, &x)
+		return nil, nodeError("ipfs " + endpoint + " failed: " + resp.Status + " " + x.Message)
+	}
+	return resp, nil
+}
+
+// call posts a request to an endpoint of the IPFS HTTP API and returns the
+// response body
+func (s *NodeStore) call(endpoint string, args url.Values, body io.Reader, contentType string) ([]byte, error) {
+	resp, err := s.request(endpoint, args, body, contentType)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read ipfs response")
+	}
+	return data, nil
+}
+
+// Put adds data to the node with the default settings, so that its CID is
+// the one ComputeCID returns. Data isn't pinned until Pin is called.
+func (s *NodeStore) Put(data []byte) (string, error) {
+	return s.PutStream(bytes.NewReader(data))
+}
+
+// PutStream adds the data read from r to the node, see Put
+func (s *NodeStore) PutStream(r io.Reader) (string, error) {
+	// the multipart body is written as the node reads it
+	pr, pw := io.Pipe()
+	defer pr.Close()
+	writer := multipart.NewWriter(pw)
+	go func() {
+		part, err := writer.CreateFormFile("file", "data")
+		if err == nil {
+			_, err = io.Copy(part, r)
+		}
+		if err == nil {
+			err = writer.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+
+	args := url.Values{}
+	args.Set("pin", "false")
+	args.Set("cid-version", "0")
+
+	resp, err := s.call("add", args, pr, writer.FormDataContentType())
+	if err != nil {
+		return "", err
+	}
+
+	var x struct{ Hash string }
+	err = json.Unmarshal(resp, &x)
+	if err != nil || x.Hash == "" {
+		return "", errors.New("could not parse ipfs add response")
+	}
+	return x.Hash, nil
+}
+
+// Get retrieves data from the node, which fetches it from the network if
+// it doesn't have it
+func (s *NodeStore) Get(cid string) ([]byte, error) {
+	args := url.Values{}
+	args.Set("arg", cid)
+	return s.call("cat", args, nil, "")
+}
+
+// GetStream returns a reader of data on the node, see Get
+func (s *NodeStore) GetStream(cid string) (io.ReadCloser, error) {
+	args := url.Values{}
+	args.Set("arg", cid)
+	resp, err := s.request("cat", args, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// Pin pins data recursively on the node, so that it isn't garbage collected
+func (s *NodeStore) Pin(cid string) error {
+	args := url.Values{}
+	args.Set("arg", cid)
+	_, err := s.call("pin/add", args, nil, "")
+	return err
+}
+
+// Has checks whether the node has all blocks of the data locally
+func (s *NodeStore) Has(cid string) (bool, error) {
+	args := url.Values{}
+	args.Set("arg", cid)
+	args.Set("offline", "true")
+	args.Set("timeout", "10s")
+	_, err := s.call("dag/stat", args, nil, "")
+	if _, ok := err.(nodeError); ok {
+		return false, nil
+	}
+	return err == nil, err
+}
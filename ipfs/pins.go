@@ -0,0 +1,200 @@
+package ipfs
+
+import (
+	"encoding/json"
+
+	edb "github.com/Varunram/essentials/database"
+	"github.com/Varunram/essentials/utils"
+	globals "github.com/YaleOpenLab/openclimate/globals"
+	"github.com/pkg/errors"
+)
+
+/*
+
+	The pin manager keeps track of every CID the platform depends on (data
+	committed with IpfsCommitData and uploaded files), so that the data isn't
+	garbage collected by the store it is kept in. Each CID is pinned when it
+	is tracked, and VerifyPins, which the oracle runs on a schedule, checks
+	that the data is still available and pins it again if it was lost.
+
+*/
+
+// PinBucket is the database bucket tracked pins are stored in
+var PinBucket = []byte("Pins")
+
+// Status of a pin
+const (
+	PinPinned = "pinned"
+	PinFailed = "failed" // pinning failed, retried at the next check
+	PinLost   = "lost"   // the data is no longer available and couldn't be pinned again
+)
+
+// PinFile is the kind of pins of uploaded files. Pins of data committed with
+// IpfsCommitData have the report type of the data as their kind.
+const PinFile = "file"
+
+// Pin is a CID the platform depends on
+type Pin struct {
+	Index     int
+	Hash      string
+	ActorType string
+	ActorID   int
+	Kind      string
+
+	Status string
+	Error  string // why the last attempt to pin or check the data failed
+	Repins int    // the number of times the data was lost and pinned again
+
+	Created     string
+	LastPinned  string
+	LastChecked string
+}
+
+// PinSummary is the status of the pins of an actor
+type PinSummary struct {
+	Pinned int
+	Failed int
+	Lost   int
+	Pins   []Pin
+}
+
+// TrackPin records that the platform depends on the data stored under a hash
+// and pins it. Tracking a hash an actor already depends on pins it again. A
+// failure to pin is recorded in the pin's status rather than returned, and
+// retried at the next check.
+func TrackPin(hash string, actorType string, actorID int, kind string) (Pin, error) {
+	var p Pin
+	pins, err := RetrieveAllPins()
+	if err != nil {
+		return p, err
+	}
+
+	p.Created = utils.Timestamp()
+	for _, x := range pins {
+		if x.Hash == hash && x.ActorType == actorType && x.ActorID == actorID {
+			p = x
+			break
+		}
+	}
+	p.Hash = hash
+	p.ActorType = actorType
+	p.ActorID = actorID
+	p.Kind = kind
+
+	store, err := NewStore()
+	if err != nil {
+		return p, err
+	}
+
+	p.pin(store)
+	err = save(PinBucket, &p)
+	return p, err
+}
+
+// pin pins the data and records the outcome
+func (p *Pin) pin(store ContentStore) {
+	err := store.Pin(p.Hash)
+	if err != nil {
+		p.Status = PinFailed
+		p.Error = err.Error()
+		return
+	}
+
+	p.Status = PinPinned
+	p.Error = ""
+	p.LastPinned = utils.Timestamp()
+}
+
+// VerifyPins checks that the data of every tracked pin is still available,
+// pins the data that was lost or couldn't be pinned before again, and returns
+// the pins that are still not pinned. It stops without changing any pin if
+// the store can't be reached.
+func VerifyPins() ([]Pin, error) {
+	var unpinned []Pin
+	pins, err := RetrieveAllPins()
+	if err != nil {
+		return unpinned, err
+	}
+
+	store, err := NewStore()
+	if err != nil {
+		return unpinned, err
+	}
+
+	for _, p := range pins {
+		has, err := store.Has(p.Hash)
+		if err != nil {
+			return unpinned, errors.Wrap(err, "could not check pins")
+		}
+
+		p.LastChecked = utils.Timestamp()
+		switch {
+		case has && p.Status == PinPinned:
+		case has:
+			p.pin(store)
+		default:
+			// the data is gone from the store, pinning it fetches it again
+			// if it is still available elsewhere
+			p.pin(store)
+			if p.Status == PinPinned {
+				p.Repins++
+			} else {
+				p.Status = PinLost
+			}
+		}
+
+		err = save(PinBucket, &p)
+		if err != nil {
+			return unpinned, err
+		}
+		if p.Status != PinPinned {
+			unpinned = append(unpinned, p)
+		}
+	}
+	return unpinned, nil
+}
+
+// RetrieveAllPins gets a list of all tracked pins
+func RetrieveAllPins() ([]Pin, error) {
+	var arr []Pin
+	keys, err := edb.RetrieveAllKeys(globals.DbPath, PinBucket)
+	if err != nil {
+		return arr, errors.Wrap(err, "error while retrieving all pins")
+	}
+
+	for _, val := range keys {
+		var p Pin
+		err = json.Unmarshal(val, &p)
+		if err != nil {
+			return arr, errors.Wrap(err, "could not unmarshal json")
+		}
+		arr = append(arr, p)
+	}
+	return arr, nil
+}
+
+// SummarizePins returns the status of the pins of an actor
+func SummarizePins(actorType string, actorID int) (PinSummary, error) {
+	var s PinSummary
+	pins, err := RetrieveAllPins()
+	if err != nil {
+		return s, err
+	}
+
+	for _, p := range pins {
+		if p.ActorType != actorType || p.ActorID != actorID {
+			continue
+		}
+
+		switch p.Status {
+		case PinPinned:
+			s.Pinned++
+		case PinFailed:
+			s.Failed++
+		case PinLost:
+			s.Lost++
+		}
+		s.Pins = append(s.Pins, p)
+	}
+	return s, nil
+}
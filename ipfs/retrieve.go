@@ -1,32 +1,194 @@
 package ipfs
 
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// Earth data is committed by the oracle as data of a single actor
+const (
+	EarthActorType = "Earth"
+	EarthActorID   = 1
+
+	EarthCO2  = "Atmospheric CO2"
+	EarthTemp = "Global Temperature"
+)
+
+// Record is data committed to IPFS, with the index entry that points to it
+type Record struct {
+	IndexEntry
+	Data interface{}
+}
+
 /*
 
-	GetFromIpfs() is not a real function yet. The function will receive the
-	actor type and the actor id, then search our smart contract for all the
-	IPFS hashes that are associated with that actor type and actor id. The
-	function will then retrieve the corresponding data from IPFS using those
-	hash content addresses and return it to us here.
+	GetFromIpfs() looks up all the IPFS hashes that are associated with the
+	given report type, actor type and actor id in the content index, then
+	retrieves the corresponding data from IPFS and decodes it.
 
 	arguments:
+	- "reportType": the type of the data (Emissions, Mitigation, Adaptation, etc.)
 	- "actorType": the type of the actor (company, city, region, country, etc.)
 	- "actorID": the ID assigned to the actor in the database
 
 	return type:
-	A map that maps the date the data was reported to the data itself.
+	A map that maps the period the data was reported for (e.g. 2018), or the
+	date it was committed if it doesn't cover a period, to the records of
+	the data, oldest first. A report can be committed more than once for a
+	period, and reports of different types for the same period, so each key
+	holds a slice. Emissions, mitigation and adaptation reports are decoded
+	into Emissions, Mitigation and Adaptation structs, other data into plain
+	JSON values.
 */
+func GetFromIpfs(reportType string, actorType string, actorID int) (map[string][]Record, error) {
+	data := make(map[string][]Record)
 
-func GetFromIpfs(reportType string, actorType string, actorID int) (map[string]interface{}, error) {
-	var empty map[string]interface{}
-	return empty, nil
+	entries, err := RetrieveIndex(reportType, actorType, actorID)
+	if err != nil {
+		return data, err
+	}
+
+	for _, entry := range entries {
+		x, err := retrieveEntry(entry)
+		if err != nil {
+			return data, err
+		}
+
+		key := entry.Period
+		if key == "" {
+			key = entry.Date
+		}
+		data[key] = append(data[key], Record{IndexEntry: entry, Data: x})
+	}
+	return data, nil
+}
+
+// GetAllFromIpfs retrieves all the data of an actor committed to IPFS, of
+// any report type
+func GetAllFromIpfs(actorType string, actorID int) (map[string][]Record, error) {
+	return GetFromIpfs("", actorType, actorID)
 }
 
-func GetAllFromIpfs(actorType string, actorID int) (map[string]interface{}, error) {
-	var empty map[string]interface{}
-	return empty, nil
+// earthMeasurement holds the fields of the earth data the oracle commits
+// (see GlobalCO2 and GlobalTemp in oracle/earth.go) that are needed here
+type earthMeasurement struct {
+	Location string
+	Year     int
+	Month    int
+	Day      int
+	Cycle    float64
 }
 
+// GetFromIpfsEarthData retrieves the atmospheric CO2 and global temperature
+// measurements committed by the oracle, by the date they were measured
+// (YYYY-MM-DD, or YYYY-MM for monthly measurements). Measurements from
+// different locations on the same date are averaged, and a measurement that
+// was committed more than once counts once with its latest value.
 func GetFromIpfsEarthData() (map[string]Earth, error) {
-	var empty map[string]Earth
-	return empty, nil
+	data := make(map[string]Earth)
+
+	entries, err := RetrieveIndex("", EarthActorType, EarthActorID)
+	if err != nil {
+		return data, err
+	}
+
+	// latest value by report type, date and location
+	values := make(map[string]map[string]map[string]float64)
+	for _, entry := range entries {
+		if entry.ReportType != EarthCO2 && entry.ReportType != EarthTemp {
+			continue
+		}
+
+		dataBytes, err := fetch(entry.Hash)
+		if err != nil {
+			return data, err
+		}
+
+		var measurements []earthMeasurement
+		err = json.Unmarshal(dataBytes, &measurements)
+		if err != nil {
+			return data, errors.Wrap(err, "could not decode earth data "+entry.Hash)
+		}
+
+		if values[entry.ReportType] == nil {
+			values[entry.ReportType] = make(map[string]map[string]float64)
+		}
+		for _, m := range measurements {
+			date := fmt.Sprintf("%04d-%02d-%02d", m.Year, m.Month, m.Day)
+			if m.Day == 0 {
+				date = fmt.Sprintf("%04d-%02d", m.Year, m.Month)
+			}
+			if values[entry.ReportType][date] == nil {
+				values[entry.ReportType][date] = make(map[string]float64)
+			}
+			values[entry.ReportType][date][m.Location] = m.Cycle
+		}
+	}
+
+	for reportType, byDate := range values {
+		for date, byLocation := range byDate {
+			var sum float64
+			for _, value := range byLocation {
+				sum += value
+			}
+
+			x := data[date]
+			switch reportType {
+			case EarthCO2:
+				x.AtmosCO2 = sum / float64(len(byLocation))
+			case EarthTemp:
+				x.GlobalTemp = sum / float64(len(byLocation))
+			}
+			data[date] = x
+		}
+	}
+	return data, nil
+}
+
+// retrieveEntry retrieves the data an index entry points to and decodes it
+// into the struct of its report type
+func retrieveEntry(entry IndexEntry) (interface{}, error) {
+	dataBytes, err := fetch(entry.Hash)
+	if err != nil {
+		return nil, err
+	}
+
+	var x interface{}
+	switch entry.ReportType {
+	case "Emissions":
+		x = new(Emissions)
+	case "Mitigation":
+		x = new(Mitigation)
+	case "Adaptation":
+		x = new(Adaptation)
+	default:
+		x = new(interface{})
+	}
+
+	err = json.Unmarshal(dataBytes, x)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not decode "+entry.ReportType+" data "+entry.Hash)
+	}
+
+	switch y := x.(type) {
+	case *Emissions:
+		return *y, nil
+	case *Mitigation:
+		return *y, nil
+	case *Adaptation:
+		return *y, nil
+	default:
+		return *x.(*interface{}), nil
+	}
+}
+
+// fetch retrieves the data stored under an IPFS hash from the content store
+func fetch(hash string) ([]byte, error) {
+	data, err := Get(hash)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not retrieve "+hash)
+	}
+	return data, nil
 }
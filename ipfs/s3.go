@@ -0,0 +1,238 @@
+package ipfs
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// S3Store stores data as objects named after their CID in a bucket of an
+// S3-compatible object store (AWS S3, MinIO, etc.). Requests are signed with
+// AWS signature version 4 and use path-style URLs. Objects are never
+// removed, so all of them count as pinned.
+type S3Store struct {
+	Endpoint  string // e.g. https://s3.us-east-1.amazonaws.com
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	Client    *http.Client
+}
+
+// NewS3Store returns a store backed by a bucket of an S3-compatible store
+func NewS3Store(endpoint string, region string, bucket string, accessKey string, secretKey string) (*S3Store, error) {
+	if endpoint == "" || bucket == "" {
+		return nil, errors.New("s3 endpoint and bucket must be set")
+	}
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	return &S3Store{
+		Endpoint:  strings.TrimSuffix(endpoint, "/"),
+		Region:    region,
+		Bucket:    bucket,
+		AccessKey: accessKey,
+		SecretKey: secretKey,
+		Client:    &http.Client{Timeout: 2 * time.Minute},
+	}, nil
+}
+
+// do sends a signed request for the object named after a CID. The SHA-256
+// of the body has to be known in advance to sign the request.
+func (s *S3Store) do(method string, cid string, body io.Reader, size int64, payloadHash string) (*http.Response, error) {
+	if body == nil {
+		body = http.NoBody
+	}
+
+	req, err := http.NewRequest(method, s.Endpoint+"/"+s.Bucket+"/"+cid, body)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create request")
+	}
+	req.ContentLength = size
+	s.sign(req, payloadHash, time.Now().UTC())
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not reach s3 store")
+	}
+	return resp, nil
+}
+
+// sign adds the headers of AWS signature version 4 to a request
+func (s *S3Store) sign(req *http.Request, payloadHash string, t time.Time) {
+	amzDate := t.Format("20060102T150405Z")
+	date := t.Format("20060102")
+
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		"host:" + req.URL.Host,
+		"x-amz-content-sha256:" + payloadHash,
+		"x-amz-date:" + amzDate,
+		"",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+s.SecretKey), date)
+	key = hmacSHA256(key, s.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+s.AccessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// Put uploads data as the object named after its CID
+func (s *S3Store) Put(data []byte) (string, error) {
+	cid := ComputeCID(data)
+	return cid, s.put(cid, bytes.NewReader(data), int64(len(data)), sha256Hex(data))
+}
+
+// PutStream uploads the data read from r as the object named after its CID.
+// The data is spooled to a temporary file first, since its CID, size and
+// hash have to be known before the upload starts.
+func (s *S3Store) PutStream(r io.Reader) (string, error) {
+	tmp, err := ioutil.TempFile("", "openclimate-s3-")
+	if err != nil {
+		return "", errors.Wrap(err, "could not create file")
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	var b cidBuilder
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, &b, h), r)
+	if err != nil {
+		return "", errors.Wrap(err, "could not write file")
+	}
+
+	_, err = tmp.Seek(0, io.SeekStart)
+	if err != nil {
+		return "", errors.Wrap(err, "could not read file")
+	}
+
+	cid := b.CID()
+	return cid, s.put(cid, tmp, size, hex.EncodeToString(h.Sum(nil)))
+}
+
+func (s *S3Store) put(cid string, body io.Reader, size int64, payloadHash string) error {
+	resp, err := s.do("PUT", cid, body, size, payloadHash)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.New("could not upload " + cid + ": " + resp.Status)
+	}
+	return nil
+}
+
+// Get downloads the object named after a CID. Since the object store doesn't
+// address objects by their content, the data is checked against the CID.
+func (s *S3Store) Get(cid string) ([]byte, error) {
+	body, err := s.GetStream(cid)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	data, err := ioutil.ReadAll(body)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not retrieve "+cid)
+	}
+	return data, nil
+}
+
+// GetStream returns a reader of the object named after a CID. The data is
+// checked against the CID as it is read, and the last read fails if they
+// don't match.
+func (s *S3Store) GetStream(cid string) (io.ReadCloser, error) {
+	resp, err := s.do("GET", cid, nil, 0, sha256Hex(nil))
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, errors.New("could not retrieve " + cid + ": " + resp.Status)
+	}
+	return &verifiedReader{ReadCloser: resp.Body, cid: cid}, nil
+}
+
+// verifiedReader checks the data it reads against its CID
+type verifiedReader struct {
+	io.ReadCloser
+	cid string
+	b   cidBuilder
+}
+
+func (v *verifiedReader) Read(p []byte) (int, error) {
+	n, err := v.ReadCloser.Read(p)
+	v.b.Write(p[:n])
+	if err == io.EOF && v.b.CID() != v.cid {
+		return n, errors.New("data stored under " + v.cid + " doesn't match its cid")
+	}
+	return n, err
+}
+
+// Pin checks that the object exists, since objects are never removed
+func (s *S3Store) Pin(cid string) error {
+	has, err := s.Has(cid)
+	if err != nil {
+		return err
+	}
+	if !has {
+		return errors.New("can't pin missing " + cid)
+	}
+	return nil
+}
+
+// Has checks that the object named after a CID exists
+func (s *S3Store) Has(cid string) (bool, error) {
+	resp, err := s.do("HEAD", cid, nil, 0, sha256Hex(nil))
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, errors.New("could not check " + cid + ": " + resp.Status)
+	}
+}
@@ -0,0 +1,97 @@
+package ipfs
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newTestS3 serves the objects of a bucket from a map
+func newTestS3(t *testing.T, objects map[string][]byte) (*S3Store, func()) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 ") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		key := strings.TrimPrefix(r.URL.Path, "/bucket/")
+		switch r.Method {
+		case "PUT":
+			data, _ := ioutil.ReadAll(r.Body)
+			objects[key] = data
+		case "GET", "HEAD":
+			data, ok := objects[key]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			if r.Method == "GET" {
+				w.Write(data)
+			}
+		}
+	}))
+
+	store, err := NewS3Store(server.URL, "", "bucket", "access", "secret")
+	if err != nil {
+		server.Close()
+		t.Fatal(err)
+	}
+	return store, server.Close
+}
+
+func TestS3Store(t *testing.T) {
+	objects := make(map[string][]byte)
+	store, closeServer := newTestS3(t, objects)
+	defer closeServer()
+
+	data := []byte("hello world\n")
+	cid, err := store.Put(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cid != ComputeCID(data) {
+		t.Errorf("Put returned %s, want %s", cid, ComputeCID(data))
+	}
+
+	stored, err := store.Get(cid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(stored, data) {
+		t.Errorf("Get(%s) = %q, want %q", cid, stored, data)
+	}
+
+	has, err := store.Has(cid)
+	if err != nil || !has {
+		t.Errorf("Has(%s) = %v, %v, want true", cid, has, err)
+	}
+	missing := ComputeCID([]byte("missing"))
+	has, err = store.Has(missing)
+	if err != nil || has {
+		t.Errorf("Has(%s) = %v, %v, want false", missing, has, err)
+	}
+}
+
+// Data that doesn't match the CID it is stored under is rejected
+func TestS3StoreMismatch(t *testing.T) {
+	cid := ComputeCID([]byte("hello world\n"))
+	objects := map[string][]byte{cid: []byte("tampered\n")}
+	store, closeServer := newTestS3(t, objects)
+	defer closeServer()
+
+	if _, err := store.Get(cid); err == nil || !strings.Contains(err.Error(), "doesn't match") {
+		t.Errorf("Get of tampered data returned %v, want a mismatch", err)
+	}
+
+	body, err := store.GetStream(cid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer body.Close()
+	if _, err = ioutil.ReadAll(body); err == nil {
+		t.Error("reading tampered data succeeded")
+	}
+}
@@ -0,0 +1,87 @@
+package ipfs
+
+import (
+	"io"
+
+	globals "github.com/YaleOpenLab/openclimate/globals"
+	"github.com/pkg/errors"
+)
+
+// ContentStore stores data addressed by its content. All stores address
+// data by the CID an IPFS node assigns to it (see cid.go), so data can be
+// moved between stores without changing the hashes recorded on chain.
+type ContentStore interface {
+	// Put stores data and returns its CID
+	Put(data []byte) (string, error)
+	// Get retrieves the data stored under a CID
+	Get(cid string) ([]byte, error)
+	// PutStream stores the data read from r and returns its CID, without
+	// holding all of it in memory
+	PutStream(r io.Reader) (string, error)
+	// GetStream returns a reader of the data stored under a CID, which the
+	// caller has to close
+	GetStream(cid string) (io.ReadCloser, error)
+	// Pin makes sure the data stored under a CID is kept by the store
+	Pin(cid string) error
+	// Has checks whether the data stored under a CID can be retrieved
+	Has(cid string) (bool, error)
+}
+
+// Content stores that can be selected with globals.ContentStore
+const (
+	StoreIpfs  = "ipfs"
+	StoreLocal = "local"
+	StoreS3    = "s3"
+)
+
+// NewStore returns the content store selected in globals.ContentStore
+func NewStore() (ContentStore, error) {
+	switch globals.ContentStore {
+	case StoreIpfs:
+		return NewNodeStore(globals.IpfsApiUrl), nil
+	case StoreLocal:
+		return NewLocalStore(globals.LocalStoreDir)
+	case StoreS3:
+		return NewS3Store(globals.S3Endpoint, globals.S3Region, globals.S3Bucket,
+			globals.S3AccessKey, globals.S3SecretKey)
+	default:
+		return nil, errors.New("unknown content store " + globals.ContentStore)
+	}
+}
+
+// Put stores data in the configured content store and returns its CID
+func Put(data []byte) (string, error) {
+	store, err := NewStore()
+	if err != nil {
+		return "", err
+	}
+	return store.Put(data)
+}
+
+// Get retrieves data from the configured content store
+func Get(cid string) ([]byte, error) {
+	store, err := NewStore()
+	if err != nil {
+		return nil, err
+	}
+	return store.Get(cid)
+}
+
+// PutStream stores the data read from r in the configured content store and
+// returns its CID
+func PutStream(r io.Reader) (string, error) {
+	store, err := NewStore()
+	if err != nil {
+		return "", err
+	}
+	return store.PutStream(r)
+}
+
+// GetStream returns a reader of data in the configured content store
+func GetStream(cid string) (io.ReadCloser, error) {
+	store, err := NewStore()
+	if err != nil {
+		return nil, err
+	}
+	return store.GetStream(cid)
+}
@@ -3,8 +3,8 @@ package main
 import (
 	// "github.com/YaleOpenLab/openclimate/blockchain"
 	"github.com/YaleOpenLab/openclimate/database"
-	// "github.com/YaleOpenLab/openclimate/oracle"
 	"github.com/YaleOpenLab/openclimate/globals"
+	"github.com/YaleOpenLab/openclimate/oracle"
 	"github.com/YaleOpenLab/openclimate/server"
 	flags "github.com/jessevdk/go-flags"
 	"log"
@@ -13,8 +13,19 @@ import (
 )
 
 var opts struct {
-	Insecure bool `short:"i" description:"Start the API using http. Not recommended"`
-	Port     int  `short:"p" description:"The port on which the server runs on. Default: HTTPS/8080"`
+	Insecure  bool  `short:"i" description:"Start the API using http. Not recommended"`
+	Port      int   `short:"p" description:"The port on which the server runs on. Default: HTTPS/8080"`
+	MaxUpload int64 `long:"maxupload" description:"The largest file that can be uploaded, in MB. Default: 100"`
+
+	Store    string `long:"store" description:"Where committed data and uploaded files are stored: ipfs, local or s3. Default: ipfs"`
+	IpfsApi  string `long:"ipfsapi" description:"The HTTP API of the IPFS node of the ipfs store. Default: http://localhost:5001"`
+	StoreDir string `long:"storedir" description:"The directory of the local store. Default: ~/.openclimate/store"`
+
+	Ledger        string `long:"ledger" description:"The ledger roots are anchored on: ethereum, stellar or local. Default: ethereum"`
+	EthRpc        string `long:"ethrpc" description:"The Ethereum node roots are anchored through. Default: Kovan via Infura"`
+	ChainID       int64  `long:"chainid" description:"The chain ID transactions are signed for. Default: 42 (Kovan)"`
+	RootContract  string `long:"rootcontract" description:"The address of the root storage contract"`
+	Confirmations int    `long:"confirmations" description:"Blocks on top of an anchoring transaction before it is final. Default: 3"`
 }
 
 // ParseConfig parses CLI parameters passed
@@ -27,6 +38,33 @@ func ParseConfig(args []string) (bool, int, error) {
 	if opts.Port != 0 {
 		port = opts.Port
 	}
+	if opts.MaxUpload != 0 {
+		globals.MaxUploadSize = opts.MaxUpload << 20
+	}
+	if opts.Store != "" {
+		globals.ContentStore = opts.Store
+	}
+	if opts.IpfsApi != "" {
+		globals.IpfsApiUrl = opts.IpfsApi
+	}
+	if opts.StoreDir != "" {
+		globals.LocalStoreDir = opts.StoreDir
+	}
+	if opts.Ledger != "" {
+		globals.AnchorLedger = opts.Ledger
+	}
+	if opts.EthRpc != "" {
+		globals.EthRpcUrl = opts.EthRpc
+	}
+	if opts.ChainID != 0 {
+		globals.EthChainID = opts.ChainID
+	}
+	if opts.RootContract != "" {
+		globals.EthRootContract = opts.RootContract
+	}
+	if opts.Confirmations != 0 {
+		globals.EthConfirmations = opts.Confirmations
+	}
 	return opts.Insecure, port, nil
 }
 
@@ -34,6 +72,9 @@ func main() {
 	// oracle.Schedule()
 	// blockchain.CheckTokenBalance()
 	// blockchain.CommitToChain(big.NewInt(1565752648), "0x4920636172652061626f757420636c696d617465")
+	if globals.MasterKey == "" {
+		log.Fatal("OPENCLIMATE_MASTER_KEY must be set to the key entity keys are encrypted with")
+	}
 	database.FlushDB()
 	database.CreateHomeDir()
 	database.Populate()
@@ -44,5 +85,13 @@ func main() {
 	}
 
 	log.Println("flushed and created new db")
+	err = oracle.SchedulePinChecks()
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = oracle.ScheduleAnchoring()
+	if err != nil {
+		log.Fatal(err)
+	}
 	server.StartServer(port, insecure)
 }
@@ -1,27 +1,13 @@
 package oracle
 
 import (
-	"math/big"
+	"strconv"
 
-	"github.com/YaleOpenLab/openclimate/blockchain"
+	"github.com/YaleOpenLab/openclimate/anchor"
 	"github.com/YaleOpenLab/openclimate/ipfs"
 	"github.com/pkg/errors"
-
-	utils "github.com/Varunram/essentials/utils"
 )
 
-// Struct defining the data scheme of all reported climate action data
-// when it is stored on Ethereum. Contains all metadata necessary to
-// locate the correct data and statistic and ensure that it is attached
-// to the correct climate actor.
-type BlockChainDataStruct struct {
-	EntityType string
-	EntityID   int
-	ReportType string
-	DataVal    float64
-	IpfsHash   string
-}
-
 // Reads data in the form of an array of GlobalCO2 structs (a struct
 // that, along with the atmospheric CO2 data measurements themselves,
 // also holds metadeta) and computes the "true value".
@@ -45,11 +31,46 @@ func verifyGlobalTemp(data []GlobalTemp) ([]GlobalTemp, float64, error) {
 	return data, temp, nil
 }
 
+// verifyEmissions computes the "true value" of an emissions report, which
+// is the total of its scope 1 and scope 2 emissions. Reports are validated
+// when they are parsed (see ParseReport in oracle/reports.go).
+func verifyEmissions(data ipfs.Emissions) (ipfs.Emissions, float64, error) {
+	return data, float64(data.TotalScope1CO2e + data.TotalScope2CO2e), nil
+}
+
+// verifyMitigation computes the "true value" of a mitigation report, which
+// is the total carbon offset by the reported mitigation actions.
+func verifyMitigation(data ipfs.Mitigation) (ipfs.Mitigation, float64, error) {
+	return data, data.TotalCarbonOffset, nil
+}
+
+// verifyAdaptation computes the "true value" of an adaptation report, which
+// is the number of people that benefit from the reported adaptation actions.
+func verifyAdaptation(data ipfs.Adaptation) (ipfs.Adaptation, float64, error) {
+	return data, float64(data.TotalBeneficiaries), nil
+}
+
+// reportPeriod returns the year a report covers, under which it is indexed
+// on IPFS. Earth data spans many dates and isn't indexed by period.
+func reportPeriod(data interface{}) string {
+	switch d := data.(type) {
+	case ipfs.Emissions:
+		return strconv.Itoa(d.Year)
+	case ipfs.Mitigation:
+		return strconv.Itoa(d.Year)
+	case ipfs.Adaptation:
+		return strconv.Itoa(d.Year)
+	default:
+		return ""
+	}
+}
+
 // VerifyAndCommit receives data and depending on what kind of data it is,
 // sends it to a helper function to verify the data and compute the "true value".
 // Next, it commits the verified data itself to IPFS, receives the IPFS hash,
-// then commits the hash and the computed statistic to Ethereum.
-func VerifyAndCommit(reportType string, entityType string, entityID int, data interface{}) error {
+// then queues the hash and the computed statistic to be anchored with the
+// next batch. Returns the IPFS hash of the committed data.
+func VerifyAndCommit(reportType string, entityType string, entityID int, data interface{}) (string, error) {
 
 	var verifiedData interface{}
 	var dataVal float64
@@ -58,13 +79,46 @@ func VerifyAndCommit(reportType string, entityType string, entityID int, data in
 	switch reportType {
 
 	case "Atmospheric CO2":
-		verifiedData, dataVal, err = verifyAtmosCO2(data.([]GlobalCO2))
+		d, ok := data.([]GlobalCO2)
+		if !ok {
+			return "", errors.New("data is not atmospheric CO2 data")
+		}
+		verifiedData, dataVal, err = verifyAtmosCO2(d)
 
 	case "Global Temperature":
-		verifiedData, dataVal, err = verifyGlobalTemp(data.([]GlobalTemp))
+		d, ok := data.([]GlobalTemp)
+		if !ok {
+			return "", errors.New("data is not global temperature data")
+		}
+		verifiedData, dataVal, err = verifyGlobalTemp(d)
+
+	case ReportEmissions:
+		d, ok := data.(ipfs.Emissions)
+		if !ok {
+			return "", errors.New("data is not an emissions report")
+		}
+		verifiedData, dataVal, err = verifyEmissions(d)
+
+	case ReportMitigation:
+		d, ok := data.(ipfs.Mitigation)
+		if !ok {
+			return "", errors.New("data is not a mitigation report")
+		}
+		verifiedData, dataVal, err = verifyMitigation(d)
+
+	case ReportAdaptation:
+		d, ok := data.(ipfs.Adaptation)
+		if !ok {
+			return "", errors.New("data is not an adaptation report")
+		}
+		verifiedData, dataVal, err = verifyAdaptation(d)
 
 	default:
-		return errors.New("Verification of this report type is not supported.")
+		return "", errors.New("Verification of this report type is not supported.")
+	}
+
+	if err != nil {
+		return "", errors.Wrap(err, "oracle.VerifyAndCommit() failed")
 	}
 
 	// Committing to IPFS may not be necessary. We can commit this data
@@ -74,28 +128,20 @@ func VerifyAndCommit(reportType string, entityType string, entityID int, data in
 	// Here, we commit to IPFS and store the hash on the blockchain to
 	// demonstrate the concept.
 
-	ipfsHash, err := ipfs.IpfsCommitData(verifiedData)
+	ipfsHash, err := ipfs.IpfsCommitData(reportType, entityType, entityID, reportPeriod(verifiedData), verifiedData)
 	if err != nil {
-		return errors.Wrap(err, "oracle.VerifyAndCommit() failed")
+		return "", errors.Wrap(err, "oracle.VerifyAndCommit() failed")
 	}
 
-	// Marshal the data into the BlockChainDataStruct to ensure we have all
-	// the metadata we need to locate the correct IPFS hash and statistic
-	// (for display on the front-end).
-
-	var bcds BlockChainDataStruct
-	bcds.EntityType = entityType
-	bcds.EntityID = entityID
-	bcds.ReportType = reportType
-	bcds.DataVal = dataVal
-	bcds.IpfsHash = ipfsHash
+	// The hash is anchored with the other hashes committed in the same
+	// window, as part of a Merkle tree whose root is stored on the
+	// configured ledger (see anchor/merkle.go). The metadata needed to locate the data
+	// and statistic is stored with the hash.
 
-	timeStamp := utils.Unix()
-	timeStampBigInt := new(big.Int).SetInt64(timeStamp)
-	err = blockchain.CommitToChain(timeStampBigInt, bcds.IpfsHash)
+	_, err = anchor.Enqueue(ipfsHash, reportType, entityType, entityID, dataVal)
 	if err != nil {
-		return errors.Wrap(err, "oracle.VerifyAndCommit() failed")
+		return ipfsHash, errors.Wrap(err, "oracle.VerifyAndCommit() failed")
 	}
 
-	return nil
+	return ipfsHash, nil
 }
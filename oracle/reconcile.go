@@ -0,0 +1,347 @@
+package oracle
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/YaleOpenLab/openclimate/database"
+	"github.com/YaleOpenLab/openclimate/globals"
+	"github.com/pkg/errors"
+)
+
+/*
+
+	Reconciliation compares the values actors report themselves against the
+	values other sources report for the same actor and period:
+
+	- Countries' scope 1 emissions are compared against the territorial
+	  emissions in staticdata/ (CDIAC, UNFCCC and BP figures compiled by the
+	  Global Carbon Project). Territorial emissions only cover fossil CO2, so
+	  tolerances need to allow for the other gases.
+	- Cities' number of mitigation actions is compared against the emission
+	  reduction activities they disclosed to CDP.
+	- Any other source in the time series (e.g. values the oracle or a third
+	  party recorded) is compared against the self-reported values as well.
+
+	Values that differ by more than globals.ReconcileTolerance are flagged as
+	discrepancies for oversight organizations to review.
+
+*/
+
+// carbon to CO2 mass conversion (the territorial emissions dataset is in MtC)
+const carbonToCO2 = 44.0 / 12.0
+
+type territorialEmissions struct {
+	Code      string
+	Year      int
+	Emissions float64 // MtC
+	Source    string
+}
+
+type countryDefinition struct {
+	Code string
+	Name string
+}
+
+type cdpAction struct {
+	City          string `json:"city"`
+	Country       string `json:"country"`
+	ReportingYear string `json:"reporting_year"`
+}
+
+// seriesID identifies an actor's time series of a metric
+type seriesID struct {
+	actorType string
+	actorID   int
+	metric    string
+}
+
+// Reconcile imports the reference datasets for all actors that reported
+// data themselves, compares the self-reported values against every other
+// source and flags the ones that differ by more than the tolerance. Returns
+// the discrepancies flagged in this run.
+func Reconcile() ([]database.Discrepancy, error) {
+	var flagged []database.Discrepancy
+
+	points, err := database.RetrieveAllDataPoints()
+	if err != nil {
+		return flagged, errors.Wrap(err, "could not retrieve data points")
+	}
+
+	err = importReferenceData(points)
+	if err != nil {
+		return flagged, errors.Wrap(err, "could not import reference data")
+	}
+
+	var ids []seriesID
+	seen := make(map[seriesID]bool)
+	for _, point := range points {
+		id := seriesID{point.ActorType, point.ActorID, point.Metric}
+		if point.Source == database.SourceSelfReported && !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	for _, id := range ids {
+		var q database.SeriesQuery
+		q.ActorType = id.actorType
+		q.ActorID = id.actorID
+		q.Metric = id.metric
+		q.Granularity = database.Annual
+
+		series, err := database.QuerySeries(q)
+		if err != nil {
+			return flagged, errors.Wrap(err, "could not query time series")
+		}
+
+		reported := make(map[string]float64)
+		for _, value := range series[database.SourceSelfReported] {
+			reported[value.Period] = value.Value
+		}
+
+		var sources []string
+		for source := range series {
+			if source != database.SourceSelfReported {
+				sources = append(sources, source)
+			}
+		}
+		sort.Strings(sources)
+
+		for _, source := range sources {
+			for _, value := range series[source] {
+				r, exists := reported[value.Period]
+				if !exists {
+					continue
+				}
+
+				d, discrepant := compare(id, value.Period, source, r, value.Value)
+				if !discrepant {
+					err = database.ClearDiscrepancy(id.actorType, id.actorID, id.metric, value.Period, source)
+					if err != nil {
+						return flagged, errors.Wrap(err, "could not clear discrepancy")
+					}
+					continue
+				}
+
+				d, err = database.FlagDiscrepancy(d)
+				if err != nil {
+					return flagged, errors.Wrap(err, "could not flag discrepancy")
+				}
+				flagged = append(flagged, d)
+			}
+		}
+	}
+
+	return flagged, nil
+}
+
+// reconcile runs Reconcile as a scheduled job
+func reconcile() {
+	flagged, err := Reconcile()
+	if err != nil {
+		log.Println(errors.Wrap(err, "reconciliation failed"))
+		return
+	}
+	log.Println("reconciliation flagged", len(flagged), "discrepancies")
+}
+
+// compare checks whether a self-reported value differs from a reference
+// value by more than the tolerance
+func compare(id seriesID, period string, source string, reported float64, reference float64) (database.Discrepancy, bool) {
+	var d database.Discrepancy
+	d.ActorType = id.actorType
+	d.ActorID = id.actorID
+	d.Metric = id.metric
+	d.Period = period
+	d.Source = source
+	d.Reported = reported
+	d.Reference = reference
+	d.Difference = reported - reference
+
+	switch {
+	case reference != 0:
+		d.RelativeDifference = d.Difference / reference
+	case reported != 0:
+		// nothing to compare against, the whole value is the difference
+		d.RelativeDifference = 1
+	}
+
+	return d, math.Abs(d.RelativeDifference) > globals.ReconcileTolerance
+}
+
+// importReferenceData adds the values reported by the static datasets to the
+// time series of the actors that reported values for the same periods
+func importReferenceData(points []database.DataPoint) error {
+	countryYears := make(map[int]map[int]bool)
+	cityYears := make(map[int]map[int]bool)
+
+	for _, point := range points {
+		if point.Source != database.SourceSelfReported {
+			continue
+		}
+
+		year, err := strconv.Atoi(point.Period[:4])
+		if err != nil {
+			continue
+		}
+
+		if point.ActorType == "country" && point.Metric == database.MetricScope1 {
+			addYear(countryYears, point.ActorID, year)
+		}
+		if point.ActorType == "city" && point.Metric == database.MetricMitigationActions {
+			addYear(cityYears, point.ActorID, year)
+		}
+	}
+
+	var reference []database.DataPoint
+
+	if len(countryYears) != 0 {
+		arr, err := territorialReference(countryYears)
+		if err != nil {
+			return err
+		}
+		reference = append(reference, arr...)
+	}
+
+	if len(cityYears) != 0 {
+		arr, err := cdpReference(cityYears)
+		if err != nil {
+			return err
+		}
+		reference = append(reference, arr...)
+	}
+
+	if len(reference) == 0 {
+		return nil
+	}
+
+	_, err := database.RecordDataPoints(reference)
+	return err
+}
+
+// territorialReference returns the territorial emissions of countries in the
+// given years, converted to tCO2
+func territorialReference(countryYears map[int]map[int]bool) ([]database.DataPoint, error) {
+	var points []database.DataPoint
+
+	var definitions map[string]countryDefinition
+	err := readJson(globals.StDataDir+"/country_definitions.json", &definitions)
+	if err != nil {
+		return points, err
+	}
+
+	var emissions map[string]territorialEmissions
+	err = readJson(globals.StDataDir+"/territorial_emissions.json", &emissions)
+	if err != nil {
+		return points, err
+	}
+
+	codes := make(map[string]int) // ISO code -> country ID
+	for countryID := range countryYears {
+		country, err := database.RetrieveCountry(countryID)
+		if err != nil {
+			return points, errors.Wrap(err, "could not retrieve country")
+		}
+
+		code := strings.ToUpper(country.Iso)
+		if code == "" {
+			for _, definition := range definitions {
+				if strings.EqualFold(definition.Name, country.Name) {
+					code = definition.Code
+				}
+			}
+		}
+		if code != "" {
+			codes[code] = countryID
+		}
+	}
+
+	for _, row := range emissions {
+		countryID, exists := codes[row.Code]
+		if !exists || !countryYears[countryID][row.Year] {
+			continue
+		}
+
+		var point database.DataPoint
+		point.ActorType = "country"
+		point.ActorID = countryID
+		point.Metric = database.MetricScope1
+		point.Period = strconv.Itoa(row.Year)
+		point.Source = row.Source
+		point.Value = row.Emissions * 1e6 * carbonToCO2
+		point.Unit = "tCO2e"
+		points = append(points, point)
+	}
+
+	return points, nil
+}
+
+// cdpReference returns the number of emission reduction activities cities
+// disclosed to CDP in the given years. Cities are matched by name, and by
+// country if the country is known and named the same way.
+func cdpReference(cityYears map[int]map[int]bool) ([]database.DataPoint, error) {
+	var points []database.DataPoint
+
+	var actions []cdpAction
+	err := readJson(globals.StDataDir+"/u385-vvra.json", &actions)
+	if err != nil {
+		return points, err
+	}
+
+	for cityID, years := range cityYears {
+		city, err := database.RetrieveCity(cityID)
+		if err != nil {
+			return points, errors.Wrap(err, "could not retrieve city")
+		}
+
+		counts := make(map[int]int)
+		for _, action := range actions {
+			if !strings.EqualFold(action.City, city.Name) {
+				continue
+			}
+			if city.Country != "" && action.Country != "" && !strings.EqualFold(action.Country, city.Country) {
+				continue
+			}
+
+			year, err := strconv.Atoi(action.ReportingYear)
+			if err == nil && years[year] {
+				counts[year]++
+			}
+		}
+
+		for year, count := range counts {
+			var point database.DataPoint
+			point.ActorType = "city"
+			point.ActorID = cityID
+			point.Metric = database.MetricMitigationActions
+			point.Period = strconv.Itoa(year)
+			point.Source = database.SourceCDP
+			point.Value = float64(count)
+			point.Unit = "actions"
+			points = append(points, point)
+		}
+	}
+
+	return points, nil
+}
+
+func addYear(m map[int]map[int]bool, actorID int, year int) {
+	if m[actorID] == nil {
+		m[actorID] = make(map[int]bool)
+	}
+	m[actorID][year] = true
+}
+
+func readJson(path string, x interface{}) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return errors.Wrap(err, "could not read "+path)
+	}
+	return errors.Wrap(json.Unmarshal(data, x), "could not parse "+path)
+}
@@ -0,0 +1,579 @@
+package oracle
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/YaleOpenLab/openclimate/ghg"
+	"github.com/YaleOpenLab/openclimate/globals"
+	"github.com/YaleOpenLab/openclimate/ipfs"
+	"github.com/pkg/errors"
+)
+
+// Report types that climate actors can self-report
+const (
+	ReportEmissions  = "Emissions"
+	ReportMitigation = "Mitigation"
+	ReportAdaptation = "Adaptation"
+)
+
+// Earliest year for which reports are accepted (UNFCCC base year)
+const minReportYear = 1990
+
+// Relative difference allowed between a reported total and the sum of
+// its children, to allow for rounding in the reported figures
+const childSumTolerance = 0.001
+
+// Conversion factors from the accepted units to tCO2e
+var co2eUnits = map[string]float64{
+	"tCO2e":  1,
+	"ktCO2e": 1e3,
+	"MtCO2e": 1e6,
+}
+
+// Conversion factors from the accepted units to MWh
+var energyUnits = map[string]float64{
+	"kWh": 1e-3,
+	"MWh": 1,
+	"GWh": 1e3,
+}
+
+// Options accepted for the Type and Category of mitigation actions
+var mitigationTypes = []string{
+	"Renewable energy",
+	"Energy efficiency",
+	"Agriculture, Forestry & Other",
+	"Carbon sequestrations",
+}
+
+var mitigationCategories = []string{
+	"Reduction of GHG sources",
+	"Enhancement of GHG sinks",
+	"Both",
+}
+
+// Options accepted for the hazards adaptation actions respond to
+var hazardTypes = []string{
+	"Flooding",
+	"Sea level rise",
+	"Extreme heat",
+	"Drought",
+	"Wildfire",
+	"Storms",
+	"Water scarcity",
+	"Other",
+}
+
+// ValidationError describes why a field in a report was rejected
+type ValidationError struct {
+	Field  string `json:"field"`
+	Reason string `json:"reason"`
+}
+
+// ValidationErrors is returned when a report fails validation. Unlike other
+// errors returned by the oracle, it is caused by the data that was submitted
+// and should be reported back to the submitter.
+type ValidationErrors []ValidationError
+
+func (v ValidationErrors) Error() string {
+	var reasons []string
+	for _, e := range v {
+		reasons = append(reasons, e.Field+": "+e.Reason)
+	}
+	return "report failed validation: " + strings.Join(reasons, "; ")
+}
+
+func (v *ValidationErrors) add(field string, format string, args ...interface{}) {
+	*v = append(*v, ValidationError{Field: field, Reason: fmt.Sprintf(format, args...)})
+}
+
+// IsValidationError checks whether err (or the error it wraps) was caused
+// by invalid report data
+func IsValidationError(err error) bool {
+	_, ok := errors.Cause(err).(ValidationErrors)
+	return ok
+}
+
+/*
+	ParseReport decodes a self-reported climate action report into the typed
+	struct defined in ipfs/data.go for its report type, validates it and
+	normalizes its units. The metadata of the report is checked against the
+	climate actor submitting it.
+
+	arguments:
+	- "reportType": one of ReportEmissions, ReportMitigation, ReportAdaptation
+	- "entityType": the type of the reporting actor
+	- "entityID": the ID of the reporting actor in the database
+	- "data": the report encoded as JSON
+
+	Returns ValidationErrors if the report is malformed.
+*/
+func ParseReport(reportType string, entityType string, entityID int, data []byte) (interface{}, error) {
+	switch reportType {
+	case ReportEmissions:
+		var report ipfs.Emissions
+		err := decodeStrict(data, &report)
+		if err == nil {
+			err = validateEmissions(&report, entityType, entityID)
+		}
+		return report, err
+
+	case ReportMitigation:
+		var report ipfs.Mitigation
+		err := decodeStrict(data, &report)
+		if err == nil {
+			err = validateMitigation(&report, entityType, entityID)
+		}
+		return report, err
+
+	case ReportAdaptation:
+		var report ipfs.Adaptation
+		err := decodeStrict(data, &report)
+		if err == nil {
+			err = validateAdaptation(&report, entityType, entityID)
+		}
+		return report, err
+
+	default:
+		var v ValidationErrors
+		v.add("report_type", "unsupported report type %q", reportType)
+		return nil, v
+	}
+}
+
+// decodeStrict decodes JSON into x, rejecting fields that x does not define
+func decodeStrict(data []byte, x interface{}) error {
+	var v ValidationErrors
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(x)
+	if err != nil {
+		switch e := err.(type) {
+		case *json.UnmarshalTypeError:
+			v.add(e.Field, "expected a value of type %s, got %s", e.Type.String(), e.Value)
+		default:
+			v.add("data", "invalid json: %s", err.Error())
+		}
+		return v
+	}
+
+	if decoder.More() {
+		v.add("data", "unexpected data after the report")
+		return v
+	}
+	return nil
+}
+
+// validateMeta checks the metadata that all report types share and fills
+// in the fields that are derived from the reporting actor
+func validateMeta(v *ValidationErrors, userID *int, reportEntityType *string, year int,
+	dateReported *string, source string, methodology string, entityType string, entityID int) {
+
+	if *reportEntityType != "" && *reportEntityType != entityType {
+		v.add("EntityType", "report is for a %s but the reporting actor is a %s", *reportEntityType, entityType)
+	}
+	*reportEntityType = entityType
+
+	if *userID != 0 && *userID != entityID {
+		v.add("UserID", "report is for actor %d but the reporting actor is %d", *userID, entityID)
+	}
+	*userID = entityID
+
+	if year < minReportYear || !valid_record_year(year) {
+		v.add("Year", "year must be between %d and the current year", minReportYear)
+	}
+
+	if *dateReported == "" {
+		*dateReported = time.Now().Format("2006-01-02")
+	} else if !validDate(*dateReported) {
+		v.add("DateReported", "date must be formatted as YYYY-MM-DD or RFC 3339")
+	}
+
+	if strings.TrimSpace(source) == "" {
+		v.add("Source", "source is required")
+	}
+
+	if strings.TrimSpace(methodology) == "" {
+		v.add("Methodology", "methodology is required")
+	}
+}
+
+func validateEmissions(report *ipfs.Emissions, entityType string, entityID int) error {
+	var v ValidationErrors
+
+	validateMeta(&v, &report.UserID, &report.EntityType, report.Year, &report.DateReported,
+		report.Source, report.Methodology, entityType, entityID)
+
+	factor := unitFactor(&v, "Unit", &report.Unit, co2eUnits, "tCO2e")
+
+	if report.TotalScope1CO2e < 0 {
+		v.add("TotalScope1CO2e", "emissions cannot be negative")
+	}
+	if report.TotalScope2CO2e < 0 {
+		v.add("TotalScope2CO2e", "emissions cannot be negative")
+	}
+	if report.TotalScope2MarketCO2e < 0 {
+		v.add("TotalScope2MarketCO2e", "emissions cannot be negative")
+	}
+	if report.TotalScope3CO2e < 0 {
+		v.add("TotalScope3CO2e", "emissions cannot be negative")
+	}
+
+	energyFactor := 1.0
+	if len(report.Electricity) != 0 {
+		energyFactor = unitFactor(&v, "EnergyUnit", &report.EnergyUnit, energyUnits, "MWh")
+
+		regions, err := ghg.LoadGridRegions()
+		if err != nil {
+			return errors.Wrap(err, "could not load grid regions")
+		}
+
+		for i, use := range report.Electricity {
+			field := fmt.Sprintf("Electricity[%d]", i)
+			if !knownGridRegion(regions, use.GridRegion) {
+				v.add(field, "unknown grid region %q", use.GridRegion)
+			}
+			if use.Consumption < 0 {
+				v.add(field, "consumption cannot be negative")
+			}
+			if use.SupplierFactor != nil && *use.SupplierFactor < 0 {
+				v.add(field, "supplier emission factor cannot be negative")
+			}
+		}
+	}
+
+	if report.GWP == "" {
+		report.GWP = globals.DefaultGWP
+	}
+	if report.GWPHorizon == 0 {
+		report.GWPHorizon = ghg.Horizon100
+	}
+	gwp := ghg.GWPSet{Report: report.GWP, Horizon: report.GWPHorizon}
+	if !ghg.ValidGWP(gwp) {
+		v.add("GWP", "GWP must be AR4, AR5 or AR6 and the horizon 20 or 100 years")
+		gwp = ghg.DefaultGWPSet() // keep validating the rest of the report
+	}
+
+	for i, activity := range report.Activities {
+		field := fmt.Sprintf("Activities[%d]", i)
+		if activity.Scope != 1 && activity.Scope != 3 {
+			v.add(field, "scope must be 1 or 3, electricity is reported in Electricity")
+		}
+		if activity.Category == ghg.CategoryElectricity {
+			v.add(field, "electricity is reported in Electricity")
+		}
+		_, err := ghg.Calculate(activity.Category, activity.Activity, activity.Quantity, activity.Unit,
+			report.Year, activity.FactorSource, gwp)
+		if err != nil {
+			v.add(field, "%s", err.Error())
+		}
+	}
+
+	for i, gas := range report.Gases {
+		field := fmt.Sprintf("Gases[%d]", i)
+		if gas.Scope != 1 && gas.Scope != 3 {
+			v.add(field, "scope must be 1 or 3, electricity is reported in Electricity")
+		}
+		if gas.Gas == ghg.HFCs || gas.Gas == ghg.PFCs {
+			v.add(field, "HFCs and PFCs must be reported by species, e.g. HFC-134a or CF4")
+		} else if !ghg.KnownGas(gas.Gas) {
+			v.add(field, "unknown gas %q", gas.Gas)
+		}
+		if gas.Quantity < 0 {
+			v.add(field, "quantity cannot be negative")
+		}
+	}
+
+	var scope1, scope2, scope3 float64
+	children := make(map[int]bool)
+	for i, child := range report.ByChild {
+		field := fmt.Sprintf("ByChild[%d]", i)
+		validateChild(&v, field, child.ChildID, child.ChildName, children)
+
+		if child.Scope1CO2e < 0 || child.Scope2CO2e < 0 || child.Scope3CO2e < 0 {
+			v.add(field, "emissions cannot be negative")
+		}
+
+		scope1 += child.Scope1CO2e
+		scope2 += child.Scope2CO2e
+		scope3 += child.Scope3CO2e
+	}
+
+	if len(report.ByChild) != 0 {
+		checkChildSum(&v, "TotalScope1CO2e", float64(report.TotalScope1CO2e), scope1)
+		// scope 2 is computed from the electricity consumption if it is reported
+		if len(report.Electricity) == 0 {
+			checkChildSum(&v, "TotalScope2CO2e", float64(report.TotalScope2CO2e), scope2)
+		}
+		checkChildSum(&v, "TotalScope3CO2e", float64(report.TotalScope3CO2e), scope3)
+	}
+
+	if len(v) != 0 {
+		return v
+	}
+
+	report.TotalScope1CO2e = int(math.Round(float64(report.TotalScope1CO2e) * factor))
+	report.TotalScope2CO2e = int(math.Round(float64(report.TotalScope2CO2e) * factor))
+	report.TotalScope3CO2e = int(math.Round(float64(report.TotalScope3CO2e) * factor))
+	report.TotalScope2MarketCO2e = int(math.Round(float64(report.TotalScope2MarketCO2e) * factor))
+	for i := range report.ByChild {
+		report.ByChild[i].Scope1CO2e *= factor
+		report.ByChild[i].Scope2CO2e *= factor
+		report.ByChild[i].Scope3CO2e *= factor
+	}
+
+	activities, err := ghg.ComputeActivities(report.Activities, report.Year, gwp)
+	if err != nil {
+		return errors.Wrap(err, "could not compute emissions from activity data")
+	}
+	gases, err := ghg.ConvertGases(report.Gases, gwp)
+	if err != nil {
+		return errors.Wrap(err, "could not convert gases to CO2e")
+	}
+	inventory, err := ghg.Inventory(gases, activities, nil, gwp)
+	if err != nil {
+		return errors.Wrap(err, "could not compute gas inventory")
+	}
+
+	var computed1, computed3 float64
+	for _, gas := range inventory {
+		if gas.Scope == 1 {
+			computed1 += gas.CO2e
+		} else {
+			computed3 += gas.CO2e
+		}
+	}
+
+	report.Activities = activities
+	report.Gases = gases
+	report.Inventory = inventory
+	report.TotalScope1CO2e += int(math.Round(computed1))
+	report.TotalScope3CO2e += int(math.Round(computed3))
+
+	if len(report.Electricity) == 0 {
+		if report.TotalScope2MarketCO2e == 0 {
+			report.TotalScope2MarketCO2e = report.TotalScope2CO2e
+		}
+		return nil
+	}
+
+	for i := range report.Electricity {
+		report.Electricity[i].Consumption *= energyFactor
+	}
+
+	computed, err := ghg.ComputeActorScope2(entityType, entityID, report.Electricity, report.Year, gwp)
+	if err != nil {
+		return errors.Wrap(err, "could not compute scope 2 emissions")
+	}
+
+	report.Inventory, err = ghg.Inventory(gases, activities, computed.Gases, gwp)
+	if err != nil {
+		return errors.Wrap(err, "could not compute gas inventory")
+	}
+
+	report.Electricity = computed.Electricity
+	report.TotalScope2CO2e = int(math.Round(computed.LocationBased))
+	report.TotalScope2MarketCO2e = int(math.Round(computed.MarketBased))
+	return nil
+}
+
+func knownGridRegion(regions []ghg.GridRegion, name string) bool {
+	for _, region := range regions {
+		if region.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func validateMitigation(report *ipfs.Mitigation, entityType string, entityID int) error {
+	var v ValidationErrors
+
+	validateMeta(&v, &report.UserID, &report.EntityType, report.Year, &report.DateReported,
+		report.Source, report.Methodology, entityType, entityID)
+
+	factor := unitFactor(&v, "Unit", &report.Unit, co2eUnits, "tCO2e")
+	energyFactor := unitFactor(&v, "EnergyUnit", &report.EnergyUnit, energyUnits, "MWh")
+
+	if report.TotalCarbonOffset < 0 {
+		v.add("TotalCarbonOffset", "carbon offsets cannot be negative")
+	}
+	if report.TotalEnergySaved < 0 {
+		v.add("TotalEnergySaved", "energy saved cannot be negative")
+	}
+	if report.TotalEnergyGen < 0 {
+		v.add("TotalEnergyGen", "energy generated cannot be negative")
+	}
+
+	var offset, saved, generated float64
+	children := make(map[int]bool)
+	for i, child := range report.ByChild {
+		field := fmt.Sprintf("ByChild[%d]", i)
+		validateChild(&v, field, child.ChildID, child.ChildName, children)
+
+		if child.CarbonOffset < 0 || child.EnergySaved < 0 || child.EnergyGen < 0 {
+			v.add(field, "mitigation outcomes cannot be negative")
+		}
+
+		if !contains(mitigationTypes, child.Type) {
+			v.add(field+".Type", "type must be one of: %s", strings.Join(mitigationTypes, ", "))
+		}
+
+		if !contains(mitigationCategories, child.Category) {
+			v.add(field+".Category", "category must be one of: %s", strings.Join(mitigationCategories, ", "))
+		}
+
+		offset += child.CarbonOffset
+		saved += child.EnergySaved
+		generated += child.EnergyGen
+	}
+
+	if len(report.ByChild) != 0 {
+		checkChildSum(&v, "TotalCarbonOffset", report.TotalCarbonOffset, offset)
+		checkChildSum(&v, "TotalEnergySaved", report.TotalEnergySaved, saved)
+		checkChildSum(&v, "TotalEnergyGen", report.TotalEnergyGen, generated)
+	}
+
+	if len(v) != 0 {
+		return v
+	}
+
+	report.TotalCarbonOffset *= factor
+	report.TotalEnergySaved *= energyFactor
+	report.TotalEnergyGen *= energyFactor
+	for i := range report.ByChild {
+		report.ByChild[i].CarbonOffset *= factor
+		report.ByChild[i].EnergySaved *= energyFactor
+		report.ByChild[i].EnergyGen *= energyFactor
+	}
+	return nil
+}
+
+func validateAdaptation(report *ipfs.Adaptation, entityType string, entityID int) error {
+	var v ValidationErrors
+
+	validateMeta(&v, &report.UserID, &report.EntityType, report.Year, &report.DateReported,
+		report.Source, report.Methodology, entityType, entityID)
+
+	if report.Currency == "" {
+		report.Currency = "USD"
+	}
+	if len(report.Currency) != 3 || strings.ToUpper(report.Currency) != report.Currency {
+		v.add("Currency", "currency must be an ISO 4217 code")
+	}
+
+	if report.TotalInvestment < 0 {
+		v.add("TotalInvestment", "investment cannot be negative")
+	}
+	if report.TotalBeneficiaries < 0 {
+		v.add("TotalBeneficiaries", "beneficiaries cannot be negative")
+	}
+
+	var investment float64
+	var beneficiaries int
+	children := make(map[int]bool)
+	for i, child := range report.ByChild {
+		field := fmt.Sprintf("ByChild[%d]", i)
+		validateChild(&v, field, child.ChildID, child.ChildName, children)
+
+		if strings.TrimSpace(child.Action) == "" {
+			v.add(field+".Action", "action is required")
+		}
+
+		if !contains(hazardTypes, child.HazardType) {
+			v.add(field+".HazardType", "hazard type must be one of: %s", strings.Join(hazardTypes, ", "))
+		}
+
+		if child.ExposedPopulation < 0 || child.Beneficiaries < 0 || child.Investment < 0 {
+			v.add(field, "adaptation outcomes cannot be negative")
+		}
+
+		for j, indicator := range child.ResilienceIndicators {
+			if strings.TrimSpace(indicator.Name) == "" || strings.TrimSpace(indicator.Unit) == "" {
+				v.add(fmt.Sprintf("%s.ResilienceIndicators[%d]", field, j), "indicator must have a name and a unit")
+			}
+		}
+
+		investment += child.Investment
+		beneficiaries += child.Beneficiaries
+	}
+
+	if len(report.ByChild) != 0 {
+		checkChildSum(&v, "TotalInvestment", report.TotalInvestment, investment)
+		checkChildSum(&v, "TotalBeneficiaries", float64(report.TotalBeneficiaries), float64(beneficiaries))
+	}
+
+	if len(v) != 0 {
+		return v
+	}
+	return nil
+}
+
+// unitFactor checks that unit is one of the accepted units and returns the
+// factor that converts it to the base unit. An empty unit defaults to the
+// base unit.
+func unitFactor(v *ValidationErrors, field string, unit *string, units map[string]float64, base string) float64 {
+	if *unit == "" {
+		*unit = base
+	}
+
+	factor, ok := units[*unit]
+	if !ok {
+		var accepted []string
+		for key := range units {
+			accepted = append(accepted, key)
+		}
+		sort.Strings(accepted)
+		v.add(field, "unit must be one of: %s", strings.Join(accepted, ", "))
+		return 1
+	}
+
+	// reports are stored in the base unit
+	*unit = base
+	return factor
+}
+
+// validateChild checks that a child of a report can be identified and is
+// not reported twice
+func validateChild(v *ValidationErrors, field string, childID int, childName string, seen map[int]bool) {
+	if childID <= 0 && strings.TrimSpace(childName) == "" {
+		v.add(field, "child must have a ChildID or a ChildName")
+	}
+
+	if childID > 0 {
+		if seen[childID] {
+			v.add(field, "child %d is reported more than once", childID)
+		}
+		seen[childID] = true
+	}
+}
+
+// checkChildSum checks that the sum of a report's children matches its total
+func checkChildSum(v *ValidationErrors, field string, total float64, sum float64) {
+	if math.Abs(total-sum) > math.Max(1, total*childSumTolerance) {
+		v.add(field, "total %g does not match the sum of ByChild (%g)", total, sum)
+	}
+}
+
+func validDate(date string) bool {
+	if _, err := time.Parse("2006-01-02", date); err == nil {
+		return true
+	}
+	_, err := time.Parse(time.RFC3339, date)
+	return err == nil
+}
+
+func contains(options []string, x string) bool {
+	for _, option := range options {
+		if option == x {
+			return true
+		}
+	}
+	return false
+}
@@ -1,24 +1,28 @@
 package oracle
 
 import (
+	"github.com/YaleOpenLab/openclimate/anchor"
+	"github.com/YaleOpenLab/openclimate/database"
+	globals "github.com/YaleOpenLab/openclimate/globals"
+	"github.com/YaleOpenLab/openclimate/ipfs"
 	"github.com/pkg/errors"
 	"github.com/robfig/cron"
 	"log"
-	// "github.com/YaleOpenLab/openclimate/blockchain"
 )
 
 // Valid reportType(s):
 // - "Atmospheric CO2"
 // - "Global Temperature"
-// - TBD: Emissions related reports
-// - TBD: Mitigation related reports
+// - "Emissions" (self-reported, see oracle/reports.go)
+// - "Mitigation" (self-reported, see oracle/reports.go)
+// - "Adaptation" (self-reported, see oracle/reports.go)
 
 const (
 	// Earth data parameters
-	earthCO2        = "Atmospheric CO2"
-	earthTemp       = "Global Temperature"
-	earthEntityID   = 1
-	earthEntityType = "Earth"
+	earthCO2        = ipfs.EarthCO2
+	earthTemp       = ipfs.EarthTemp
+	earthEntityID   = ipfs.EarthActorID
+	earthEntityType = ipfs.EarthActorType
 )
 
 type getExternalData func() (interface{}, error)
@@ -34,13 +38,76 @@ func getVerifyCommit(reportType string, entityType string, entityID int, get get
 			log.Fatal(errors.Wrap(err, "GetVerifyCommit() failed"))
 		}
 
-		err = VerifyAndCommit(reportType, entityType, entityID, data)
+		_, err = VerifyAndCommit(reportType, entityType, entityID, data)
 		if err != nil {
 			log.Fatal(errors.Wrap(err, "GetVerifyCommit() failed"))
 		}
 	}
 }
 
+// checkPins verifies that the data the platform depends on is still pinned
+func checkPins() {
+	unpinned, err := ipfs.VerifyPins()
+	if err != nil {
+		log.Println(errors.Wrap(err, "pin check failed"))
+		return
+	}
+	for _, p := range unpinned {
+		log.Println("could not pin", p.Hash, "of", p.ActorType, p.ActorID, ":", p.Error)
+	}
+}
+
+// SchedulePinChecks schedules the checks that the data the platform depends
+// on is still pinned, on globals.PinCheckSchedule. Unlike the jobs in
+// Schedule(), it doesn't depend on external data, so it is started with the
+// platform.
+func SchedulePinChecks() error {
+	c := cron.New()
+	err := c.AddFunc(globals.PinCheckSchedule, checkPins)
+	if err != nil {
+		return errors.Wrap(err, "invalid pin check schedule")
+	}
+	c.Start()
+	return nil
+}
+
+// anchorBatch anchors the root of the hashes committed since the last batch
+// on the configured ledger, and records the receipts with the reports
+func anchorBatch() {
+	a, err := anchor.NewAnchor(globals.AnchorLedger)
+	if err != nil {
+		log.Println(errors.Wrap(err, "anchoring failed"))
+		return
+	}
+
+	// batches that failed earlier may have been anchored even if this one
+	// wasn't, so the receipts are recorded either way
+	batch, err := anchor.AnchorPending(a)
+	if err != nil {
+		log.Println(errors.Wrap(err, "anchoring failed"))
+	} else if batch.Index != 0 {
+		log.Println("anchored", batch.Leaves, "hashes under root", batch.Root, "on", batch.Receipt.Ledger)
+	}
+
+	err = database.RecordAnchors()
+	if err != nil {
+		log.Println(errors.Wrap(err, "could not record anchor receipts"))
+	}
+}
+
+// ScheduleAnchoring schedules the anchoring of the hashes committed to IPFS
+// in batches, on globals.AnchorSchedule. It is started with the platform,
+// since hashes are queued whenever data is committed.
+func ScheduleAnchoring() error {
+	c := cron.New()
+	err := c.AddFunc(globals.AnchorSchedule, anchorBatch)
+	if err != nil {
+		return errors.Wrap(err, "invalid anchor schedule")
+	}
+	c.Start()
+	return nil
+}
+
 // Schedule() schedules regular calls to APIs and FTP servers for data,
 // sends the data to the oracle for verification and storage on IPFS
 // and Ethereum. To add new scheduled API wrappers, simply add another
@@ -54,6 +121,7 @@ func Schedule() {
 	c := cron.New()
 	c.AddFunc("@daily", getVerifyCommit(earthCO2, earthEntityType, earthEntityID, getNoaaDailyCO2))
 	c.AddFunc("@monthly", getVerifyCommit(earthCO2, earthEntityType, earthEntityID, getNoaaMonthlyCO2))
+	c.AddFunc("@daily", reconcile)
 
 	c.Start()
 }
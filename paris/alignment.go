@@ -0,0 +1,170 @@
+package paris
+
+import (
+	"strings"
+
+	"github.com/YaleOpenLab/openclimate/database"
+	"github.com/pkg/errors"
+)
+
+/*
+
+	Scores pledges against the carbon budget. An actor's emission reduction
+	pledges define a trajectory relative to its base year emissions: a linear
+	path from the base year to the goal in the target year, held flat after
+	the target year. We then ask what would happen to the climate if global
+	emissions followed the same relative trajectory from the last year in the
+	bundled data until horizonYear. The implied warming is the current warming
+	plus the warming caused by those cumulative emissions (using the TCRE).
+
+	This lets us rate actors of any size without knowing their share of global
+	emissions.
+
+*/
+
+// last year considered when computing cumulative future emissions
+const horizonYear = 2100
+
+// Alignment is the result of scoring an actor's pledges against the budget
+type Alignment struct {
+	ActorType      string            `json:"actor_type"`
+	ActorID        int               `json:"actor_id"`
+	Pledges        []database.Pledge `json:"pledges"`
+	Cumulative     float64           `json:"cumulative_gt_co2"` // global emissions until horizonYear on this trajectory
+	ImpliedWarming float64           `json:"implied_warming"`
+	Baseline       float64           `json:"baseline_warming"` // implied warming if global emissions stay flat
+	Rating         string            `json:"rating"`
+	Score          float64           `json:"score"` // 0 (no better than baseline) to 100 (1.5C aligned)
+}
+
+// Ratings that are assigned to pledges
+const (
+	Rating15         = "1.5C aligned"
+	Rating2          = "2C aligned"
+	RatingNotAligned = "not aligned"
+	RatingNoPledge   = "no emission reduction pledge"
+)
+
+// IsReductionPledge checks whether a pledge is an emissions reduction pledge
+// whose goal is the percentage reduction from the base year
+func IsReductionPledge(p database.Pledge) bool {
+	if !strings.Contains(strings.ToLower(p.PledgeType), "reduction") {
+		return false
+	}
+	return p.Goal > 0 && p.Goal <= 100 && p.TargetYear > p.BaseYear
+}
+
+// Fraction returns the emissions in year as a fraction of the base year
+// emissions if the actor follows the pledge
+func Fraction(p database.Pledge, year float64) float64 {
+	if year <= p.BaseYear {
+		return 1
+	}
+	if year >= p.TargetYear {
+		return 1 - p.Goal/100
+	}
+	progress := (year - p.BaseYear) / (p.TargetYear - p.BaseYear)
+	return 1 - progress*p.Goal/100
+}
+
+// trajectory returns the emissions in year as a fraction of the base year
+// emissions, taking the most ambitious pledge in every year
+func trajectory(pledges []database.Pledge, year float64) float64 {
+	min := 1.0
+	for _, p := range pledges {
+		f := Fraction(p, year)
+		if f < min {
+			min = f
+		}
+	}
+	return min
+}
+
+// futureEmissions returns the cumulative global emissions from the year
+// after the last year in the data until horizonYear if global emissions
+// followed the given pledges
+func futureEmissions(pledges []database.Pledge, status Status) float64 {
+	start := float64(status.DataYear)
+	current := trajectory(pledges, start)
+	if current == 0 {
+		return 0
+	}
+
+	cumulative := 0.0
+	for year := status.DataYear + 1; year <= horizonYear; year++ {
+		cumulative += status.AnnualEmissions * trajectory(pledges, float64(year)) / current
+	}
+	return cumulative
+}
+
+// impliedWarming converts cumulative future emissions into warming
+func impliedWarming(cumulative float64, status Status) float64 {
+	return status.Warming + tcre*cumulative
+}
+
+// ScorePledges computes the implied warming of a set of pledges
+func ScorePledges(pledges []database.Pledge, status Status) Alignment {
+	var a Alignment
+	for _, p := range pledges {
+		if IsReductionPledge(p) {
+			a.Pledges = append(a.Pledges, p)
+		}
+	}
+
+	a.Baseline = impliedWarming(futureEmissions(nil, status), status)
+	if len(a.Pledges) == 0 {
+		a.Cumulative = futureEmissions(nil, status)
+		a.ImpliedWarming = a.Baseline
+		a.Rating = RatingNoPledge
+		return a
+	}
+
+	a.Cumulative = futureEmissions(a.Pledges, status)
+	a.ImpliedWarming = impliedWarming(a.Cumulative, status)
+
+	switch {
+	case a.ImpliedWarming <= 1.5:
+		a.Rating = Rating15
+	case a.ImpliedWarming <= 2:
+		a.Rating = Rating2
+	default:
+		a.Rating = RatingNotAligned
+	}
+
+	if a.Baseline > 1.5 {
+		a.Score = 100 * (a.Baseline - a.ImpliedWarming) / (a.Baseline - 1.5)
+	}
+	if a.Score > 100 {
+		a.Score = 100
+	}
+	if a.Score < 0 {
+		a.Score = 0
+	}
+	return a
+}
+
+// ScoreActor retrieves an actor's pledges and scores them against the
+// current state of the carbon budget
+func ScoreActor(actorType string, actorID int) (Alignment, error) {
+	var a Alignment
+
+	actor, err := database.RetrieveActor(actorType, actorID)
+	if err != nil {
+		return a, errors.Wrap(err, "ScoreActor() failed")
+	}
+
+	pledges, err := actor.GetPledges()
+	if err != nil {
+		return a, errors.Wrap(err, "ScoreActor() failed")
+	}
+
+	status, err := GetStatus()
+	if err != nil {
+		return a, errors.Wrap(err, "ScoreActor() failed")
+	}
+
+	a = ScorePledges(pledges, status)
+	a.ActorType = actorType
+	a.ActorID = actorID
+	return a, nil
+}
@@ -0,0 +1,339 @@
+package paris
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/YaleOpenLab/openclimate/globals"
+	"github.com/pkg/errors"
+)
+
+/*
+
+	Computes the state of the global carbon budget from the datasets bundled
+	in staticdata/. All emissions in the Global Carbon Project datasets are
+	reported in GtC/yr and are converted to GtCO2 here.
+
+	The remaining budgets are the IPCC SR1.5 (Table 2.2) estimates of the
+	CO2 that can still be emitted from the start of 2018 while limiting
+	warming to the given target with the given likelihood. Emissions after
+	the last year covered by the bundled data are extrapolated at the latest
+	annual rate.
+
+*/
+
+const (
+	// GtC to GtCO2 conversion factor (molecular weight of CO2 / C)
+	carbonToCO2 = 44.0 / 12.0
+
+	// Transient climate response to cumulative emissions of CO2 in degrees
+	// celsius per GtCO2 (IPCC SR1.5 central estimate of 0.45C / 1000 GtCO2)
+	tcre = 0.45 / 1000
+
+	// first year for which the SR1.5 remaining budgets are defined
+	budgetBaseYear = 2018
+
+	// years used as the pre-industrial reference for the temperature anomaly
+	preIndustrialStart = 1880
+	preIndustrialEnd   = 1900
+)
+
+// Budget is a remaining carbon budget for a given warming target
+type Budget struct {
+	Target     float64 `json:"target"`     // degrees celsius above pre-industrial levels
+	Likelihood int     `json:"likelihood"` // percent chance of staying below the target
+	GtCO2      float64 `json:"gt_co2"`     // remaining budget from the start of budgetBaseYear
+}
+
+// Budgets holds the IPCC SR1.5 remaining carbon budgets
+var Budgets = []Budget{
+	{Target: 1.5, Likelihood: 66, GtCO2: 420},
+	{Target: 1.5, Likelihood: 50, GtCO2: 580},
+	{Target: 2, Likelihood: 66, GtCO2: 1170},
+	{Target: 2, Likelihood: 50, GtCO2: 1500},
+}
+
+// HeadlineBudget is the budget reported on the earth status page
+var HeadlineBudget = Budgets[0]
+
+// AnnualEmissions holds the global fossil and land use emissions for a year
+type AnnualEmissions struct {
+	Year          int
+	FossilFuel    float64 // GtCO2
+	LandUseChange float64 // GtCO2
+}
+
+// Total returns the total CO2 emitted in the year in GtCO2
+func (a AnnualEmissions) Total() float64 {
+	return a.FossilFuel + a.LandUseChange
+}
+
+// RemainingBudget is the state of a Budget as of a given year
+type RemainingBudget struct {
+	Budget
+	Remaining     float64 `json:"remaining_gt_co2"`
+	DepletionYear float64 `json:"depletion_year"`
+}
+
+// Status holds the state of the earth computed from the bundled datasets
+type Status struct {
+	AsOf            int               `json:"as_of"`
+	DataYear        int               `json:"data_year"`
+	Warming         float64           `json:"warming_in_c"`
+	AtmosCO2        float64           `json:"atmospheric_co2_ppm"`
+	AnnualEmissions float64           `json:"annual_global_emission"`
+	Cumulative      float64           `json:"cumulative_gt_co2"`
+	Budgets         []RemainingBudget `json:"budgets"`
+}
+
+type globalBudgetRecord struct {
+	Year          int     `json:"Year"`
+	FossilFuel    float64 `json:"Fossil-Fuel-And-Industry"`
+	LandUseChange float64 `json:"Land-Use-Change-Emissions"`
+}
+
+type historicalBudgetRecord struct {
+	Year          int     `json:"Year"`
+	FossilFuel    float64 `json:"Fossil-Fuel-Industry"`
+	LandUseChange float64 `json:"Land-Use-Change"`
+}
+
+type co2Record struct {
+	Year int     `json:"year"`
+	Mean float64 `json:"mean"`
+}
+
+// LoadEmissions reads the global carbon budget datasets and returns the
+// global emissions in GtCO2 for every year since 1750, sorted by year. The
+// global budget (which includes land use change since 1959) takes precedence
+// over the historical budget where both cover a year.
+func LoadEmissions() ([]AnnualEmissions, error) {
+	var arr []AnnualEmissions
+
+	var historical map[string]historicalBudgetRecord
+	err := readJson(globals.StDataDir+"/historical_budget.json", &historical)
+	if err != nil {
+		return arr, errors.Wrap(err, "could not read historical budget")
+	}
+
+	var global map[string]globalBudgetRecord
+	err = readJson(globals.StDataDir+"/global_carbon_budget.json", &global)
+	if err != nil {
+		return arr, errors.Wrap(err, "could not read global carbon budget")
+	}
+
+	years := make(map[int]AnnualEmissions)
+	for _, val := range historical {
+		years[val.Year] = AnnualEmissions{
+			Year:          val.Year,
+			FossilFuel:    val.FossilFuel * carbonToCO2,
+			LandUseChange: val.LandUseChange * carbonToCO2,
+		}
+	}
+
+	for _, val := range global {
+		years[val.Year] = AnnualEmissions{
+			Year:          val.Year,
+			FossilFuel:    val.FossilFuel * carbonToCO2,
+			LandUseChange: val.LandUseChange * carbonToCO2,
+		}
+	}
+
+	for _, val := range years {
+		arr = append(arr, val)
+	}
+
+	if len(arr) == 0 {
+		return arr, errors.New("no emissions data found")
+	}
+
+	sort.Slice(arr, func(i, j int) bool {
+		return arr[i].Year < arr[j].Year
+	})
+	return arr, nil
+}
+
+// LatestAtmosCO2 returns the most recent annual mean atmospheric CO2
+// concentration (in ppm) and the year it was measured
+func LatestAtmosCO2() (float64, int, error) {
+	var records map[string]co2Record
+	err := readJson(globals.EarthDataDir+"/co2_annmean.json", &records)
+	if err != nil {
+		return 0, 0, errors.Wrap(err, "could not read atmospheric co2 data")
+	}
+
+	var latest co2Record
+	for _, val := range records {
+		if val.Year > latest.Year {
+			latest = val
+		}
+	}
+
+	if latest.Year == 0 {
+		return 0, 0, errors.New("no atmospheric co2 data found")
+	}
+	return latest.Mean, latest.Year, nil
+}
+
+// CurrentWarming returns the warming above pre-industrial levels (in degrees
+// celsius) as of the latest year in the NASA land-ocean temperature index.
+// The latest smoothed anomaly is compared against the mean anomaly over
+// preIndustrialStart-preIndustrialEnd.
+func CurrentWarming() (float64, int, error) {
+	file, err := os.Open(globals.TemperatureDataPath)
+	if err != nil {
+		return 0, 0, errors.Wrap(err, "could not open temperature data")
+	}
+	defer file.Close()
+
+	var baseline, latest float64
+	var count, latestYear int
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// rows are of the form: Year No_Smoothing Lowess(5)
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 3 {
+			continue
+		}
+
+		year, err := strconv.Atoi(fields[0])
+		if err != nil {
+			continue // header
+		}
+
+		anomaly, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			return 0, 0, errors.Wrap(err, "could not parse temperature anomaly")
+		}
+
+		smoothed, err := strconv.ParseFloat(fields[2], 64)
+		if err != nil {
+			return 0, 0, errors.Wrap(err, "could not parse temperature anomaly")
+		}
+
+		if year >= preIndustrialStart && year <= preIndustrialEnd {
+			baseline += anomaly
+			count++
+		}
+
+		if year > latestYear {
+			latestYear = year
+			latest = smoothed
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return 0, 0, errors.Wrap(err, "could not read temperature data")
+	}
+
+	if count == 0 {
+		return 0, 0, errors.New("temperature data does not cover the pre-industrial reference period")
+	}
+
+	return latest - baseline/float64(count), latestYear, nil
+}
+
+// Remaining computes how much of the given budget is left at the start of
+// asOf, assuming emissions after the last year in the data continue at the
+// latest annual rate
+func Remaining(b Budget, emissions []AnnualEmissions, asOf int) RemainingBudget {
+	var rb RemainingBudget
+	rb.Budget = b
+
+	latest := emissions[len(emissions)-1]
+	used := 0.0
+	for year := budgetBaseYear; year < asOf; year++ {
+		if year <= latest.Year {
+			used += emissionsInYear(emissions, year)
+		} else {
+			used += latest.Total()
+		}
+	}
+
+	rb.Remaining = b.GtCO2 - used
+	if rb.Remaining < 0 {
+		rb.Remaining = 0
+	}
+
+	rb.DepletionYear = float64(asOf)
+	if latest.Total() > 0 {
+		rb.DepletionYear += rb.Remaining / latest.Total()
+	}
+	return rb
+}
+
+// GetStatus computes the status of the earth and all remaining budgets as
+// of the start of the current year
+func GetStatus() (Status, error) {
+	return GetStatusAsOf(time.Now().Year())
+}
+
+// GetStatusAsOf computes the status of the earth and all remaining budgets
+// as of the start of the given year
+func GetStatusAsOf(asOf int) (Status, error) {
+	var status Status
+
+	emissions, err := LoadEmissions()
+	if err != nil {
+		return status, errors.Wrap(err, "GetStatus() failed")
+	}
+
+	status.Warming, _, err = CurrentWarming()
+	if err != nil {
+		return status, errors.Wrap(err, "GetStatus() failed")
+	}
+
+	status.AtmosCO2, _, err = LatestAtmosCO2()
+	if err != nil {
+		return status, errors.Wrap(err, "GetStatus() failed")
+	}
+
+	latest := emissions[len(emissions)-1]
+	status.AsOf = asOf
+	status.DataYear = latest.Year
+	status.AnnualEmissions = latest.Total()
+
+	for _, val := range emissions {
+		status.Cumulative += val.Total()
+	}
+
+	for _, b := range Budgets {
+		status.Budgets = append(status.Budgets, Remaining(b, emissions, asOf))
+	}
+
+	return status, nil
+}
+
+// Headline returns the remaining budget reported on the earth status page
+func (s Status) Headline() RemainingBudget {
+	for _, b := range s.Budgets {
+		if b.Budget == HeadlineBudget {
+			return b
+		}
+	}
+	return RemainingBudget{Budget: HeadlineBudget}
+}
+
+func emissionsInYear(emissions []AnnualEmissions, year int) float64 {
+	for _, val := range emissions {
+		if val.Year == year {
+			return val.Total()
+		}
+	}
+	return 0
+}
+
+func readJson(path string, x interface{}) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, x)
+}
@@ -0,0 +1,366 @@
+package score
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/YaleOpenLab/openclimate/aggregation"
+	"github.com/YaleOpenLab/openclimate/database"
+	"github.com/YaleOpenLab/openclimate/paris"
+	"github.com/pkg/errors"
+)
+
+/*
+
+	Rates how accountable a climate actor is on a scale from 0 to 100. The
+	score is the weighted average of four components, each between 0 and 1:
+
+	- completeness: the share of the expected data (emissions, mitigation
+		and adaptation reports, and the three emission scopes) the actor
+		reported for its latest reporting year
+	- verification: how well the reports of that year are vouched for by
+		oversight organizations, each counting with its weight
+	- ambition: how close the actor's emission reduction pledges come to
+		being 1.5C aligned (see paris/alignment.go)
+	- progress: how much of the reduction its pledges called for by its
+		latest reported emissions the actor achieved
+
+	The score lists every component with its weight and the points it
+	contributed, and breaks the actor's emissions down by how they can be
+	held to account (direct, indirect and untrackable).
+
+*/
+
+// Score is the accountability score of a climate actor
+type Score struct {
+	ActorType string
+	ActorID   int
+	Year      int // latest year the actor reported in, 0 if it never reported
+
+	Weights    Weights
+	Components []Component
+	Total      float64 // 0 to 100
+
+	Accountability Accountability
+}
+
+// Component is one of the components of the score
+type Component struct {
+	Name   string
+	Weight float64 // share of the total score, the weights add up to 1
+	Value  float64 // 0 to 1
+	Points float64 // Weight * Value * 100
+	Notes  []string
+}
+
+// Names of the score components
+const (
+	Completeness = "completeness"
+	Verification = "verification"
+	Ambition     = "ambition"
+	Progress     = "progress"
+)
+
+/*
+	Accountability breaks down the scope 1 emissions of an actor in a year
+	(tCO2e) by who accounts for them:
+	- Direct: reported by the actor's parts (its assets, or the companies,
+		cities, states and regions within it) themselves
+	- Indirect: aggregated from the parts' own parts
+	- Untrackable: reported by the actor but not covered by any of its parts
+*/
+type Accountability struct {
+	Total       float64
+	Direct      float64
+	Indirect    float64
+	Untrackable float64
+}
+
+// ScoreActor computes the accountability score of a climate actor with the
+// given weights
+func ScoreActor(actorType string, actorID int, weights Weights) (Score, error) {
+	var s Score
+	s.ActorType = actorType
+	s.ActorID = actorID
+	s.Weights = weights
+
+	err := weights.Validate()
+	if err != nil {
+		return s, err
+	}
+
+	actor, err := database.RetrieveActor(actorType, actorID)
+	if err != nil {
+		return s, errors.Wrap(err, "could not retrieve actor")
+	}
+
+	pledges, err := actor.GetPledges()
+	if err != nil {
+		return s, errors.Wrap(err, "could not retrieve pledges")
+	}
+
+	reports, err := database.RetrieveActorReports(actorType, actorID, "")
+	if err != nil {
+		return s, errors.Wrap(err, "could not retrieve reports")
+	}
+
+	var latest []database.Report
+	for _, report := range reports {
+		if report.Year > s.Year {
+			s.Year = report.Year
+			latest = nil
+		}
+		if report.Year == s.Year {
+			latest = append(latest, report)
+		}
+	}
+
+	ver, err := verification(latest, weights.VerificationTarget)
+	if err != nil {
+		return s, err
+	}
+
+	amb, err := ambition(pledges)
+	if err != nil {
+		return s, err
+	}
+
+	prog, err := progress(actorType, actorID, pledges)
+	if err != nil {
+		return s, err
+	}
+
+	s.Components = []Component{completeness(latest), ver, amb, prog}
+
+	total := weights.sum()
+	for i, c := range s.Components {
+		switch c.Name {
+		case Completeness:
+			c.Weight = weights.Completeness / total
+		case Verification:
+			c.Weight = weights.Verification / total
+		case Ambition:
+			c.Weight = weights.Ambition / total
+		case Progress:
+			c.Weight = weights.Progress / total
+		}
+		c.Points = 100 * c.Weight * c.Value
+		s.Total += c.Points
+		s.Components[i] = c
+	}
+
+	if s.Year != 0 {
+		s.Accountability, err = accountability(actorType, actorID, s.Year)
+		if err != nil {
+			return s, err
+		}
+	}
+	return s, nil
+}
+
+// completeness checks which report types and emission scopes an actor
+// reported in its latest reports
+func completeness(reports []database.Report) Component {
+	var c Component
+	c.Name = Completeness
+
+	reported := make(map[string]bool)
+	for _, report := range reports {
+		reported[report.ReportType+" report"] = true
+		if report.Emissions != nil {
+			reported["scope 1"] = reported["scope 1"] || report.Emissions.TotalScope1CO2e != 0
+			reported["scope 2"] = reported["scope 2"] || report.Emissions.TotalScope2CO2e != 0
+			reported["scope 3"] = reported["scope 3"] || report.Emissions.TotalScope3CO2e != 0
+		}
+	}
+
+	items := []string{"Emissions report", "Mitigation report", "Adaptation report", "scope 1", "scope 2", "scope 3"}
+	for _, item := range items {
+		if reported[item] {
+			c.Value++
+		} else {
+			c.Notes = append(c.Notes, "missing "+item)
+		}
+	}
+	c.Value /= float64(len(items))
+	return c
+}
+
+// verification averages how well each report is verified: the total weight
+// of the oversight organizations that verified it relative to the target
+func verification(reports []database.Report, target float64) (Component, error) {
+	var c Component
+	c.Name = Verification
+	if len(reports) == 0 {
+		c.Notes = append(c.Notes, "no reports")
+		return c, nil
+	}
+
+	osOrgs, err := database.RetrieveAllOsOrgs()
+	if err != nil {
+		return c, errors.Wrap(err, "could not retrieve oversight organizations")
+	}
+
+	weights := make(map[int]float64)
+	for _, osOrg := range osOrgs {
+		weights[osOrg.Index] = float64(osOrg.Weight)
+		if osOrg.Weight == 0 {
+			weights[osOrg.Index] = 1
+		}
+	}
+
+	for _, report := range reports {
+		var weight float64
+		for _, v := range report.Verifications {
+			weight += weights[v.OversightID]
+		}
+
+		if weight >= target {
+			c.Value++
+		} else {
+			c.Value += weight / target
+		}
+		c.Notes = append(c.Notes, fmt.Sprintf("%s report verified by %d oversight organizations (weight %g of %g)",
+			report.ReportType, len(report.Verifications), weight, target))
+	}
+	c.Value /= float64(len(reports))
+	return c, nil
+}
+
+// ambition scores an actor's emission reduction pledges against the carbon
+// budget
+func ambition(pledges []database.Pledge) (Component, error) {
+	var c Component
+	c.Name = Ambition
+
+	status, err := paris.GetStatus()
+	if err != nil {
+		return c, errors.Wrap(err, "could not compute the carbon budget")
+	}
+
+	alignment := paris.ScorePledges(pledges, status)
+	c.Value = alignment.Score / 100
+	c.Notes = append(c.Notes, fmt.Sprintf("%s (implied warming %.2fC)", alignment.Rating, alignment.ImpliedWarming))
+	return c, nil
+}
+
+/*
+	progress compares the latest scope 1 and 2 emissions an actor reported
+	with the emissions each of its reduction pledges called for by then,
+	relative to the emissions it reported in the pledge's base year. A
+	pledge whose base year emissions weren't reported can't be tracked and
+	counts as no progress.
+*/
+func progress(actorType string, actorID int, pledges []database.Pledge) (Component, error) {
+	var c Component
+	c.Name = Progress
+
+	emissions, err := annualEmissions(actorType, actorID)
+	if err != nil {
+		return c, err
+	}
+
+	var latest int
+	for year := range emissions {
+		if year > latest {
+			latest = year
+		}
+	}
+
+	var count int
+	for _, p := range pledges {
+		if !paris.IsReductionPledge(p) {
+			continue
+		}
+		count++
+
+		base, ok := emissions[int(p.BaseYear)]
+		if !ok || base == 0 || latest <= int(p.BaseYear) {
+			c.Notes = append(c.Notes, fmt.Sprintf("no emissions reported for base year %g and after", p.BaseYear))
+			continue
+		}
+
+		expected := 1 - paris.Fraction(p, float64(latest))
+		achieved := 1 - emissions[latest]/base
+
+		var value float64
+		switch {
+		case achieved >= expected:
+			value = 1
+		case achieved > 0:
+			value = achieved / expected
+		}
+		c.Value += value
+		c.Notes = append(c.Notes, fmt.Sprintf("reduced emissions by %.1f%% from %g to %d, %.1f%% pledged by then",
+			100*achieved, p.BaseYear, latest, 100*expected))
+	}
+
+	if count == 0 {
+		c.Notes = append(c.Notes, "no emission reduction pledge")
+		return c, nil
+	}
+	c.Value /= float64(count)
+	return c, nil
+}
+
+// annualEmissions returns the scope 1 and 2 emissions an actor reported by year
+func annualEmissions(actorType string, actorID int) (map[int]float64, error) {
+	emissions := make(map[int]float64)
+	for _, metric := range []string{database.MetricScope1, database.MetricScope2} {
+		var q database.SeriesQuery
+		q.ActorType = actorType
+		q.ActorID = actorID
+		q.Metric = metric
+		q.Granularity = database.Annual
+		q.Sources = []string{database.SourceSelfReported}
+
+		series, err := database.QuerySeries(q)
+		if err != nil {
+			return emissions, errors.Wrap(err, "could not query time series")
+		}
+
+		for _, value := range series[database.SourceSelfReported] {
+			year, err := strconv.Atoi(value.Period)
+			if err != nil {
+				continue
+			}
+			emissions[year] += value.Value
+		}
+	}
+	return emissions, nil
+}
+
+// accountability breaks down an actor's scope 1 emissions with the parts
+// they are aggregated from
+func accountability(actorType string, actorID int, year int) (Accountability, error) {
+	var a Accountability
+
+	result, err := aggregation.Aggregate(actorType, actorID, database.MetricScope1, year, "")
+	if err == aggregation.ErrActorNotFound {
+		// actor types that aren't aggregated (e.g. oversight)
+		return a, nil
+	}
+	if err != nil {
+		return a, errors.Wrap(err, "could not aggregate emissions")
+	}
+
+	for _, part := range result.Parts {
+		if part.Reported {
+			a.Direct += part.Value
+		} else {
+			a.Indirect += part.Value
+		}
+	}
+
+	// emissions counted by more than one part are only accounted for once
+	if result.SumOfParts > 0 {
+		a.Direct *= result.Deduplicated / result.SumOfParts
+		a.Indirect *= result.Deduplicated / result.SumOfParts
+	}
+
+	if result.Unattributed > 0 {
+		a.Untrackable = result.Unattributed
+	}
+	a.Total = a.Direct + a.Indirect + a.Untrackable
+	return a, nil
+}
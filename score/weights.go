@@ -0,0 +1,63 @@
+package score
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math"
+
+	globals "github.com/YaleOpenLab/openclimate/globals"
+	"github.com/pkg/errors"
+)
+
+// Weights are the weights of the components of the accountability score.
+// They don't need to add up to 1, the score is divided by their sum.
+type Weights struct {
+	Completeness float64
+	Verification float64
+	Ambition     float64
+	Progress     float64
+
+	// total weight of the oversight organizations that verified a report for
+	// it to count as fully verified. Organizations without a weight count as 1.
+	VerificationTarget float64
+}
+
+// LoadWeights reads the default weights from globals.ScoreWeightsPath
+func LoadWeights() (Weights, error) {
+	var w Weights
+
+	data, err := ioutil.ReadFile(globals.ScoreWeightsPath)
+	if err != nil {
+		return w, errors.Wrap(err, "could not read score weights")
+	}
+
+	err = json.Unmarshal(data, &w)
+	if err != nil {
+		return w, errors.Wrap(err, "could not parse score weights")
+	}
+	return w, w.Validate()
+}
+
+// Validate checks that the weights are finite numbers, not negative and that
+// at least one component is weighted
+func (w Weights) Validate() error {
+	for _, x := range []float64{w.Completeness, w.Verification, w.Ambition, w.Progress, w.VerificationTarget} {
+		if math.IsNaN(x) || math.IsInf(x, 0) {
+			return errors.New("weights must be finite numbers")
+		}
+	}
+	if w.Completeness < 0 || w.Verification < 0 || w.Ambition < 0 || w.Progress < 0 {
+		return errors.New("weights cannot be negative")
+	}
+	if w.sum() == 0 {
+		return errors.New("at least one component must have a weight")
+	}
+	if w.VerificationTarget <= 0 {
+		return errors.New("verification target must be positive")
+	}
+	return nil
+}
+
+func (w Weights) sum() float64 {
+	return w.Completeness + w.Verification + w.Ambition + w.Progress
+}
@@ -188,7 +188,7 @@ func getCity() {
 
 func getAllCompanies() {
 	http.HandleFunc("/company/all", func(w http.ResponseWriter, r *http.Request) {
-		err := erpc.CheckGet(w, r)
+		viewer, err := CheckGetViewer(w, r)
 		if err != nil {
 			return
 		}
@@ -200,13 +200,23 @@ func getAllCompanies() {
 			return
 		}
 
+		d := newDisclosure(viewer)
+		for i, company := range companies {
+			companies[i], err = d.discloseCompany(company)
+			if err != nil {
+				log.Println(err)
+				erpc.ResponseHandler(w, erpc.StatusInternalServerError)
+				return
+			}
+		}
+
 		erpc.MarshalSend(w, companies)
 	})
 }
 
 func getCompany() {
 	http.HandleFunc("/company", func(w http.ResponseWriter, r *http.Request) {
-		err := erpc.CheckGet(w, r)
+		viewer, err := CheckGetViewer(w, r)
 		if err != nil {
 			return
 		}
@@ -224,6 +234,13 @@ func getCompany() {
 			return
 		}
 
+		company, err = newDisclosure(viewer).discloseCompany(company)
+		if err != nil {
+			log.Println(err)
+			erpc.ResponseHandler(w, erpc.StatusInternalServerError)
+			return
+		}
+
 		erpc.MarshalSend(w, company)
 	})
 }
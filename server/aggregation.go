@@ -0,0 +1,89 @@
+package server
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	erpc "github.com/Varunram/essentials/rpc"
+	"github.com/YaleOpenLab/openclimate/aggregation"
+	db "github.com/YaleOpenLab/openclimate/database"
+)
+
+func setupAggregationHandlers() {
+	getAggregate()
+}
+
+/*
+	Rolls the emissions of an actor's parts (assets, companies, cities,
+	states and regions) up to the actor and returns both the sum of parts
+	and the reported total, with the emissions counted by more than one
+	part subtracted.
+
+	URL parameters:
+	- "actor_type": either city, country, region, state or company.
+	- "actor_id": the ID assigned to the actor in the database.
+	- "year": the year to aggregate.
+	- "metric" (optional): e.g. scope1, scope2, scope3. scope1 if not set.
+	- "source" (optional): the time series source to use, self-reported if not set.
+	- "username", "access_token" (optional): the viewer's credentials
+
+	Self-reported values of the actor and its parts are subject to their
+	disclosure policies: parts the viewer can't see are counted in the
+	totals but not listed.
+*/
+func getAggregate() {
+	http.HandleFunc("/aggregate", func(w http.ResponseWriter, r *http.Request) {
+		viewer, err := CheckGetViewer(w, r)
+		if err != nil {
+			return
+		}
+
+		if !checkReqdParams(w, r, "actor_type", "actor_id", "year") {
+			return
+		}
+
+		actorType := r.URL.Query()["actor_type"][0]
+		actorID, err := strconv.Atoi(r.URL.Query()["actor_id"][0])
+		if err != nil {
+			log.Println(err)
+			erpc.ResponseHandler(w, erpc.StatusBadRequest)
+			return
+		}
+
+		year, err := strconv.Atoi(r.URL.Query()["year"][0])
+		if err != nil {
+			log.Println(err)
+			erpc.ResponseHandler(w, erpc.StatusBadRequest)
+			return
+		}
+
+		metric := r.URL.Query().Get("metric")
+		if metric == "" {
+			metric = db.MetricScope1
+		}
+
+		result, err := aggregation.Aggregate(actorType, actorID, metric, year, r.URL.Query().Get("source"))
+		if err == aggregation.ErrActorNotFound {
+			erpc.ResponseHandler(w, erpc.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Println(err)
+			erpc.ResponseHandler(w, erpc.StatusInternalServerError)
+			return
+		}
+
+		source := r.URL.Query().Get("source")
+		if source == "" || source == db.SourceSelfReported || source == db.SourceAssets {
+			result, err = newDisclosure(viewer).discloseAggregate(result)
+			if err != nil {
+				log.Println(err)
+				erpc.ResponseHandler(w, erpc.StatusInternalServerError)
+				return
+			}
+		}
+
+		erpc.MarshalSend(w, result)
+	})
+}
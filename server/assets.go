@@ -0,0 +1,239 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+
+	erpc "github.com/Varunram/essentials/rpc"
+	db "github.com/YaleOpenLab/openclimate/database"
+	"github.com/YaleOpenLab/openclimate/ghg"
+)
+
+func setupAssetHandlers() {
+	reportAssetData()
+	getAssetReports()
+	getCompanyAssetTotals()
+}
+
+/*
+	Report the production and emissions of one of the logged in user's
+	company's assets in a month or a year. Reporting a period again corrects
+	the earlier report, which is kept in the report's corrections.
+
+	Form parameters:
+	- "asset_id": the ID of the asset
+	- "period": either a year (YYYY) or a month (YYYY-MM)
+	- "generation" (optional): electricity generated in MWh
+	- "capacity_factor" (optional): generation relative to running at
+		nameplate capacity all period, computed from the asset's capacity if not set
+	- "avoided_emissions" (optional): emissions avoided in tCO2e, computed
+		from the generation and the grid's emission factor for renewable assets
+	- "fuel_use" (optional): JSON list of the fuels burned (Fuel, Quantity
+		and Unit), converted to emissions with the emission factor library
+	- "reason" (optional): why an earlier report is corrected
+*/
+func reportAssetData() {
+	http.HandleFunc("/asset/report", func(w http.ResponseWriter, r *http.Request) {
+		user, err := CheckPostAuth(w, r)
+		if err != nil {
+			return
+		}
+
+		err = r.ParseForm()
+		if err != nil {
+			erpc.ResponseHandler(w, erpc.StatusBadRequest)
+			return
+		}
+
+		if !checkReqdPostParams(w, r, "asset_id", "period") {
+			return
+		}
+
+		assetID, err := strconv.Atoi(r.FormValue("asset_id"))
+		if err != nil {
+			erpc.ResponseHandler(w, erpc.StatusBadRequest)
+			return
+		}
+
+		asset, err := db.RetrieveAsset(assetID)
+		if err != nil {
+			log.Println(err)
+			erpc.ResponseHandler(w, erpc.StatusNotFound)
+			return
+		}
+
+		if user.EntityType != "company" || user.EntityID != asset.CompanyID {
+			erpc.ResponseHandler(w, erpc.StatusUnauthorized)
+			return
+		}
+
+		var report db.AssetReport
+		report.Period = r.FormValue("period")
+		report.UserID = user.Index
+		if !db.ValidPeriod(report.Period) {
+			erpc.ResponseHandler(w, erpc.StatusBadRequest)
+			return
+		}
+		year, _ := strconv.Atoi(report.Period[:4])
+
+		values := map[string]*float64{
+			"generation":        &report.Generation,
+			"capacity_factor":   &report.CapacityFactor,
+			"avoided_emissions": &report.AvoidedEmissions,
+		}
+		for param, value := range values {
+			if r.FormValue(param) == "" {
+				continue
+			}
+			// ParseFloat accepts NaN and Inf, which can't be stored
+			*value, err = strconv.ParseFloat(r.FormValue(param), 64)
+			if err != nil || math.IsNaN(*value) || math.IsInf(*value, 0) {
+				erpc.ResponseHandler(w, erpc.StatusBadRequest)
+				return
+			}
+		}
+
+		if fuelUse := r.FormValue("fuel_use"); fuelUse != "" {
+			err = json.Unmarshal([]byte(fuelUse), &report.FuelUse)
+			if err != nil {
+				erpc.ResponseHandler(w, erpc.StatusBadRequest)
+				return
+			}
+
+			report.FuelUse, report.Emissions, err = ghg.ComputeFuelUse(report.FuelUse, year, ghg.DefaultGWPSet())
+			if err != nil {
+				log.Println(err)
+				erpc.ResponseHandler(w, erpc.StatusBadRequest)
+				return
+			}
+		}
+
+		if r.FormValue("avoided_emissions") == "" && report.Generation > 0 && ghg.IsRenewable(asset) {
+			avoided, err := ghg.AvoidedEmissions(asset, report.Generation/1e3, year, ghg.DefaultGWPSet())
+			if err != nil {
+				log.Println(err)
+				erpc.ResponseHandler(w, erpc.StatusBadRequest)
+				return
+			}
+			report.AvoidedEmissions = avoided.AvoidedEmissions
+		}
+
+		report, err = asset.ReportAssetData(report, r.FormValue("reason"))
+		if err != nil {
+			log.Println(err)
+			erpc.ResponseHandler(w, erpc.StatusBadRequest)
+			return
+		}
+
+		erpc.MarshalSend(w, report)
+	})
+}
+
+/*
+	Retrieve the production and emissions reported for an asset, with the
+	corrections made to each report. Only available if the asset's company
+	discloses its asset data to the viewer in full.
+
+	URL parameters:
+	- "asset_id": the ID of the asset
+	- "username", "access_token" (optional): the viewer's credentials
+*/
+func getAssetReports() {
+	http.HandleFunc("/asset/reports", func(w http.ResponseWriter, r *http.Request) {
+		viewer, err := CheckGetViewer(w, r)
+		if err != nil {
+			return
+		}
+
+		if !checkReqdParams(w, r, "asset_id") {
+			return
+		}
+
+		assetID, err := strconv.Atoi(r.URL.Query()["asset_id"][0])
+		if err != nil {
+			erpc.ResponseHandler(w, erpc.StatusBadRequest)
+			return
+		}
+
+		asset, err := db.RetrieveAsset(assetID)
+		if err != nil {
+			log.Println(err)
+			erpc.ResponseHandler(w, erpc.StatusNotFound)
+			return
+		}
+
+		access, err := newDisclosure(viewer).access("company", asset.CompanyID, db.FieldAssets)
+		if err != nil {
+			log.Println(err)
+			erpc.ResponseHandler(w, erpc.StatusInternalServerError)
+			return
+		}
+		if access != db.AccessFull {
+			erpc.ResponseHandler(w, erpc.StatusUnauthorized)
+			return
+		}
+
+		erpc.MarshalSend(w, asset.Reports)
+	})
+}
+
+/*
+	Sum up the production, avoided emissions and emissions of a company's
+	assets in a period. The assets included are only listed if the company
+	discloses its asset data to the viewer in full.
+
+	URL parameters:
+	- "company_id": the ID of the company
+	- "period": either a year (YYYY) or a month (YYYY-MM)
+	- "username", "access_token" (optional): the viewer's credentials
+*/
+func getCompanyAssetTotals() {
+	http.HandleFunc("/company/assets/totals", func(w http.ResponseWriter, r *http.Request) {
+		viewer, err := CheckGetViewer(w, r)
+		if err != nil {
+			return
+		}
+
+		if !checkReqdParams(w, r, "company_id", "period") {
+			return
+		}
+
+		companyID, err := strconv.Atoi(r.URL.Query()["company_id"][0])
+		if err != nil {
+			erpc.ResponseHandler(w, erpc.StatusBadRequest)
+			return
+		}
+
+		period := r.URL.Query()["period"][0]
+		if !db.ValidPeriod(period) {
+			erpc.ResponseHandler(w, erpc.StatusBadRequest)
+			return
+		}
+
+		access, err := newDisclosure(viewer).access("company", companyID, db.FieldAssets)
+		if err != nil {
+			log.Println(err)
+			erpc.ResponseHandler(w, erpc.StatusInternalServerError)
+			return
+		}
+		if access == db.AccessNone {
+			erpc.ResponseHandler(w, erpc.StatusUnauthorized)
+			return
+		}
+
+		totals, err := db.CompanyAssetTotals(companyID, period)
+		if err != nil {
+			log.Println(err)
+			erpc.ResponseHandler(w, erpc.StatusInternalServerError)
+			return
+		}
+		if access != db.AccessFull {
+			totals.Assets = nil
+		}
+
+		erpc.MarshalSend(w, totals)
+	})
+}
@@ -0,0 +1,469 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	erpc "github.com/Varunram/essentials/rpc"
+	"github.com/YaleOpenLab/openclimate/aggregation"
+	db "github.com/YaleOpenLab/openclimate/database"
+	"github.com/YaleOpenLab/openclimate/ipfs"
+	"github.com/YaleOpenLab/openclimate/score"
+)
+
+func setupDisclosureHandlers() {
+	getDisclosurePolicy()
+	updateDisclosurePolicy()
+}
+
+// disclosure looks up how much of the data of each actor a viewer can see,
+// caching the policies it retrieves
+type disclosure struct {
+	viewer   db.User
+	policies map[string]db.DisclosurePolicy
+	assets   map[int]int // company ID by asset ID
+}
+
+func newDisclosure(viewer db.User) *disclosure {
+	var d disclosure
+	d.viewer = viewer
+	d.policies = make(map[string]db.DisclosurePolicy)
+	d.assets = make(map[int]int)
+	return &d
+}
+
+// access returns how much of a field of an actor the viewer can see. The
+// data of an asset is only visible if its company discloses its asset data
+// in full, since asset data is itself a breakdown of the company's totals.
+func (d *disclosure) access(actorType string, actorID int, field string) (int, error) {
+	if actorType == "asset" {
+		companyID, ok := d.assets[actorID]
+		if !ok {
+			asset, err := db.RetrieveAsset(actorID)
+			if err != nil {
+				return db.AccessNone, err
+			}
+			companyID = asset.CompanyID
+			d.assets[actorID] = companyID
+		}
+
+		access, err := d.access("company", companyID, db.FieldAssets)
+		if access != db.AccessFull {
+			return db.AccessNone, err
+		}
+		return access, nil
+	}
+
+	k := actorType + "/" + strconv.Itoa(actorID)
+	policy, ok := d.policies[k]
+	if !ok {
+		var err error
+		policy, err = db.RetrieveDisclosurePolicy(actorType, actorID)
+		if err != nil {
+			return db.AccessNone, err
+		}
+		d.policies[k] = policy
+	}
+	return policy.Access(field, d.viewer), nil
+}
+
+// reportField returns the disclosure field of a report type
+func reportField(reportType string) string {
+	switch reportType {
+	case "Emissions":
+		return db.FieldEmissions
+	case "Mitigation":
+		return db.FieldMitigation
+	default:
+		return db.FieldAdaptation
+	}
+}
+
+// metricField returns the disclosure field of a time series metric, and
+// whether the metric is a breakdown of a total (e.g. a single gas)
+func metricField(metric string) (string, bool) {
+	switch {
+	case metric == db.MetricMitigation || metric == db.MetricGeneration:
+		return db.FieldMitigation, false
+	case strings.HasPrefix(metric, db.MetricMitigation+"/"):
+		return db.FieldMitigation, true
+	case metric == db.MetricScope2Market:
+		return db.FieldEmissions, false
+	default:
+		return db.FieldEmissions, strings.Contains(metric, "/")
+	}
+}
+
+// discloseReports leaves out the reports the viewer can't see, and the
+// breakdowns (activities, gases, electricity use, children) and IPFS hashes
+// of the reports the viewer only sees totals of
+func (d *disclosure) discloseReports(reports []db.Report) ([]db.Report, error) {
+	var disclosed []db.Report
+	for _, report := range reports {
+		access, err := d.access(report.ActorType, report.ActorID, reportField(report.ReportType))
+		if err != nil {
+			return disclosed, err
+		}
+
+		switch access {
+		case db.AccessNone:
+			continue
+		case db.AccessAggregate:
+			// the report on IPFS holds the breakdowns
+			report.IpfsHash = ""
+			if report.Emissions != nil {
+				x := *report.Emissions
+				x.Electricity = nil
+				x.Activities = nil
+				x.Gases = nil
+				x.Inventory = nil
+				x.ByChild = nil
+				report.Emissions = &x
+			}
+			if report.Mitigation != nil {
+				x := *report.Mitigation
+				x.ByChild = nil
+				report.Mitigation = &x
+			}
+			if report.Adaptation != nil {
+				x := *report.Adaptation
+				x.ByChild = nil
+				report.Adaptation = &x
+			}
+		}
+		disclosed = append(disclosed, report)
+	}
+	return disclosed, nil
+}
+
+// discloseAggregate leaves out the parts of an aggregation result the viewer
+// can't see. They are still included in the totals. Assets are only listed
+// if the viewer sees their company's asset data in full. If the actor
+// doesn't disclose its own total, the totals are recomputed from the parts
+// the viewer can see, and are zero if it can't see any.
+func (d *disclosure) discloseAggregate(result aggregation.Result) (aggregation.Result, error) {
+	field, _ := metricField(result.Metric)
+
+	access, err := d.access(result.ActorType, result.ActorID, field)
+	if err != nil {
+		return result, err
+	}
+	if access == db.AccessNone {
+		result.Reported = 0
+		result.HasReported = false
+		result.Unattributed = 0
+	}
+	actorHidden := access == db.AccessNone
+
+	hidden := make(map[string]bool)
+	var parts []aggregation.Part
+	for _, part := range result.Parts {
+		access, err := d.access(part.ActorType, part.ActorID, field)
+		if err != nil {
+			return result, err
+		}
+
+		if access == db.AccessNone {
+			hidden[part.ActorType+"/"+strconv.Itoa(part.ActorID)] = true
+			continue
+		}
+
+		var assets []int
+		for _, id := range part.Assets {
+			access, err := d.access("asset", id, field)
+			if err != nil {
+				return result, err
+			}
+			if access != db.AccessNone {
+				assets = append(assets, id)
+			}
+		}
+		part.Assets = assets
+		parts = append(parts, part)
+	}
+	result.Parts = parts
+
+	// the overlap between the visible parts, including assets the viewer
+	// can't see, which are still in the values of the parts
+	var overlap float64
+	var doubleCounted []aggregation.DoubleCount
+	for _, dc := range result.DoubleCounted {
+		var countedBy []string
+		for _, part := range dc.CountedBy {
+			if !hidden[part] {
+				countedBy = append(countedBy, part)
+			}
+		}
+		if len(countedBy) > 1 {
+			overlap += dc.Value * float64(len(countedBy)-1)
+		}
+
+		access, err := d.access("asset", dc.AssetID, field)
+		if err != nil {
+			return result, err
+		}
+		if access == db.AccessNone {
+			continue
+		}
+		dc.CountedBy = countedBy
+		doubleCounted = append(doubleCounted, dc)
+	}
+	result.DoubleCounted = doubleCounted
+
+	if actorHidden {
+		result.SumOfParts = 0
+		for _, part := range result.Parts {
+			result.SumOfParts += part.Value
+		}
+		result.Overlap = overlap
+		result.Deduplicated = result.SumOfParts - result.Overlap
+		result.Total = result.Deduplicated
+	}
+	return result, nil
+}
+
+// discloseDiscrepancies leaves out the discrepancies in values the viewer
+// can't see
+func (d *disclosure) discloseDiscrepancies(discrepancies []db.Discrepancy) ([]db.Discrepancy, error) {
+	var disclosed []db.Discrepancy
+	for _, x := range discrepancies {
+		field, breakdown := metricField(x.Metric)
+		access, err := d.access(x.ActorType, x.ActorID, field)
+		if err != nil {
+			return disclosed, err
+		}
+
+		if access == db.AccessFull || (access == db.AccessAggregate && !breakdown) {
+			disclosed = append(disclosed, x)
+		}
+	}
+	return disclosed, nil
+}
+
+// discloseCompany removes the asset, certificate, climate report and pledge
+// IDs of a company the viewer can't see
+func (d *disclosure) discloseCompany(company db.Company) (db.Company, error) {
+	access, err := d.access("company", company.Index, db.FieldAssets)
+	if err != nil {
+		return company, err
+	}
+	if access != db.AccessFull {
+		company.Assets = nil
+	}
+
+	access, err = d.access("company", company.Index, db.FieldEmissions)
+	if err != nil {
+		return company, err
+	}
+	if access != db.AccessFull {
+		company.Certificates = nil
+		company.ClimateReports = nil
+	}
+
+	access, err = d.access("company", company.Index, db.FieldPledges)
+	if err != nil {
+		return company, err
+	}
+	if access == db.AccessNone {
+		company.Pledges = nil
+	}
+	return company, nil
+}
+
+// disclosePledges returns an actor's pledges if the viewer can see them
+func (d *disclosure) disclosePledges(actorType string, actorID int, pledges []db.Pledge) ([]db.Pledge, error) {
+	access, err := d.access(actorType, actorID, db.FieldPledges)
+	if err != nil || access == db.AccessNone {
+		var empty []db.Pledge
+		return empty, err
+	}
+	return pledges, nil
+}
+
+// discloseScore removes the emissions an accountability score reveals if the
+// actor doesn't disclose its emissions to the viewer
+func (d *disclosure) discloseScore(s score.Score) (score.Score, error) {
+	access, err := d.access(s.ActorType, s.ActorID, db.FieldEmissions)
+	if err != nil || access != db.AccessNone {
+		return s, err
+	}
+
+	var empty score.Accountability
+	s.Accountability = empty
+	for i, c := range s.Components {
+		if c.Name == score.Progress {
+			s.Components[i].Notes = nil
+		}
+	}
+	return s, nil
+}
+
+// discloseActivities leaves out the activities about reports and pledges the
+// viewer can't see
+func (d *disclosure) discloseActivities(activities []db.Activity) ([]db.Activity, error) {
+	var disclosed []db.Activity
+	for _, a := range activities {
+		field := db.FieldPledges
+		if a.Type == db.ActivityReport || a.Type == db.ActivityVerification {
+			field = reportField(a.ReportType)
+		}
+
+		access, err := d.access(a.ActorType, a.ActorID, field)
+		if err != nil {
+			return disclosed, err
+		}
+		if access != db.AccessNone {
+			disclosed = append(disclosed, a)
+		}
+	}
+	return disclosed, nil
+}
+
+// discloseIpfsData leaves out the data an actor committed to IPFS that it
+// doesn't disclose to the viewer in full. Pledges only need to be visible.
+func (d *disclosure) discloseIpfsData(actorType string, actorID int, data map[string][]ipfs.Record) (map[string][]ipfs.Record, error) {
+	disclosed := make(map[string][]ipfs.Record)
+	for key, records := range data {
+		for _, x := range records {
+			field := db.FieldPledges
+			switch x.Data.(type) {
+			case ipfs.Emissions:
+				field = db.FieldEmissions
+			case ipfs.Mitigation:
+				field = db.FieldMitigation
+			case ipfs.Adaptation:
+				field = db.FieldAdaptation
+			}
+
+			access, err := d.access(actorType, actorID, field)
+			if err != nil {
+				return disclosed, err
+			}
+			if access == db.AccessFull || (field == db.FieldPledges && access != db.AccessNone) {
+				disclosed[key] = append(disclosed[key], x)
+			}
+		}
+	}
+	return disclosed, nil
+}
+
+/*
+	Retrieve the disclosure policy of an actor: the disclosure level (public,
+	aggregate-only, private or shared) of each field. The oversight
+	organizations data is shared with are only listed to the actor's users.
+
+	URL parameters:
+	- "actor_type": either city, country, region, state, company, etc.
+	- "actor_id": the ID assigned to the actor in the database.
+*/
+func getDisclosurePolicy() {
+	http.HandleFunc("/disclosure", func(w http.ResponseWriter, r *http.Request) {
+		viewer, err := CheckGetViewer(w, r)
+		if err != nil {
+			return
+		}
+
+		if !checkReqdParams(w, r, "actor_type", "actor_id") {
+			return
+		}
+
+		actorType := r.URL.Query()["actor_type"][0]
+		actorID, err := strconv.Atoi(r.URL.Query()["actor_id"][0])
+		if err != nil {
+			erpc.ResponseHandler(w, erpc.StatusBadRequest)
+			return
+		}
+
+		policy, err := disclosurePolicy(actorType, actorID, viewer)
+		if err != nil {
+			log.Println(err)
+			erpc.ResponseHandler(w, erpc.StatusInternalServerError)
+			return
+		}
+
+		erpc.MarshalSend(w, policy)
+	})
+}
+
+// disclosurePolicy returns an actor's disclosure policy as the viewer sees it.
+// Who the actor shares data with is only shown to its own verified users.
+func disclosurePolicy(actorType string, actorID int, viewer db.User) (db.DisclosurePolicy, error) {
+	policy, err := db.RetrieveDisclosurePolicy(actorType, actorID)
+	if err != nil {
+		return policy, err
+	}
+
+	if viewer.Index == 0 || !viewer.Verified || viewer.EntityType != actorType || viewer.EntityID != actorID {
+		policy.SharedWith = nil
+		policy.UserID = 0
+	}
+	return policy, nil
+}
+
+/*
+	Set the disclosure policy of the logged in user's entity, replacing its
+	earlier policy.
+
+	Form parameters:
+	- "default": the disclosure level of fields without their own (public,
+		aggregate-only, private or shared)
+	- "fields" (optional): JSON object with the disclosure level of each
+		field (emissions, mitigation, adaptation, assets or pledges)
+	- "shared_with" (optional): comma separated IDs of the oversight
+		organizations that see data that isn't public in full
+*/
+func updateDisclosurePolicy() {
+	http.HandleFunc("/disclosure/update", func(w http.ResponseWriter, r *http.Request) {
+		user, err := CheckPostAuth(w, r)
+		if err != nil {
+			return
+		}
+
+		err = r.ParseForm()
+		if err != nil {
+			erpc.ResponseHandler(w, erpc.StatusBadRequest)
+			return
+		}
+
+		if !checkReqdPostParams(w, r, "default") {
+			return
+		}
+
+		var policy db.DisclosurePolicy
+		policy.ActorType = user.EntityType
+		policy.ActorID = user.EntityID
+		policy.Default = r.FormValue("default")
+		policy.UserID = user.Index
+
+		if fields := r.FormValue("fields"); fields != "" {
+			err = json.Unmarshal([]byte(fields), &policy.Fields)
+			if err != nil {
+				erpc.ResponseHandler(w, erpc.StatusBadRequest)
+				return
+			}
+		}
+
+		if sharedWith := r.FormValue("shared_with"); sharedWith != "" {
+			for _, x := range strings.Split(sharedWith, ",") {
+				id, err := strconv.Atoi(strings.TrimSpace(x))
+				if err != nil {
+					erpc.ResponseHandler(w, erpc.StatusBadRequest)
+					return
+				}
+				policy.SharedWith = append(policy.SharedWith, id)
+			}
+		}
+
+		policy, err = db.SetDisclosurePolicy(policy)
+		if err != nil {
+			log.Println(err)
+			erpc.ResponseHandler(w, erpc.StatusBadRequest)
+			return
+		}
+
+		erpc.MarshalSend(w, policy)
+	})
+}
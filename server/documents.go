@@ -0,0 +1,401 @@
+package server
+
+import (
+	"io"
+	"io/ioutil"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	erpc "github.com/Varunram/essentials/rpc"
+	db "github.com/YaleOpenLab/openclimate/database"
+	globals "github.com/YaleOpenLab/openclimate/globals"
+)
+
+func setupDocumentHandlers() {
+	uploadDocument()
+	listDocuments()
+	downloadDocument()
+	replaceDocument()
+}
+
+// maxFormFieldsSize is the most the form fields of an upload can take up
+const maxFormFieldsSize = 1 << 20
+
+// uploadForm reads the form fields of a multipart upload up to the "file"
+// part and returns them with the part. The file isn't read, so that it can
+// be encrypted and stored as it is received, which is why the file has to
+// come after the other form fields. The request body is limited to
+// globals.MaxUploadSize. It returns false if the form is invalid.
+func uploadForm(w http.ResponseWriter, r *http.Request) (url.Values, *multipart.Part, bool) {
+	fields := url.Values{}
+	r.Body = http.MaxBytesReader(w, r.Body, globals.MaxUploadSize+maxFormFieldsSize)
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		log.Println("could not parse form data", err)
+		erpc.ResponseHandler(w, erpc.StatusBadRequest)
+		return fields, nil, false
+	}
+
+	var size int64
+	for {
+		part, err := reader.NextPart()
+		if err != nil {
+			// io.EOF if the form has no file
+			log.Println("could not parse form data", err)
+			erpc.ResponseHandler(w, erpc.StatusBadRequest)
+			return fields, nil, false
+		}
+
+		if part.FormName() == "file" {
+			return fields, part, true
+		}
+
+		value, err := ioutil.ReadAll(io.LimitReader(part, maxFormFieldsSize-size+1))
+		size += int64(len(value))
+		if err != nil || size > maxFormFieldsSize {
+			log.Println("could not parse form data", err)
+			erpc.ResponseHandler(w, erpc.StatusBadRequest)
+			return fields, nil, false
+		}
+		fields.Add(part.FormName(), string(value))
+	}
+}
+
+// readDocument reads the metadata of a document from the form fields of an
+// upload
+func readDocument(fields url.Values, part *multipart.Part) (db.File, error) {
+	var doc db.File
+	var err error
+
+	doc.Name = part.FileName()
+	doc.Title = fields.Get("title")
+	doc.DocType = fields.Get("type")
+	doc.Period = fields.Get("period")
+	doc.MimeType = part.Header.Get("Content-Type")
+
+	if x := fields.Get("report_id"); x != "" {
+		doc.ReportID, err = strconv.Atoi(x)
+		if err != nil {
+			return doc, err
+		}
+	}
+	if x := fields.Get("pledge_id"); x != "" {
+		doc.PledgeID, err = strconv.Atoi(x)
+		if err != nil {
+			return doc, err
+		}
+	}
+	return doc, nil
+}
+
+// canUpload checks that the user is a verified member of the actor that
+// owns the documents
+func canUpload(user db.User, actorType string, actorID int) bool {
+	return user.Verified && user.EntityType == actorType && user.EntityID == actorID
+}
+
+// documentView hides the wrapped data key of a document
+func documentView(doc db.File) db.File {
+	doc.WrappedKey = nil
+	return doc
+}
+
+// sendFile streams a decrypted file to the client. Files are sent as
+// attachments with the MIME type detected when they were uploaded, and
+// browsers are told not to sniff a different type, so that uploaded content
+// isn't rendered as part of the site.
+func sendFile(w http.ResponseWriter, doc db.File) {
+	file, err := doc.Open()
+	if err != nil {
+		log.Println(err)
+		erpc.ResponseHandler(w, erpc.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+
+	name := doc.Name
+	if name == "" {
+		name = doc.Title
+	}
+	disposition := mime.FormatMediaType("attachment", map[string]string{"filename": name})
+	if disposition == "" {
+		disposition = "attachment"
+	}
+	mimeType := doc.MimeType
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+
+	w.Header().Set("Content-Type", mimeType)
+	w.Header().Set("Content-Disposition", disposition)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if doc.Chunked {
+		// lets clients detect a download cut short by a decryption error
+		w.Header().Set("Content-Length", strconv.FormatInt(doc.Size, 10))
+	}
+
+	_, err = io.Copy(w, file)
+	if err != nil {
+		log.Println("could not send file", doc.Hash, err)
+	}
+}
+
+/*
+	Upload a document, e.g. an audit report backing a report or the NDC
+	backing a pledge. The document is encrypted and only verified users of
+	the actor and of the oversight organizations it shares its data with can
+	download it.
+
+	URL parameters:
+	- "username", "access_token": credentials of a verified user of the actor
+
+	Form parameters:
+	- "actor_type", "actor_id": the actor the document belongs to
+	- "title" (optional): defaults to the name of the file
+	- "type" (optional): audit report, NDC, inventory, verification statement or other (default)
+	- "period" (optional): the period the document covers, e.g. 2018
+	- "report_id", "pledge_id" (optional): the report or pledge the document is evidence for
+	- "file": the document, after all other parameters and at most
+	globals.MaxUploadSize bytes. Its MIME type is detected from its contents.
+*/
+func uploadDocument() {
+	http.HandleFunc("/documents/upload", func(w http.ResponseWriter, r *http.Request) {
+		user, err := CheckPostAuth(w, r)
+		if err != nil {
+			return
+		}
+
+		fields, part, ok := uploadForm(w, r)
+		if !ok {
+			return
+		}
+
+		actorType := fields.Get("actor_type")
+		actorID, err := strconv.Atoi(fields.Get("actor_id"))
+		if err != nil || actorType == "" {
+			erpc.ResponseHandler(w, erpc.StatusBadRequest)
+			return
+		}
+
+		if !canUpload(user, actorType, actorID) {
+			erpc.ResponseHandler(w, erpc.StatusUnauthorized)
+			return
+		}
+
+		doc, err := readDocument(fields, part)
+		if err != nil {
+			erpc.ResponseHandler(w, erpc.StatusBadRequest)
+			return
+		}
+
+		if doc.Title == "" {
+			doc.Title = doc.Name
+		}
+		doc.ActorType = actorType
+		doc.ActorID = actorID
+		doc.UploaderID = user.Index
+
+		doc, err = db.NewFile(doc, part)
+		if err != nil {
+			log.Println(err)
+			erpc.ResponseHandler(w, erpc.StatusBadRequest)
+			return
+		}
+
+		erpc.MarshalSend(w, documentView(doc))
+	})
+}
+
+/*
+	List the documents of an actor, current versions only unless the history
+	is requested.
+
+	URL parameters:
+	- "username", "access_token": credentials of a verified user of the actor
+	or of an oversight organization it shares its data with
+	- "actor_type", "actor_id": the actor the documents belong to
+	- "report_id", "pledge_id" (optional): only list the documents of a report or pledge
+	- "history" (optional): "true" to include replaced versions
+*/
+func listDocuments() {
+	http.HandleFunc("/documents", func(w http.ResponseWriter, r *http.Request) {
+		user, err := CheckGetAuth(w, r)
+		if err != nil {
+			return
+		}
+
+		if !checkReqdParams(w, r, "actor_type", "actor_id") {
+			return
+		}
+
+		actorType := r.URL.Query().Get("actor_type")
+		actorID, err := strconv.Atoi(r.URL.Query().Get("actor_id"))
+		if err != nil {
+			erpc.ResponseHandler(w, erpc.StatusBadRequest)
+			return
+		}
+
+		var reportID, pledgeID int
+		if x := r.URL.Query().Get("report_id"); x != "" {
+			reportID, err = strconv.Atoi(x)
+			if err != nil {
+				erpc.ResponseHandler(w, erpc.StatusBadRequest)
+				return
+			}
+		}
+		if x := r.URL.Query().Get("pledge_id"); x != "" {
+			pledgeID, err = strconv.Atoi(x)
+			if err != nil {
+				erpc.ResponseHandler(w, erpc.StatusBadRequest)
+				return
+			}
+		}
+		history := r.URL.Query().Get("history") == "true"
+
+		allowed, err := db.CanAccessFiles(user, actorType, actorID)
+		if err != nil {
+			log.Println(err)
+			erpc.ResponseHandler(w, erpc.StatusInternalServerError)
+			return
+		}
+		if !allowed {
+			erpc.ResponseHandler(w, erpc.StatusUnauthorized)
+			return
+		}
+
+		files, err := db.RetrieveEntityFiles(actorType, actorID)
+		if err != nil {
+			log.Println(err)
+			erpc.ResponseHandler(w, erpc.StatusInternalServerError)
+			return
+		}
+
+		docs := make([]db.File, 0, len(files))
+		for _, f := range files {
+			if !history && f.ReplacedBy != 0 {
+				continue
+			}
+			if reportID != 0 && f.ReportID != reportID {
+				continue
+			}
+			if pledgeID != 0 && f.PledgeID != pledgeID {
+				continue
+			}
+			docs = append(docs, documentView(f))
+		}
+
+		erpc.MarshalSend(w, docs)
+	})
+}
+
+/*
+	Download a document. It is decrypted as it is sent, as an attachment
+	with the document's MIME type.
+
+	URL parameters:
+	- "username", "access_token": credentials of a verified user of the actor
+	or of an oversight organization it shares its data with
+	- "id": the ID of the document
+*/
+func downloadDocument() {
+	http.HandleFunc("/documents/download", func(w http.ResponseWriter, r *http.Request) {
+		user, err := CheckGetAuth(w, r)
+		if err != nil {
+			return
+		}
+
+		if !checkReqdParams(w, r, "id") {
+			return
+		}
+
+		id, err := strconv.Atoi(r.URL.Query().Get("id"))
+		if err != nil {
+			erpc.ResponseHandler(w, erpc.StatusBadRequest)
+			return
+		}
+
+		doc, err := db.RetrieveFile(id)
+		if err != nil || doc.Index == 0 {
+			erpc.ResponseHandler(w, erpc.StatusNotFound)
+			return
+		}
+
+		allowed, err := doc.CanDecrypt(user)
+		if err != nil {
+			log.Println(err)
+			erpc.ResponseHandler(w, erpc.StatusInternalServerError)
+			return
+		}
+		if !allowed {
+			erpc.ResponseHandler(w, erpc.StatusUnauthorized)
+			return
+		}
+
+		sendFile(w, doc)
+	})
+}
+
+/*
+	Replace a document with a new version. The earlier version is kept and
+	listed in the document's history.
+
+	URL parameters:
+	- "username", "access_token": credentials of a verified user of the actor
+
+	Form parameters:
+	- "id": the ID of the current version of the document
+	- "title", "type", "period", "report_id", "pledge_id" (optional): the
+	metadata of the new version, taken from the current version if left out
+	- "file": the new version, after all other parameters
+*/
+func replaceDocument() {
+	http.HandleFunc("/documents/replace", func(w http.ResponseWriter, r *http.Request) {
+		user, err := CheckPostAuth(w, r)
+		if err != nil {
+			return
+		}
+
+		fields, part, ok := uploadForm(w, r)
+		if !ok {
+			return
+		}
+
+		id, err := strconv.Atoi(fields.Get("id"))
+		if err != nil {
+			erpc.ResponseHandler(w, erpc.StatusBadRequest)
+			return
+		}
+
+		current, err := db.RetrieveFile(id)
+		if err != nil || current.Index == 0 {
+			erpc.ResponseHandler(w, erpc.StatusNotFound)
+			return
+		}
+
+		if !canUpload(user, current.ActorType, current.ActorID) {
+			erpc.ResponseHandler(w, erpc.StatusUnauthorized)
+			return
+		}
+
+		doc, err := readDocument(fields, part)
+		if err != nil {
+			erpc.ResponseHandler(w, erpc.StatusBadRequest)
+			return
+		}
+		doc.UploaderID = user.Index
+
+		doc, err = current.Replace(doc, part)
+		if err != nil {
+			log.Println(err)
+			erpc.ResponseHandler(w, erpc.StatusBadRequest)
+			return
+		}
+
+		erpc.MarshalSend(w, documentView(doc))
+	})
+}
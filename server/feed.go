@@ -0,0 +1,219 @@
+package server
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	erpc "github.com/Varunram/essentials/rpc"
+	db "github.com/YaleOpenLab/openclimate/database"
+)
+
+func setupFeedHandlers() {
+	follow()
+	unfollow()
+	getFollowing()
+	getFeed()
+	getFollowers()
+}
+
+// followTarget reads the actor or pledge to (un)follow from the form
+// parameters. It returns false if the parameters are missing or invalid.
+func followTarget(w http.ResponseWriter, r *http.Request) (string, int, int, bool) {
+	var actorType string
+	var actorID, pledgeID int
+	var err error
+
+	err = r.ParseForm()
+	if err != nil {
+		erpc.ResponseHandler(w, erpc.StatusBadRequest)
+		return actorType, actorID, pledgeID, false
+	}
+
+	if r.FormValue("pledge_id") != "" {
+		pledgeID, err = strconv.Atoi(r.FormValue("pledge_id"))
+		if err != nil || pledgeID == 0 {
+			erpc.ResponseHandler(w, erpc.StatusBadRequest)
+			return actorType, actorID, pledgeID, false
+		}
+		return actorType, actorID, pledgeID, true
+	}
+
+	if !checkReqdPostParams(w, r, "actor_type", "actor_id") {
+		return actorType, actorID, pledgeID, false
+	}
+
+	actorType = r.FormValue("actor_type")
+	actorID, err = strconv.Atoi(r.FormValue("actor_id"))
+	if err != nil {
+		erpc.ResponseHandler(w, erpc.StatusBadRequest)
+		return actorType, actorID, pledgeID, false
+	}
+	return actorType, actorID, pledgeID, true
+}
+
+/*
+	Follow a climate actor or one of its pledges. The activities of what the
+	user follows (new reports, new and amended pledges, verifications) show
+	up in their feed.
+
+	Form parameters:
+	- "actor_type", "actor_id": the actor to follow
+	- "pledge_id" (optional): the pledge to follow instead of an actor
+*/
+func follow() {
+	http.HandleFunc("/follow", func(w http.ResponseWriter, r *http.Request) {
+		user, err := CheckPostAuth(w, r)
+		if err != nil {
+			return
+		}
+
+		actorType, actorID, pledgeID, ok := followTarget(w, r)
+		if !ok {
+			return
+		}
+
+		err = user.Follow(actorType, actorID, pledgeID)
+		if err != nil {
+			log.Println(err)
+			erpc.ResponseHandler(w, erpc.StatusNotFound)
+			return
+		}
+
+		erpc.MarshalSend(w, user.Following)
+	})
+}
+
+/*
+	Stop following a climate actor or pledge. Takes the same form parameters
+	as /follow.
+*/
+func unfollow() {
+	http.HandleFunc("/unfollow", func(w http.ResponseWriter, r *http.Request) {
+		user, err := CheckPostAuth(w, r)
+		if err != nil {
+			return
+		}
+
+		actorType, actorID, pledgeID, ok := followTarget(w, r)
+		if !ok {
+			return
+		}
+
+		err = user.Unfollow(actorType, actorID, pledgeID)
+		if err != nil {
+			log.Println(err)
+			erpc.ResponseHandler(w, erpc.StatusInternalServerError)
+			return
+		}
+
+		erpc.MarshalSend(w, user.Following)
+	})
+}
+
+// Retrieve the actors and pledges the logged in user follows
+func getFollowing() {
+	http.HandleFunc("/following", func(w http.ResponseWriter, r *http.Request) {
+		user, err := CheckGetAuth(w, r)
+		if err != nil {
+			return
+		}
+
+		erpc.MarshalSend(w, user.Following)
+	})
+}
+
+/*
+	Retrieve the activity feed of the logged in user: the activities of the
+	actors and pledges they follow, newest first. Activities about data an
+	actor doesn't disclose to the user are left out. Users who aren't
+	verified only see activities about public data.
+
+	URL parameters:
+	- "limit" (optional): the maximum number of activities to return
+*/
+func getFeed() {
+	http.HandleFunc("/feed", func(w http.ResponseWriter, r *http.Request) {
+		user, err := CheckGetAuth(w, r)
+		if err != nil {
+			return
+		}
+
+		var limit int
+		if x := r.URL.Query().Get("limit"); x != "" {
+			limit, err = strconv.Atoi(x)
+			if err != nil || limit < 0 {
+				erpc.ResponseHandler(w, erpc.StatusBadRequest)
+				return
+			}
+		}
+
+		feed, err := user.RetrieveFeed()
+		if err != nil {
+			log.Println(err)
+			erpc.ResponseHandler(w, erpc.StatusInternalServerError)
+			return
+		}
+
+		// unverified users see what anyone can see
+		var viewer db.User
+		if user.Verified {
+			viewer = user
+		}
+		feed, err = newDisclosure(viewer).discloseActivities(feed)
+		if err != nil {
+			log.Println(err)
+			erpc.ResponseHandler(w, erpc.StatusInternalServerError)
+			return
+		}
+
+		if limit > 0 && len(feed) > limit {
+			feed = feed[:limit]
+		}
+		erpc.MarshalSend(w, feed)
+	})
+}
+
+// Followers is the number of users following a climate actor
+type Followers struct {
+	ActorType string
+	ActorID   int
+	Followers int
+}
+
+/*
+	Retrieve the number of users following a climate actor.
+
+	URL parameters:
+	- "actor_type": either city, country, region, state, company, etc.
+	- "actor_id": the ID assigned to the actor in the database.
+*/
+func getFollowers() {
+	http.HandleFunc("/followers", func(w http.ResponseWriter, r *http.Request) {
+		err := erpc.CheckGet(w, r)
+		if err != nil {
+			return
+		}
+
+		if !checkReqdParams(w, r, "actor_type", "actor_id") {
+			return
+		}
+
+		var x Followers
+		x.ActorType = r.URL.Query()["actor_type"][0]
+		x.ActorID, err = strconv.Atoi(r.URL.Query()["actor_id"][0])
+		if err != nil {
+			erpc.ResponseHandler(w, erpc.StatusBadRequest)
+			return
+		}
+
+		x.Followers, err = db.FollowerCount(x.ActorType, x.ActorID)
+		if err != nil {
+			log.Println(err)
+			erpc.ResponseHandler(w, erpc.StatusInternalServerError)
+			return
+		}
+
+		erpc.MarshalSend(w, x)
+	})
+}
@@ -1,31 +1,305 @@
 package server
 
-import ()
+import (
+	"log"
+	"strconv"
 
-func getDirectEmissionsActorId(actorId string) (map[string]string, error) {
-	// retrieve this from the blockchain
-	x := make(map[string]string)
-	return x, nil
+	"github.com/YaleOpenLab/openclimate/aggregation"
+	"github.com/YaleOpenLab/openclimate/database"
+	"github.com/YaleOpenLab/openclimate/ghg"
+	"github.com/YaleOpenLab/openclimate/score"
+	"github.com/pkg/errors"
+)
+
+// actorDashboard collects the data shown on the dashboard of a climate actor,
+// as far as the actor discloses it to the viewer
+func actorDashboard(actorType string, actorID int, viewer database.User) (map[string]interface{}, error) {
+	results := make(map[string]interface{})
+	d := newDisclosure(viewer)
+
+	actor, err := database.RetrieveActor(actorType, actorID)
+	if err != nil {
+		return results, errors.Wrap(err, "could not retrieve actor")
+	}
+
+	switch x := actor.(type) {
+	case *database.Company:
+		results["full_name"] = x.Name
+		results["description"] = x.Description
+		results["locations"] = x.Locations
+		results["accountability"] = x.Accountability
+	case *database.Country:
+		results["full_name"] = x.Name
+		results["description"] = x.Description
+		results["accountability"] = x.Accountability
+	case *database.City:
+		results["full_name"] = x.Name
+	case *database.State:
+		results["full_name"] = x.Name
+	case *database.Region:
+		results["full_name"] = x.Name
+	case *database.Oversight:
+		results["full_name"] = x.Name
+	}
+
+	pledges, err := actor.GetPledges()
+	if err != nil {
+		return results, err
+	}
+
+	results["pledges"], err = d.disclosePledges(actorType, actorID, pledges)
+	if err != nil {
+		return results, err
+	}
+
+	results["direct_emissions"], err = getDirectEmissionsActorId(actorType, actorID, d)
+	if err != nil {
+		return results, err
+	}
+
+	results["mitigation_outcomes"], err = getMitigationOutcomesActorId(actorType, actorID, d)
+	if err != nil {
+		return results, err
+	}
+
+	results["wind_and_solar"], err = getWindAndSolarActorId(actorType, actorID, d)
+	if err != nil {
+		return results, err
+	}
+
+	results["followers"], err = database.FollowerCount(actorType, actorID)
+	if err != nil {
+		return results, err
+	}
+
+	results["disclosure_settings"], err = disclosurePolicy(actorType, actorID, viewer)
+	if err != nil {
+		return results, err
+	}
+
+	weightedScore, err := getWeightedScoreActorId(actorType, actorID, d)
+	if err != nil {
+		return results, err
+	}
+	results["weighted_score"] = weightedScore.Total
+	results["score_breakdown"] = weightedScore.Components
+	results["climate_accountability"] = weightedScore.Accountability
+
+	return results, nil
 }
 
-func getMitigationOutcomesActorId(actorId string) (map[string]string, error) {
-	// retrieve this from the blockchain
-	x := make(map[string]string)
+// annualSeries returns the values of a metric an actor reported itself by year
+func annualSeries(actorType string, actorID int, metric string) (map[string]float64, error) {
+	x := make(map[string]float64)
+
+	var q database.SeriesQuery
+	q.ActorType = actorType
+	q.ActorID = actorID
+	q.Metric = metric
+	q.Granularity = database.Annual
+	q.Sources = []string{database.SourceSelfReported}
+
+	series, err := database.QuerySeries(q)
+	if err != nil {
+		return x, errors.Wrap(err, "could not query time series")
+	}
+
+	for _, value := range series[database.SourceSelfReported] {
+		x[value.Period] = value.Value
+	}
 	return x, nil
 }
 
-func getWindAndSolarActorId(actorId string) (map[string]string, error) {
-	// retrieve this from the blockchain
-	x := make(map[string]string)
-	return x, nil
+// getDirectEmissionsActorId returns the scope 1 emissions an actor reported
+// by year (tCO2e)
+func getDirectEmissionsActorId(actorType string, actorID int, d *disclosure) (map[string]float64, error) {
+	access, err := d.access(actorType, actorID, database.FieldEmissions)
+	if err != nil || access == database.AccessNone {
+		return make(map[string]float64), err
+	}
+	return annualSeries(actorType, actorID, database.MetricScope1)
 }
 
-func getDisclosureSettingsActorId(actorId string) (map[string]string, error) {
-	x := make(map[string]string)
+// getMitigationOutcomesActorId returns the emissions an actor's mitigation
+// actions avoided by year (tCO2e). Years the actor didn't report itself are
+// filled in with the emissions its assets avoided.
+func getMitigationOutcomesActorId(actorType string, actorID int, d *disclosure) (map[string]float64, error) {
+	x := make(map[string]float64)
+
+	access, err := d.access(actorType, actorID, database.FieldMitigation)
+	if err != nil {
+		return x, err
+	}
+	if access != database.AccessNone {
+		x, err = annualSeries(actorType, actorID, database.MetricMitigation)
+		if err != nil {
+			return x, err
+		}
+	}
+
+	// actor types that aren't aggregated (e.g. oversight) have no assets
+	assets, err := aggregation.Assets(actorType, actorID)
+	if err != nil && err != aggregation.ErrActorNotFound {
+		return x, err
+	}
+
+	fromAssets := make(map[string]float64)
+	for _, asset := range assets {
+		access, err := d.access("company", asset.CompanyID, database.FieldAssets)
+		if err != nil {
+			return x, err
+		}
+		if access == database.AccessNone {
+			continue
+		}
+
+		for _, year := range asset.Years() {
+			avoided, err := assetAvoidedEmissions(asset, year)
+			if err != nil {
+				return x, err
+			}
+			fromAssets[year] += avoided
+		}
+	}
+
+	for year, avoided := range fromAssets {
+		if _, reported := x[year]; !reported {
+			x[year] = avoided
+		}
+	}
 	return x, nil
 }
 
-func getWeightedScoreActorId(actorId string) (map[string]string, error) {
-	x := make(map[string]string)
+// WindAndSolar is the electricity generated by an actor's assets and the
+// share of it that is renewable, by year
+type WindAndSolar struct {
+	Generation          map[string]float64 // MWh
+	RenewableGeneration map[string]float64 // MWh
+	Share               map[string]float64 // of the generation that is renewable
+
+	Assets []RenewableAsset
+}
+
+// RenewableAsset is a renewable energy asset, with the electricity it
+// generated and the emissions it avoided each year
+type RenewableAsset struct {
+	AssetID    int
+	Name       string
+	Type       string
+	State      string
+	Capacity   float64 // MW
+	GridRegion string
+
+	Generation       map[string]float64 // MWh by year
+	AvoidedEmissions map[string]float64 // tCO2e by year
+}
+
+// getWindAndSolarActorId returns the renewable generation of an actor's
+// assets. Assets whose companies only disclose aggregate asset data are
+// counted in the totals but not listed.
+func getWindAndSolarActorId(actorType string, actorID int, d *disclosure) (WindAndSolar, error) {
+	var x WindAndSolar
+	x.Generation = make(map[string]float64)
+	x.RenewableGeneration = make(map[string]float64)
+	x.Share = make(map[string]float64)
+
+	// actor types that aren't aggregated (e.g. oversight) have no assets
+	assets, err := aggregation.Assets(actorType, actorID)
+	if err != nil && err != aggregation.ErrActorNotFound {
+		return x, err
+	}
+
+	for _, asset := range assets {
+		access, err := d.access("company", asset.CompanyID, database.FieldAssets)
+		if err != nil {
+			return x, err
+		}
+		if access == database.AccessNone {
+			continue
+		}
+
+		renewable := ghg.IsRenewable(asset)
+
+		var ra RenewableAsset
+		ra.AssetID = asset.Index
+		ra.Name = asset.Name
+		ra.Type = asset.Type
+		ra.State = asset.State
+		ra.Capacity = asset.Capacity
+		ra.Generation = make(map[string]float64)
+		ra.AvoidedEmissions = make(map[string]float64)
+
+		for _, year := range asset.Years() {
+			report, _ := asset.PeriodTotal(year)
+			x.Generation[year] += report.Generation
+			if !renewable {
+				continue
+			}
+
+			x.RenewableGeneration[year] += report.Generation
+			ra.Generation[year] = report.Generation
+			// e.g. the asset's state isn't mapped to a grid region
+			avoided, err := assetAvoidedEmissions(asset, year)
+			if err != nil {
+				log.Println(errors.Wrap(err, "could not compute avoided emissions of asset "+strconv.Itoa(asset.Index)))
+				continue
+			}
+			ra.AvoidedEmissions[year] = avoided
+		}
+
+		if !renewable || access != database.AccessFull {
+			continue
+		}
+
+		company, err := database.RetrieveCompany(asset.CompanyID)
+		if err != nil {
+			return x, errors.Wrap(err, "could not retrieve company")
+		}
+		region, err := ghg.RetrieveStateGridRegion(asset.State, company.Country)
+		if err == nil {
+			ra.GridRegion = region.Name
+		}
+
+		x.Assets = append(x.Assets, ra)
+	}
+
+	for year, generation := range x.Generation {
+		if generation > 0 {
+			x.Share[year] = x.RenewableGeneration[year] / generation
+		}
+	}
 	return x, nil
 }
+
+// assetAvoidedEmissions returns the emissions an asset avoided in a year.
+// For renewable assets that didn't report them, they are computed from the
+// electricity generated.
+func assetAvoidedEmissions(asset database.Asset, year string) (float64, error) {
+	report, _ := asset.PeriodTotal(year)
+	if report.AvoidedEmissions != 0 || report.Generation == 0 || !ghg.IsRenewable(asset) {
+		return report.AvoidedEmissions, nil
+	}
+
+	y, _ := strconv.Atoi(year)
+	avoided, err := ghg.AvoidedEmissions(asset, report.Generation/1e3, y, ghg.DefaultGWPSet())
+	if err != nil {
+		return 0, err
+	}
+	return avoided.AvoidedEmissions, nil
+}
+
+// getWeightedScoreActorId returns the accountability score of an actor with
+// the default weights
+func getWeightedScoreActorId(actorType string, actorID int, d *disclosure) (score.Score, error) {
+	var empty score.Score
+	weights, err := score.LoadWeights()
+	if err != nil {
+		return empty, err
+	}
+
+	s, err := score.ScoreActor(actorType, actorID, weights)
+	if err != nil {
+		return empty, err
+	}
+	return d.discloseScore(s)
+}
@@ -3,20 +3,16 @@ package server
 
 import (
 	// "encoding/json"
-	"io/ioutil"
 	"log"
 	"net/http"
-	"os"
 	"strconv"
 	"strings"
 
-	cc20 "github.com/Varunram/essentials/chacha20poly1305"
-	"github.com/Varunram/essentials/ipfs"
 	erpc "github.com/Varunram/essentials/rpc"
 	"github.com/Varunram/essentials/utils"
 	"github.com/YaleOpenLab/openclimate/blockchain"
 	"github.com/YaleOpenLab/openclimate/database"
-	"github.com/YaleOpenLab/openclimate/globals"
+	"github.com/YaleOpenLab/openclimate/paris"
 	"github.com/pkg/errors"
 )
 
@@ -32,7 +28,9 @@ func frontendFns() {
 	postRegister()
 	postLogin()
 	getFiles()
+	rotateFileKey()
 	addLike()
+	hideDisclosureField()
 	searchForEntity()
 }
 
@@ -43,7 +41,7 @@ func getId(w http.ResponseWriter, r *http.Request) (string, error) {
 		return id, errors.New("request not get")
 	}
 
-	urlParams := strings.Split(r.URL.String(), "/")
+	urlParams := strings.Split(r.URL.Path, "/")
 
 	if len(urlParams) < 3 {
 		return id, errors.New("no id provided, quitting")
@@ -61,13 +59,14 @@ func getPutId(w http.ResponseWriter, r *http.Request) (string, error) {
 		return id, errors.New("request not get")
 	}
 
-	urlParams := strings.Split(r.URL.String(), "/")
+	// the ID is the last element of the path, e.g. /like/pledges/{id}
+	urlParams := strings.Split(r.URL.Path, "/")
 
-	if len(urlParams) < 3 {
+	if len(urlParams) < 3 || urlParams[len(urlParams)-1] == "" {
 		return id, errors.New("no id provided, quitting")
 	}
 
-	id = urlParams[2]
+	id = urlParams[len(urlParams)-1]
 	return id, nil
 }
 
@@ -107,6 +106,11 @@ func getNationStateId() {
 			return
 		}
 
+		viewer, err := CheckGetViewer(w, r)
+		if err != nil {
+			return
+		}
+
 		id, err := strconv.Atoi(strID)
 		if err != nil {
 			log.Println(err)
@@ -125,6 +129,13 @@ func getNationStateId() {
 			erpc.ResponseHandler(w, erpc.StatusInternalServerError)
 		}
 
+		pledges, err = newDisclosure(viewer).disclosePledges("country", nationState.Index, pledges)
+		if err != nil {
+			log.Println(err)
+			erpc.ResponseHandler(w, erpc.StatusInternalServerError)
+			return
+		}
+
 		results := make(map[string]interface{})
 		results["name"] = nationState.Name
 		results["full_name"] = nationState.Name
@@ -143,6 +154,11 @@ func getMultiNationalId() {
 			return
 		}
 
+		viewer, err := CheckGetViewer(w, r)
+		if err != nil {
+			return
+		}
+
 		id, err := strconv.Atoi(strID)
 		if err != nil {
 			log.Println(err)
@@ -161,6 +177,13 @@ func getMultiNationalId() {
 			erpc.ResponseHandler(w, erpc.StatusInternalServerError)
 		}
 
+		pledges, err = newDisclosure(viewer).disclosePledges("company", multinational.Index, pledges)
+		if err != nil {
+			log.Println(err)
+			erpc.ResponseHandler(w, erpc.StatusInternalServerError)
+			return
+		}
+
 		results := make(map[string]interface{})
 		results["name"] = multinational.Name
 		results["full_name"] = multinational.Name
@@ -185,6 +208,19 @@ type Subnational struct {
 	Assets  []database.Asset
 }
 
+/*
+	Retrieve data about a climate actor.
+
+	Routes:
+	- /actors/{id}/dashboard: the actor's profile, pledges, direct emissions,
+		mitigation outcomes, wind and solar share, follower count, disclosure settings,
+		accountability score and its breakdown. The optional "actor_type" URL parameter selects the
+		type of actor (company by default).
+	- "username", "access_token" (optional URL parameters): the viewer's
+		credentials, data the actor doesn't disclose to the viewer is left out
+	- /actors/{id}/nation-states, /actors/{id}/review and
+		/actors/{id}/climate-action-asset: company data
+*/
 func getActorId() {
 	http.HandleFunc("/actors/", func(w http.ResponseWriter, r *http.Request) {
 		strID, err := getId(w, r)
@@ -192,19 +228,19 @@ func getActorId() {
 			return
 		}
 
-		id, err := utils.ToInt(strID)
+		viewer, err := CheckGetViewer(w, r)
 		if err != nil {
-			log.Println(err)
-			erpc.ResponseHandler(w, erpc.StatusInternalServerError)
+			return
 		}
 
-		company, err := database.RetrieveCompany(id)
+		id, err := utils.ToInt(strID)
 		if err != nil {
 			log.Println(err)
-			erpc.ResponseHandler(w, erpc.StatusInternalServerError)
+			erpc.ResponseHandler(w, erpc.StatusBadRequest)
+			return
 		}
 
-		urlParams := strings.Split(r.URL.String(), "/")
+		urlParams := strings.Split(r.URL.Path, "/")
 		if len(urlParams) < 4 {
 			log.Println("insufficient amount of params")
 			erpc.ResponseHandler(w, erpc.StatusBadRequest)
@@ -213,65 +249,62 @@ func getActorId() {
 
 		choice := urlParams[3]
 
-		switch choice {
-		case "dashboard":
-			pledges, err := company.GetPledges()
-			if err != nil {
-				log.Println(err)
-				erpc.ResponseHandler(w, erpc.StatusInternalServerError)
-				return
+		// the dashboard works for every type of actor, companies by default
+		if choice == "dashboard" {
+			actorType := "company"
+			if r.URL.Query().Get("actor_type") != "" {
+				actorType = r.URL.Query().Get("actor_type")
 			}
-			results := make(map[string]interface{})
-			results["full_name"] = company.Name
-			results["description"] = company.Description
-			results["locations"] = company.Locations
-			results["accountability"] = company.Accountability
-			results["pledges"] = pledges
 
-			results["direct_emissions"], err = getDirectEmissionsActorId(strID)
+			_, err = database.RetrieveActor(actorType, id)
 			if err != nil {
-				erpc.ResponseHandler(w, erpc.StatusInternalServerError)
+				log.Println(err)
+				erpc.ResponseHandler(w, erpc.StatusNotFound)
 				return
 			}
 
-			results["mitigation_outcomes"], err = getMitigationOutcomesActorId(strID)
+			results, err := actorDashboard(actorType, id, viewer)
 			if err != nil {
+				log.Println(err)
 				erpc.ResponseHandler(w, erpc.StatusInternalServerError)
 				return
 			}
+			erpc.MarshalSend(w, results)
+			return
+		}
 
-			results["direct_emissions"], err = getWindAndSolarActorId(strID)
-			if err != nil {
-				erpc.ResponseHandler(w, erpc.StatusInternalServerError)
-				return
-			}
+		company, err := database.RetrieveCompany(id)
+		if err != nil {
+			log.Println(err)
+			erpc.ResponseHandler(w, erpc.StatusNotFound)
+			return
+		}
 
-			results["disclosure_settings"], err = getDisclosureSettingsActorId(strID)
+		switch choice {
+		case "nation-states":
+			nationStates, err := getActorIdNationStates(company, newDisclosure(viewer))
 			if err != nil {
 				erpc.ResponseHandler(w, erpc.StatusInternalServerError)
 				return
 			}
-
-			results["weighted_score"], err = getWeightedScoreActorId(strID)
+			erpc.MarshalSend(w, nationStates)
+		// end of nation states case
+		case "review":
+			access, err := newDisclosure(viewer).access("company", company.Index, database.FieldEmissions)
 			if err != nil {
+				log.Println(err)
 				erpc.ResponseHandler(w, erpc.StatusInternalServerError)
 				return
 			}
-		// end of dashboard case
-		case "nation-states":
-			nationStates, err := getActorIdNationStates(company, w, r)
-			if err != nil {
-				erpc.ResponseHandler(w, erpc.StatusInternalServerError)
+			if access != database.AccessFull {
+				erpc.ResponseHandler(w, erpc.StatusUnauthorized)
 				return
 			}
-			erpc.MarshalSend(w, nationStates)
-		// end of nation states case
-		case "review":
+
 			results := make(map[string]interface{})
 			results["certificates"] = company.Certificates
 			results["climate_reports"] = company.ClimateReports
 
-			var err error
 			results["emissions"], err = blockchain.RetrieveActorEmissions(id)
 			if err != nil {
 				erpc.MarshalSend(w, erpc.StatusInternalServerError)
@@ -302,7 +335,10 @@ func getActorId() {
 	})
 }
 
-func getActorIdNationStates(company database.Company, w http.ResponseWriter, r *http.Request) ([]NationState, error) {
+// getActorIdNationStates lists the countries and states a company operates
+// in with their pledges and the company's assets there, as far as they are
+// disclosed to the viewer
+func getActorIdNationStates(company database.Company, d *disclosure) ([]NationState, error) {
 
 	var nationStates []NationState
 
@@ -311,6 +347,11 @@ func getActorIdNationStates(company database.Company, w http.ResponseWriter, r *
 		return nationStates, errors.Wrap(err, "getActorIdNationStates() failed")
 	}
 
+	assetAccess, err := d.access("company", company.Index, database.FieldAssets)
+	if err != nil {
+		return nationStates, errors.Wrap(err, "getActorIdNationStates() failed")
+	}
+
 	for _, country := range countries {
 		var nationState NationState
 		states, err := company.GetStates()
@@ -322,6 +363,10 @@ func getActorIdNationStates(company database.Company, w http.ResponseWriter, r *
 		if err != nil {
 			return nationStates, errors.Wrap(err, "getActorIdNationStates() failed")
 		}
+		pledges, err = d.disclosePledges("country", country.Index, pledges)
+		if err != nil {
+			return nationStates, errors.Wrap(err, "getActorIdNationStates() failed")
+		}
 
 		var subnationals []Subnational
 
@@ -331,14 +376,19 @@ func getActorIdNationStates(company database.Company, w http.ResponseWriter, r *
 			if err != nil {
 				return nationStates, errors.Wrap(err, "getActorIdNationStates() failed")
 			}
-			assets, err := company.GetAssetsByState(s.Name)
+			pledges, err = d.disclosePledges("state", s.Index, pledges)
 			if err != nil {
 				return nationStates, errors.Wrap(err, "getActorIdNationStates() failed")
 			}
 
 			subnational.Name = s.Name
 			subnational.Pledges = pledges
-			subnational.Assets = assets
+			if assetAccess == database.AccessFull {
+				subnational.Assets, err = company.GetAssetsByState(s.Name)
+				if err != nil {
+					return nationStates, errors.Wrap(err, "getActorIdNationStates() failed")
+				}
+			}
 			subnationals = append(subnationals, subnational)
 		}
 
@@ -366,12 +416,21 @@ func getEarthStatus() {
 			return
 		}
 
+		status, err := paris.GetStatus()
+		if err != nil {
+			log.Println(err)
+			erpc.ResponseHandler(w, erpc.StatusInternalServerError)
+			return
+		}
+
+		headline := status.Headline()
+
 		var x EarthStatusReturn
-		x.Warminginc = "sample"
-		x.Gtco2left = "sample"
-		x.Atmosphericco2ppm = "sample"
-		x.Annualglobalemission = "sample"
-		x.Estimatedbudgetdepletion = "sample"
+		x.Warminginc = strconv.FormatFloat(status.Warming, 'f', 2, 64)
+		x.Gtco2left = strconv.FormatFloat(headline.Remaining, 'f', 0, 64)
+		x.Atmosphericco2ppm = strconv.FormatFloat(status.AtmosCO2, 'f', 2, 64)
+		x.Annualglobalemission = strconv.FormatFloat(status.AnnualEmissions, 'f', 1, 64)
+		x.Estimatedbudgetdepletion = strconv.FormatFloat(headline.DepletionYear, 'f', 0, 64)
 
 		erpc.MarshalSend(w, x)
 	})
@@ -508,22 +567,38 @@ type postfileReturn struct {
 	IpfsHash string
 }
 
+/*
+	Upload a file for verification. The file is encrypted with a key of its
+	own, which is wrapped with the key of the entity, so only the entity's
+	users and the oversight organizations it shares its data with can
+	download it (see database/keys.go). /documents/upload takes documents
+	of all actor types along with their metadata.
+
+	URL parameters:
+	- "username", "access_token": credentials of a verified user of the entity
+
+	Form parameters:
+	- "id": the ID of the entity
+	- "docId": the number of the document (1 to 5)
+	- "entity": country, mnc (multinational company) or state
+	- "file": the file, after all other parameters
+*/
 func postFiles() {
 	http.HandleFunc("/files", func(w http.ResponseWriter, r *http.Request) {
 		log.Println("in postfiles endpoint")
-		err := erpc.CheckPost(w, r)
+		user, err := CheckPostAuth(w, r)
 		if err != nil {
-			erpc.ResponseHandler(w, erpc.StatusBadRequest)
 			return
 		}
 
-		if !checkReqdPostParams(w, r, "id", "docId", "entity") {
+		fields, part, ok := uploadForm(w, r)
+		if !ok {
 			return
 		}
 
-		id := r.FormValue("id")
-		docIdString := r.FormValue("docId")
-		entity := r.FormValue("entity")
+		id := fields.Get("id")
+		docIdString := fields.Get("docId")
+		entity := fields.Get("entity")
 
 		if entity != "country" && entity != "mnc" && entity != "state" {
 			erpc.MarshalSend(w, erpc.StatusBadRequest)
@@ -542,44 +617,40 @@ func postFiles() {
 			return
 		}
 
-		r.ParseMultipartForm(1 << 21) // max 10MB files
-		file, fileHeader, err := r.FormFile("file")
+		idInt, err := utils.ToInt(id)
 		if err != nil {
-			log.Println("could not parse form data", err)
 			erpc.MarshalSend(w, erpc.StatusBadRequest)
 			return
 		}
 
-		defer file.Close()
-
-		log.Println("file size: ", fileHeader.Size)
-		fileBytes, err := ioutil.ReadAll(file)
-		if err != nil {
-			erpc.MarshalSend(w, erpc.StatusInternalServerError)
-			return
+		actorType := entity
+		if entity == "mnc" {
+			actorType = "company"
 		}
-
-		// encrypt with chacha20 since the file size is variable
-		encryptedBytes, err := cc20.Encrypt(fileBytes, globals.IpfsMasterPwd)
-		if err != nil {
-			erpc.MarshalSend(w, erpc.StatusInternalServerError)
+		if user.EntityType != actorType || user.EntityID != idInt {
+			erpc.ResponseHandler(w, erpc.StatusUnauthorized)
 			return
 		}
 
-		hash, err := ipfs.IpfsAddBytes(encryptedBytes)
+		var doc database.File
+		doc.ActorType = actorType
+		doc.ActorID = idInt
+		doc.UploaderID = user.Index
+		doc.Name = part.FileName()
+		doc.Title = part.FileName()
+		doc.MimeType = part.Header.Get("Content-Type")
+
+		f, err := database.NewFile(doc, part)
 		if err != nil {
+			log.Println(err)
 			erpc.MarshalSend(w, erpc.StatusInternalServerError)
 			return
 		}
+		hash := f.Hash
 
 		switch entity {
 		case "country":
 			log.Println("storing file against required country")
-			idInt, err := utils.ToInt(id)
-			if err != nil {
-				erpc.MarshalSend(w, erpc.StatusBadRequest)
-				return
-			}
 			x, err := database.RetrieveCountry(idInt)
 			if err != nil {
 				erpc.MarshalSend(w, erpc.StatusInternalServerError)
@@ -592,11 +663,6 @@ func postFiles() {
 			}
 		case "mnc":
 			log.Println("storing file against required multinational company")
-			idInt, err := utils.ToInt(id)
-			if err != nil {
-				erpc.MarshalSend(w, erpc.StatusBadRequest)
-				return
-			}
 			x, err := database.RetrieveCompany(idInt)
 			if err != nil {
 				erpc.MarshalSend(w, erpc.StatusInternalServerError)
@@ -609,11 +675,6 @@ func postFiles() {
 			}
 		case "state":
 			log.Println("storing file against requried state")
-			idInt, err := utils.ToInt(id)
-			if err != nil {
-				erpc.MarshalSend(w, erpc.StatusBadRequest)
-				return
-			}
 			x, err := database.RetrieveState(idInt)
 			if err != nil {
 				erpc.MarshalSend(w, erpc.StatusInternalServerError)
@@ -632,46 +693,85 @@ func postFiles() {
 	})
 }
 
+/*
+	Download and decrypt a file. Only verified users of the entity that
+	uploaded the file and of the oversight organizations it shares its data
+	with can download it.
+
+	URL parameters:
+	- "username", "access_token": the user's credentials
+	- "hash": the IPFS hash of the file
+*/
 func getFiles() {
 	http.HandleFunc("/getfiles", func(w http.ResponseWriter, r *http.Request) {
 		log.Println("in getFiles endpoint")
 
-		err := erpc.CheckGet(w, r)
+		user, err := CheckGetAuth(w, r)
 		if err != nil {
 			return
 		}
 
-		if !checkReqdParams(w, r, "hash", "extension") {
+		// the extension parameter the frontend passes isn't needed since
+		// files are returned as they were uploaded
+		if !checkReqdParams(w, r, "hash") {
 			return
 		}
 
-		extension := r.URL.Query()["extension"][0]
 		hash := r.URL.Query()["hash"][0]
 
-		encryptedFile, err := ipfs.IpfsGetFile(hash, extension)
+		f, err := database.RetrieveFileByHash(hash)
 		if err != nil {
-			erpc.MarshalSend(w, erpc.StatusInternalServerError)
+			erpc.ResponseHandler(w, erpc.StatusNotFound)
 			return
 		}
 
-		encryptedBytes, err := ioutil.ReadFile(encryptedFile)
+		allowed, err := f.CanDecrypt(user)
 		if err != nil {
-			erpc.MarshalSend(w, erpc.StatusInternalServerError)
+			log.Println(err)
+			erpc.ResponseHandler(w, erpc.StatusInternalServerError)
+			return
+		}
+		if !allowed {
+			erpc.ResponseHandler(w, erpc.StatusUnauthorized)
 			return
 		}
 
-		os.Remove(encryptedFile)
+		sendFile(w, f)
+	})
+}
+
+/*
+	Rotate the key of the logged in admin's entity: the data keys of all the
+	entity's files are wrapped with a new key and the earlier keys are
+	destroyed.
 
-		decryptedBytes, err := cc20.Decrypt(encryptedBytes, globals.IpfsMasterPwd)
+	URL parameters:
+	- "username", "access_token": credentials of an admin of the entity
+*/
+func rotateFileKey() {
+	http.HandleFunc("/files/rotate-key", func(w http.ResponseWriter, r *http.Request) {
+		user, err := CheckPostAdmin(w, r)
 		if err != nil {
-			erpc.MarshalSend(w, erpc.StatusInternalServerError)
+			if user.Index != 0 && user.Verified {
+				erpc.ResponseHandler(w, erpc.StatusUnauthorized)
+			}
+			return
+		}
+
+		key, err := database.RotateEntityKey(user.EntityType, user.EntityID)
+		if err != nil {
+			log.Println(err)
+			erpc.ResponseHandler(w, erpc.StatusInternalServerError)
 			return
 		}
 
-		w.Write(decryptedBytes)
+		key.WrappedKey = nil
+		erpc.MarshalSend(w, key)
 	})
 }
 
+// Follows the pledge with the ID in the URL (/like/pledges/{id}) on behalf
+// of the logged in user, see /follow
 func addLike() {
 	http.HandleFunc("/like/pledges/", func(w http.ResponseWriter, r *http.Request) {
 		strID, err := getPutId(w, r)
@@ -681,70 +781,77 @@ func addLike() {
 			return
 		}
 
-		if !checkReqdPostParams(w, r, "accessToken", "username") {
+		pledgeID, err := strconv.Atoi(strID)
+		if err != nil {
+			erpc.ResponseHandler(w, erpc.StatusBadRequest)
 			return
 		}
 
-		accessToken := r.FormValue("accessToken")
-		username := r.FormValue("username")
-
-		user, err := database.RetrieveUserByUsername(username)
-		if err != nil {
-			erpc.ResponseHandler(w, erpc.StatusInternalServerError)
+		if !checkReqdPostParams(w, r, "accessToken", "username") {
 			return
 		}
 
-		if user.AccessToken != accessToken {
-			erpc.ResponseHandler(w, erpc.StatusBadRequest)
+		user, err := database.ValidateAccessToken(r.FormValue("username"), r.FormValue("accessToken"))
+		if err != nil || !user.Verified {
+			erpc.ResponseHandler(w, erpc.StatusUnauthorized)
 			return
 		}
 
-		// since the frontend is not expected to pass invalid requests to the liked routes, we don't validate that.
-		// this is not expected to be used by any ohter extenral parties, so this is okay I guess.
-		user.Liked = append(user.Liked, strID)
-		err = user.Save()
+		err = user.Follow("", 0, pledgeID)
 		if err != nil {
-			erpc.ResponseHandler(w, erpc.StatusInternalServerError)
+			log.Println(err)
+			erpc.ResponseHandler(w, erpc.StatusNotFound)
 			return
 		}
 
-		erpc.MarshalSend(w, erpc.StatusOK)
+		erpc.MarshalSend(w, user.Following)
 	})
 }
 
-func addNotVisible() {
+// Makes one field (emissions, mitigation, adaptation, assets or pledges) of
+// the logged in user's entity private, keeping the rest of its disclosure policy
+func hideDisclosureField() {
 	http.HandleFunc("/hide/disclosure-settings/", func(w http.ResponseWriter, r *http.Request) {
-		strID, err := getPutId(w, r)
+		err := erpc.CheckPut(w, r)
 		if err != nil {
 			log.Println(err)
+			return
+		}
+
+		urlParams := strings.Split(r.URL.Path, "/")
+		if len(urlParams) < 4 {
 			erpc.ResponseHandler(w, erpc.StatusBadRequest)
 			return
 		}
+		field := urlParams[3]
 
 		if !checkReqdPostParams(w, r, "accessToken", "username") {
 			return
 		}
 
-		accessToken := r.FormValue("accessToken")
-		username := r.FormValue("username")
+		user, err := database.ValidateAccessToken(r.FormValue("username"), r.FormValue("accessToken"))
+		if err != nil || !user.Verified {
+			erpc.ResponseHandler(w, erpc.StatusUnauthorized)
+			return
+		}
 
-		user, err := database.RetrieveUserByUsername(username)
+		policy, err := database.RetrieveDisclosurePolicy(user.EntityType, user.EntityID)
 		if err != nil {
+			log.Println(err)
 			erpc.ResponseHandler(w, erpc.StatusInternalServerError)
 			return
 		}
 
-		if user.AccessToken != accessToken {
-			erpc.ResponseHandler(w, erpc.StatusBadRequest)
-			return
+		if policy.Fields == nil {
+			policy.Fields = make(map[string]string)
 		}
+		policy.Fields[field] = database.DisclosurePrivate
+		policy.UserID = user.Index
 
-		// since the frontend is not expected to pass invalid requests to the liked routes, we don't validate that.
-		// this is not expected to be used by any ohter extenral parties, so this is okay I guess.
-		user.NotVisible = append(user.NotVisible, strID)
-		err = user.Save()
+		_, err = database.SetDisclosurePolicy(policy)
 		if err != nil {
-			erpc.ResponseHandler(w, erpc.StatusInternalServerError)
+			log.Println(err)
+			erpc.ResponseHandler(w, erpc.StatusBadRequest)
 			return
 		}
 
@@ -0,0 +1,266 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	erpc "github.com/Varunram/essentials/rpc"
+	db "github.com/YaleOpenLab/openclimate/database"
+	"github.com/YaleOpenLab/openclimate/ghg"
+	"github.com/YaleOpenLab/openclimate/ipfs"
+	"github.com/pkg/errors"
+)
+
+func setupGhgHandlers() {
+	getGridRegions()
+	getEmissionFactors()
+	calculateEmissions()
+	calculateAvoidedEmissions()
+	computeScope2()
+}
+
+// parseGWPSet parses the global warming potentials selected by the "gwp"
+// and "horizon" parameters, defaulting to globals.DefaultGWP over 100 years
+func parseGWPSet(report string, horizon string) (ghg.GWPSet, error) {
+	set := ghg.DefaultGWPSet()
+	if report != "" {
+		set.Report = report
+	}
+
+	if horizon != "" {
+		var err error
+		set.Horizon, err = strconv.Atoi(horizon)
+		if err != nil {
+			return set, err
+		}
+	}
+
+	if !ghg.ValidGWP(set) {
+		return set, errors.New("GWP must be AR4, AR5 or AR6 and the horizon 20 or 100 years")
+	}
+	return set, nil
+}
+
+/*
+	Returns the grid regions that electricity consumption can be reported
+	in, with their residual mix emission factors. Their average emission
+	factors are in the emission factor library (see /ghg/factors).
+*/
+func getGridRegions() {
+	http.HandleFunc("/ghg/grid", func(w http.ResponseWriter, r *http.Request) {
+		err := erpc.CheckGet(w, r)
+		if err != nil {
+			return
+		}
+
+		regions, err := ghg.LoadGridRegions()
+		if err != nil {
+			log.Println(err)
+			erpc.ResponseHandler(w, erpc.StatusInternalServerError)
+			return
+		}
+
+		erpc.MarshalSend(w, regions)
+	})
+}
+
+/*
+	Returns the emission factors in the library, every version published
+	for each activity.
+
+	URL parameters:
+	- "category" (optional): fuel, electricity, transport or refrigerant
+	- "activity" (optional): e.g. diesel, NEWE, passenger car or R-410A
+	- "source" (optional): the organization that published the factors
+*/
+func getEmissionFactors() {
+	http.HandleFunc("/ghg/factors", func(w http.ResponseWriter, r *http.Request) {
+		err := erpc.CheckGet(w, r)
+		if err != nil {
+			return
+		}
+
+		factors, err := ghg.FilterEmissionFactors(r.URL.Query().Get("category"),
+			r.URL.Query().Get("activity"), r.URL.Query().Get("source"))
+		if err != nil {
+			log.Println(err)
+			erpc.ResponseHandler(w, erpc.StatusInternalServerError)
+			return
+		}
+
+		erpc.MarshalSend(w, factors)
+	})
+}
+
+/*
+	Converts activity data to the quantity of each gas emitted and their
+	CO2 equivalent, using the latest emission factor for the given year.
+
+	URL parameters:
+	- "category": fuel, electricity, transport or refrigerant
+	- "activity": e.g. diesel, NEWE, passenger car or R-410A
+	- "quantity": the quantity of the activity
+	- "unit": e.g. litre, gallon, m3, kWh, km, mile or kg
+	- "year": the year the activity took place in
+	- "source" (optional): use factors published by this organization only
+	- "gwp" (optional): AR4, AR5 or AR6, the assessment report GWPs are taken from
+	- "horizon" (optional): 20 or 100, the time horizon of the GWPs in years
+*/
+func calculateEmissions() {
+	http.HandleFunc("/ghg/calculate", func(w http.ResponseWriter, r *http.Request) {
+		err := erpc.CheckGet(w, r)
+		if err != nil {
+			return
+		}
+
+		if !checkReqdParams(w, r, "category", "activity", "quantity", "unit", "year") {
+			return
+		}
+
+		quantity, err := strconv.ParseFloat(r.URL.Query()["quantity"][0], 64)
+		if err != nil {
+			erpc.ResponseHandler(w, erpc.StatusBadRequest)
+			return
+		}
+
+		year, err := strconv.Atoi(r.URL.Query()["year"][0])
+		if err != nil {
+			erpc.ResponseHandler(w, erpc.StatusBadRequest)
+			return
+		}
+
+		gwp, err := parseGWPSet(r.URL.Query().Get("gwp"), r.URL.Query().Get("horizon"))
+		if err != nil {
+			erpc.ResponseHandler(w, erpc.StatusBadRequest)
+			return
+		}
+
+		c, err := ghg.Calculate(r.URL.Query()["category"][0], r.URL.Query()["activity"][0], quantity,
+			r.URL.Query()["unit"][0], year, r.URL.Query().Get("source"), gwp)
+		if err != nil {
+			log.Println(err)
+			erpc.ResponseHandler(w, erpc.StatusBadRequest)
+			return
+		}
+
+		erpc.MarshalSend(w, c)
+	})
+}
+
+/*
+	Computes the emissions a renewable asset avoided by generating
+	electricity in a year, at the average emission factor of the grid region
+	supplying the asset's state.
+
+	URL parameters:
+	- "asset_id": the ID of the asset
+	- "generation": electricity generated in GWh
+	- "year": the year the electricity was generated in
+*/
+func calculateAvoidedEmissions() {
+	http.HandleFunc("/ghg/avoided", func(w http.ResponseWriter, r *http.Request) {
+		err := erpc.CheckGet(w, r)
+		if err != nil {
+			return
+		}
+
+		if !checkReqdParams(w, r, "asset_id", "generation", "year") {
+			return
+		}
+
+		assetID, err := strconv.Atoi(r.URL.Query()["asset_id"][0])
+		if err != nil {
+			erpc.ResponseHandler(w, erpc.StatusBadRequest)
+			return
+		}
+
+		generation, err := strconv.ParseFloat(r.URL.Query()["generation"][0], 64)
+		if err != nil {
+			erpc.ResponseHandler(w, erpc.StatusBadRequest)
+			return
+		}
+
+		year, err := strconv.Atoi(r.URL.Query()["year"][0])
+		if err != nil {
+			erpc.ResponseHandler(w, erpc.StatusBadRequest)
+			return
+		}
+
+		asset, err := db.RetrieveAsset(assetID)
+		if err != nil {
+			log.Println(err)
+			erpc.ResponseHandler(w, erpc.StatusNotFound)
+			return
+		}
+
+		avoided, err := ghg.AvoidedEmissions(asset, generation, year, ghg.DefaultGWPSet())
+		if err != nil {
+			log.Println(err)
+			erpc.ResponseHandler(w, erpc.StatusBadRequest)
+			return
+		}
+
+		erpc.MarshalSend(w, avoided)
+	})
+}
+
+/*
+	Computes location-based and market-based scope 2 emissions for the
+	logged in user's climate actor without submitting a report, applying
+	the certificates the actor holds.
+
+	Form parameters:
+	- "year": the reporting year
+	- "gwp" (optional): AR4, AR5 or AR6, the assessment report GWPs are taken from
+	- "horizon" (optional): 20 or 100, the time horizon of the GWPs in years
+	- "electricity": JSON list of the electricity consumed per grid region
+		(GridRegion, Consumption in MWh and optionally SupplierFactor)
+*/
+func computeScope2() {
+	http.HandleFunc("/ghg/scope2", func(w http.ResponseWriter, r *http.Request) {
+		user, err := CheckPostAuth(w, r)
+		if err != nil {
+			return
+		}
+
+		err = r.ParseForm()
+		if err != nil {
+			erpc.ResponseHandler(w, erpc.StatusBadRequest)
+			return
+		}
+
+		if !checkReqdPostParams(w, r, "year", "electricity") {
+			return
+		}
+
+		year, err := strconv.Atoi(r.FormValue("year"))
+		if err != nil {
+			erpc.ResponseHandler(w, erpc.StatusBadRequest)
+			return
+		}
+
+		gwp, err := parseGWPSet(r.FormValue("gwp"), r.FormValue("horizon"))
+		if err != nil {
+			erpc.ResponseHandler(w, erpc.StatusBadRequest)
+			return
+		}
+
+		var uses []ipfs.ElectricityUse
+		err = json.Unmarshal([]byte(r.FormValue("electricity")), &uses)
+		if err != nil {
+			erpc.ResponseHandler(w, erpc.StatusBadRequest)
+			return
+		}
+
+		scope2, err := ghg.ComputeActorScope2(user.EntityType, user.EntityID, uses, year, gwp)
+		if err != nil {
+			log.Println(err)
+			erpc.ResponseHandler(w, erpc.StatusBadRequest)
+			return
+		}
+
+		erpc.MarshalSend(w, scope2)
+	})
+}
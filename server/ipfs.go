@@ -6,7 +6,6 @@ import (
 	"strconv"
 
 	// "github.com/Varunram/essentials/ipfs"
-	eipfs "github.com/Varunram/essentials/ipfs"
 	erpc "github.com/Varunram/essentials/rpc"
 	"github.com/YaleOpenLab/openclimate/ipfs"
 )
@@ -15,47 +14,47 @@ func setupIpfsHandlers() {
 	RetrieveFromIpfs()
 	RetrieveAllFromIpfs()
 	getIpfsHash()
+	getPins()
 }
 
 /*
 	Request & retrieve data for a specific actor that has been committed to IPFS.
-	HTTP request to our API will provide the actor type and actor id; then
-	RetrieveFromIpfs() will look at the smart contract to retrieve all IPFS hashes
-	related to that type/ID pair. It then queries IPFS for that data using the hashes
-	and then makes that data available on the openclimate API.
+	HTTP request to our API will provide the report type, actor type and actor id;
+	RetrieveFromIpfs() then looks up all IPFS hashes related to them in the
+	content index (see ipfs/index.go), queries IPFS for that data using the
+	hashes and makes that data available on the openclimate API, keyed by the
+	period it was reported for (or the date it was committed if it doesn't
+	cover one), with the records under each key oldest first. Data on IPFS
+	includes the full breakdown of reports, so it is only returned if
+	the actor discloses it to the viewer in full.
 
 	URL parameters:
 	- "report_type": the type of climate action data that was reported. can be either
-		emissions, mitigation, or adaptation.
+		Emissions, Mitigation, or Adaptation.
 	- "actor_type": either city, country, region, state, company, etc.
 	- "actor_id": the ID assigned to the actor in the database.
+	- "username", "access_token" (optional): the viewer's credentials
 */
 func RetrieveFromIpfs() {
 	http.HandleFunc("/ipfs/retrieve", func(w http.ResponseWriter, r *http.Request) {
-
-		err := erpc.CheckGet(w, r)
+		viewer, err := CheckGetViewer(w, r)
 		if err != nil {
 			return
 		}
 
+		if !checkReqdParams(w, r, "report_type", "actor_type", "actor_id") {
+			return
+		}
+
 		reportType := r.URL.Query()["report_type"][0]
 		actorType := r.URL.Query()["actor_type"][0]
 		actorID, err := strconv.Atoi(r.URL.Query()["actor_id"][0])
 		if err != nil {
 			log.Println(err)
-			erpc.ResponseHandler(w, erpc.StatusInternalServerError)
+			erpc.ResponseHandler(w, erpc.StatusBadRequest)
 			return
 		}
 
-		/*
-			blockchain.GetFromIpfs() is not a real function yet. The function
-			will receive the actor type and the actor id, then search our smart
-			contract for all the IPFS hashes that are associated with that actor
-			type and actor id. The function will then retrieve the corresponding
-			data from IPFS using those hash content addresses and give it to us here.
-
-			For more information, see blockchain/retrieve.go
-		*/
 		data, err := ipfs.GetFromIpfs(reportType, actorType, actorID)
 		if err != nil {
 			log.Println(err)
@@ -63,40 +62,46 @@ func RetrieveFromIpfs() {
 			return
 		}
 
+		data, err = newDisclosure(viewer).discloseIpfsData(actorType, actorID, data)
+		if err != nil {
+			log.Println(err)
+			erpc.ResponseHandler(w, erpc.StatusInternalServerError)
+			return
+		}
+
 		erpc.MarshalSend(w, data)
 	})
 }
 
 /*
 	Retrieve all data related to a given actor that has been committed to IPFS.
-	This includes both emissions, mitigation, and adaptation data.
+	This includes both emissions, mitigation, and adaptation data, as far as
+	the actor discloses it to the viewer in full.
+
+	URL parameters:
+	- "actor_type": either city, country, region, state, company, etc.
+	- "actor_id": the ID assigned to the actor in the database.
+	- "username", "access_token" (optional): the viewer's credentials
 */
 func RetrieveAllFromIpfs() {
 	http.HandleFunc("/ipfs/request", func(w http.ResponseWriter, r *http.Request) {
-
-		err := erpc.CheckGet(w, r)
+		viewer, err := CheckGetViewer(w, r)
 		if err != nil {
 			return
 		}
 
-		// reportType := r.URL.Query()["report_type"][0]
+		if !checkReqdParams(w, r, "actor_type", "actor_id") {
+			return
+		}
+
 		actorType := r.URL.Query()["actor_type"][0]
 		actorID, err := strconv.Atoi(r.URL.Query()["actor_id"][0])
 		if err != nil {
 			log.Println(err)
-			erpc.ResponseHandler(w, erpc.StatusInternalServerError)
+			erpc.ResponseHandler(w, erpc.StatusBadRequest)
 			return
 		}
 
-		/*
-			blockchain.GetFromIpfs() is not a real function yet. The function
-			will receive the actor type and the actor id, then search our smart
-			contract for all the IPFS hashes that are associated with that actor
-			type and actor id. The function will then retrieve the corresponding
-			data from IPFS using those hash content addresses and give it to us here.
-
-			For more information, see blockchain/retrieve.go
-		*/
 		data, err := ipfs.GetAllFromIpfs(actorType, actorID)
 		if err != nil {
 			log.Println(err)
@@ -104,6 +109,13 @@ func RetrieveAllFromIpfs() {
 			return
 		}
 
+		data, err = newDisclosure(viewer).discloseIpfsData(actorType, actorID, data)
+		if err != nil {
+			log.Println(err)
+			erpc.ResponseHandler(w, erpc.StatusInternalServerError)
+			return
+		}
+
 		erpc.MarshalSend(w, data)
 	})
 }
@@ -121,15 +133,15 @@ func getIpfsHash() {
 		}
 
 		hashString := r.URL.Query()["string"][0]
-		hash, err := eipfs.IpfsAddString(hashString)
+		hash, err := ipfs.Put([]byte(hashString))
 		if err != nil {
 			log.Println("did not add string to ipfs", err)
 			erpc.ResponseHandler(w, erpc.StatusInternalServerError)
 			return
 		}
 
-		hashCheck, err := eipfs.IpfsGetString(hash)
-		if err != nil || hashCheck != hashString {
+		hashCheck, err := ipfs.Get(hash)
+		if err != nil || string(hashCheck) != hashString {
 			erpc.ResponseHandler(w, erpc.StatusInternalServerError)
 			return
 		}
@@ -137,3 +149,46 @@ func getIpfsHash() {
 		erpc.MarshalSend(w, hash)
 	})
 }
+
+/*
+	Retrieve the status of the pins of the data an actor committed or
+	uploaded: whether the data is pinned, couldn't be pinned, or was lost.
+	Only the actor's own verified users can see its pins.
+
+	URL parameters:
+	- "actor_type": either city, country, region, state, company, etc.
+	- "actor_id": the ID assigned to the actor in the database.
+*/
+func getPins() {
+	http.HandleFunc("/pins", func(w http.ResponseWriter, r *http.Request) {
+		user, err := CheckGetAuth(w, r)
+		if err != nil {
+			return
+		}
+
+		if !checkReqdParams(w, r, "actor_type", "actor_id") {
+			return
+		}
+
+		actorType := r.URL.Query()["actor_type"][0]
+		actorID, err := strconv.Atoi(r.URL.Query()["actor_id"][0])
+		if err != nil {
+			erpc.ResponseHandler(w, erpc.StatusBadRequest)
+			return
+		}
+
+		if !user.Verified || user.EntityType != actorType || user.EntityID != actorID {
+			erpc.ResponseHandler(w, erpc.StatusUnauthorized)
+			return
+		}
+
+		summary, err := ipfs.SummarizePins(actorType, actorID)
+		if err != nil {
+			log.Println(err)
+			erpc.ResponseHandler(w, erpc.StatusInternalServerError)
+			return
+		}
+
+		erpc.MarshalSend(w, summary)
+	})
+}
@@ -23,6 +23,7 @@ func setupManage() {
 	UpdatePledge()
 	CommitPledge()
 	UpdateMRV()
+	AddCertificate()
 	integrateRequest()
 }
 
@@ -230,6 +231,109 @@ func AddPledge() {
 	})
 }
 
+/*
+	Registers an energy attribute certificate (REC, GO, I-REC) or power
+	purchase agreement held by the user's company. Retired certificates are
+	applied to the company's market-based scope 2 emissions. A certificate
+	that is already registered, by any company, is rejected.
+
+	Form parameters:
+	- "certificate_id": the ID of the certificate in its registry
+	- "type": REC, GO, I-REC or PPA
+	- "volume": the electricity the certificate covers
+	- "unit": kWh, MWh or GWh
+	- "year": the year the electricity was generated
+	- "market": the grid region or country the certificate was issued in
+	- "status" (optional): active (default) or retired
+	- "emission_factor" (optional): tCO2e/MWh of the generation, 0 if not set
+*/
+func AddCertificate() {
+	http.HandleFunc("/manage/certificates/add", func(w http.ResponseWriter, r *http.Request) {
+		user, err := CheckPostAdmin(w, r)
+		if err != nil {
+			log.Println(err)
+			erpc.ResponseHandler(w, erpc.StatusUnauthorized)
+			return
+		}
+
+		if user.EntityType != "company" {
+			erpc.ResponseHandler(w, erpc.StatusBadRequest)
+			return
+		}
+
+		err = r.ParseForm()
+		if err != nil {
+			erpc.ResponseHandler(w, erpc.StatusBadRequest)
+			return
+		}
+
+		if !checkReqdPostParams(w, r, "certificate_id", "type", "volume", "unit", "year", "market") {
+			return
+		}
+
+		var cert db.Certificate
+		cert.Type = r.FormValue("type")
+		cert.Unit = r.FormValue("unit")
+		cert.Market = r.FormValue("market")
+		cert.Status = r.FormValue("status")
+		if cert.Status == "" {
+			cert.Status = db.CertificateActive
+		}
+
+		cert.CertificateID, err = strconv.Atoi(r.FormValue("certificate_id"))
+		if err != nil {
+			erpc.ResponseHandler(w, erpc.StatusBadRequest)
+			return
+		}
+		cert.Year, err = strconv.Atoi(r.FormValue("year"))
+		if err != nil {
+			erpc.ResponseHandler(w, erpc.StatusBadRequest)
+			return
+		}
+		cert.Volume, err = strconv.ParseFloat(r.FormValue("volume"), 64)
+		if err != nil || cert.Volume < 0 {
+			erpc.ResponseHandler(w, erpc.StatusBadRequest)
+			return
+		}
+		if factor := r.FormValue("emission_factor"); factor != "" {
+			cert.EmissionFactor, err = strconv.ParseFloat(factor, 64)
+			if err != nil || cert.EmissionFactor < 0 {
+				erpc.ResponseHandler(w, erpc.StatusBadRequest)
+				return
+			}
+		}
+
+		if cert.Status != db.CertificateActive && cert.Status != db.CertificateRetired {
+			erpc.ResponseHandler(w, erpc.StatusBadRequest)
+			return
+		}
+		if cert.Unit != "kWh" && cert.Unit != "MWh" && cert.Unit != "GWh" {
+			erpc.ResponseHandler(w, erpc.StatusBadRequest)
+			return
+		}
+
+		company, err := db.RetrieveCompany(user.EntityID)
+		if err != nil {
+			log.Println(err)
+			erpc.ResponseHandler(w, erpc.StatusInternalServerError)
+			return
+		}
+
+		cert, err = company.AddCertificate(cert)
+		if err == db.ErrDuplicateCertificate {
+			erpc.ResponseHandler(w, erpc.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			log.Println(err)
+			erpc.ResponseHandler(w, erpc.StatusInternalServerError)
+			return
+		}
+
+		erpc.MarshalSend(w, cert)
+	})
+}
+
 func UpdatePledge() {
 	http.HandleFunc("/manage/pledges/update", func(w http.ResponseWriter, r *http.Request) {
 
@@ -300,7 +404,7 @@ func CommitPledge() {
 			return
 		}
 
-		ipfsHash, err := ipfs.IpfsCommitData(pledge)
+		ipfsHash, err := ipfs.IpfsCommitData("Pledge", pledge.ActorType, pledge.ActorID, "", pledge)
 		if err != nil {
 			log.Println(err)
 			erpc.ResponseHandler(w, erpc.StatusInternalServerError)
@@ -0,0 +1,90 @@
+package server
+
+import (
+	"log"
+	"net/http"
+
+	erpc "github.com/Varunram/essentials/rpc"
+	"github.com/Varunram/essentials/utils"
+	db "github.com/YaleOpenLab/openclimate/database"
+	"github.com/YaleOpenLab/openclimate/paris"
+)
+
+func setupParisHandlers() {
+	getCarbonBudgetStatus()
+	getPledgeAlignment()
+}
+
+/*
+	Returns the remaining 1.5C and 2C carbon budgets, computed from the
+	global carbon budget datasets bundled in staticdata/.
+*/
+func getCarbonBudgetStatus() {
+	http.HandleFunc("/paris/budget", func(w http.ResponseWriter, r *http.Request) {
+		err := erpc.CheckGet(w, r)
+		if err != nil {
+			return
+		}
+
+		status, err := paris.GetStatus()
+		if err != nil {
+			log.Println(err)
+			erpc.ResponseHandler(w, erpc.StatusInternalServerError)
+			return
+		}
+
+		erpc.MarshalSend(w, status)
+	})
+}
+
+/*
+	Scores an actor's emission reduction pledges against the carbon budget
+	and returns the warming they imply.
+
+	URL parameters:
+	- "actor_type": either city, country, region, state, company, etc.
+	- "actor_id": the ID assigned to the actor in the database.
+	- "username", "access_token" (optional): the viewer's credentials, the
+		pledges of actors that keep them private are only scored for viewers
+		they are disclosed to.
+*/
+func getPledgeAlignment() {
+	http.HandleFunc("/paris/alignment", func(w http.ResponseWriter, r *http.Request) {
+		viewer, err := CheckGetViewer(w, r)
+		if err != nil {
+			return
+		}
+
+		if !checkReqdParams(w, r, "actor_type", "actor_id") {
+			return
+		}
+
+		actorType := r.URL.Query()["actor_type"][0]
+		actorID, err := utils.ToInt(r.URL.Query()["actor_id"][0])
+		if err != nil {
+			log.Println(err)
+			erpc.ResponseHandler(w, erpc.StatusBadRequest)
+			return
+		}
+
+		access, err := newDisclosure(viewer).access(actorType, actorID, db.FieldPledges)
+		if err != nil {
+			log.Println(err)
+			erpc.ResponseHandler(w, erpc.StatusInternalServerError)
+			return
+		}
+		if access == db.AccessNone {
+			erpc.ResponseHandler(w, erpc.StatusUnauthorized)
+			return
+		}
+
+		alignment, err := paris.ScoreActor(actorType, actorID)
+		if err != nil {
+			log.Println(err)
+			erpc.ResponseHandler(w, erpc.StatusInternalServerError)
+			return
+		}
+
+		erpc.MarshalSend(w, alignment)
+	})
+}
@@ -0,0 +1,153 @@
+package server
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	erpc "github.com/Varunram/essentials/rpc"
+	db "github.com/YaleOpenLab/openclimate/database"
+	"github.com/YaleOpenLab/openclimate/oracle"
+)
+
+func setupReconcileHandlers() {
+	runReconciliation()
+	getDiscrepancies()
+	reviewDiscrepancy()
+}
+
+// isOversight checks whether a user belongs to an oversight organization
+func isOversight(user db.User) bool {
+	return user.EntityType == "oversight"
+}
+
+/*
+	Compare self-reported values against the static datasets and the other
+	sources in the time series right away instead of waiting for the
+	scheduled job. Only oversight organizations can start a reconciliation.
+	Returns the discrepancies flagged in this run.
+*/
+func runReconciliation() {
+	http.HandleFunc("/reconcile/run", func(w http.ResponseWriter, r *http.Request) {
+		user, err := CheckPostAuth(w, r)
+		if err != nil {
+			return
+		}
+
+		if !isOversight(user) {
+			erpc.ResponseHandler(w, erpc.StatusUnauthorized)
+			return
+		}
+
+		flagged, err := oracle.Reconcile()
+		if err != nil {
+			log.Println(err)
+			erpc.ResponseHandler(w, erpc.StatusInternalServerError)
+			return
+		}
+
+		erpc.MarshalSend(w, flagged)
+	})
+}
+
+/*
+	List the discrepancies flagged by reconciliation, largest relative
+	difference first.
+
+	URL parameters:
+	- "status" (optional): open, confirmed, dismissed or resolved.
+	- "actor_type" (optional): either city, country, region, state, company, etc.
+	- "actor_id" (optional): the ID assigned to the actor in the database.
+	- "username", "access_token" (optional): the viewer's credentials.
+		Discrepancies in values an actor doesn't disclose to the viewer are
+		left out.
+*/
+func getDiscrepancies() {
+	http.HandleFunc("/reconcile/discrepancies", func(w http.ResponseWriter, r *http.Request) {
+		viewer, err := CheckGetViewer(w, r)
+		if err != nil {
+			return
+		}
+
+		var actorID int
+		if id := r.URL.Query().Get("actor_id"); id != "" {
+			actorID, err = strconv.Atoi(id)
+			if err != nil {
+				log.Println(err)
+				erpc.ResponseHandler(w, erpc.StatusBadRequest)
+				return
+			}
+		}
+
+		discrepancies, err := db.FilterDiscrepancies(r.URL.Query().Get("status"),
+			r.URL.Query().Get("actor_type"), actorID)
+		if err != nil {
+			log.Println(err)
+			erpc.ResponseHandler(w, erpc.StatusInternalServerError)
+			return
+		}
+
+		discrepancies, err = newDisclosure(viewer).discloseDiscrepancies(discrepancies)
+		if err != nil {
+			log.Println(err)
+			erpc.ResponseHandler(w, erpc.StatusInternalServerError)
+			return
+		}
+
+		erpc.MarshalSend(w, discrepancies)
+	})
+}
+
+/*
+	Record an oversight organization's review of a discrepancy.
+
+	Form parameters:
+	- "discrepancy_id": the ID of the discrepancy.
+	- "status": confirmed if the self-reported value is wrong, dismissed
+		if it is correct.
+	- "comment" (optional): the reasoning behind the review.
+*/
+func reviewDiscrepancy() {
+	http.HandleFunc("/reconcile/review", func(w http.ResponseWriter, r *http.Request) {
+		user, err := CheckPostAuth(w, r)
+		if err != nil {
+			return
+		}
+
+		if !isOversight(user) {
+			erpc.ResponseHandler(w, erpc.StatusUnauthorized)
+			return
+		}
+
+		err = r.ParseForm()
+		if err != nil {
+			erpc.ResponseHandler(w, erpc.StatusBadRequest)
+			return
+		}
+
+		if !checkReqdPostParams(w, r, "discrepancy_id", "status") {
+			return
+		}
+
+		id, err := strconv.Atoi(r.FormValue("discrepancy_id"))
+		if err != nil {
+			erpc.ResponseHandler(w, erpc.StatusBadRequest)
+			return
+		}
+
+		status := r.FormValue("status")
+		if status != db.DiscrepancyConfirmed && status != db.DiscrepancyDismissed {
+			erpc.ResponseHandler(w, erpc.StatusBadRequest)
+			return
+		}
+
+		d, err := db.ReviewDiscrepancy(id, user.EntityID, status, r.FormValue("comment"))
+		if err != nil {
+			log.Println(err)
+			erpc.ResponseHandler(w, erpc.StatusNotFound)
+			return
+		}
+
+		erpc.MarshalSend(w, d)
+	})
+}
@@ -5,24 +5,39 @@ import (
 	// "io/ioutil"
 	"log"
 	"net/http"
-	// "strconv"
-	// "github.com/pkg/errors"
+	"strconv"
+
+	"github.com/pkg/errors"
 
 	erpc "github.com/Varunram/essentials/rpc"
-	// db "github.com/YaleOpenLab/openclimate/database"
-	// "github.com/YaleOpenLab/openclimate/ipfs"
+	db "github.com/YaleOpenLab/openclimate/database"
+	"github.com/YaleOpenLab/openclimate/ghg"
+	"github.com/YaleOpenLab/openclimate/ipfs"
 	"github.com/YaleOpenLab/openclimate/oracle"
 )
 
 func setupReport() {
 	reportDirect()
+	getActorReports()
+	verifyReport()
+	getActorReportSummary()
+	getActorGases()
 }
 
 /*
 	Handler that allows actors to self-report their climate action data.
 	The data in the body of the POST request must follow the format of
 	either the Emissions, Mitigation, or Adaptation structs defined in
-	ipfs/data.go.
+	ipfs/data.go. Emissions can be reported as activity data (fuel burned,
+	distance travelled, refrigerant leaked) instead of pre-computed CO2e,
+	which is converted with the emission factor library in ghg/.
+
+	Form parameters:
+	- "report_type": either Emissions, Mitigation or Adaptation
+	- "data": the report encoded as JSON
+
+	Reports that fail validation are rejected with a 400 and the list of
+	fields that need to be fixed.
 */
 func reportDirect() {
 	http.HandleFunc("/report/direct", func(w http.ResponseWriter, r *http.Request) {
@@ -33,24 +48,315 @@ func reportDirect() {
 
 		err = r.ParseForm()
 		if err != nil {
-			erpc.ResponseHandler(w, erpc.StatusInternalServerError)
+			erpc.ResponseHandler(w, erpc.StatusBadRequest)
 			return
 		}
 
-		if !checkReqdParams(w, r, "report_type", "data") {
+		if !checkReqdPostParams(w, r, "report_type", "data") {
 			return
 		}
 
 		reportType := r.FormValue("report_type")
 		data := r.FormValue("data")
-		err = oracle.VerifyAndCommit(reportType, user.EntityType, user.EntityID, data)
+
+		report, err := oracle.ParseReport(reportType, user.EntityType, user.EntityID, []byte(data))
+		if err != nil {
+			log.Println(err)
+			if oracle.IsValidationError(err) {
+				responseErrors(w, erpc.StatusBadRequest, errors.Cause(err))
+				return
+			}
+			erpc.ResponseHandler(w, erpc.StatusInternalServerError)
+			return
+		}
+
+		// commit to ipfs and the blockchain
+		ipfsHash, err := oracle.VerifyAndCommit(reportType, user.EntityType, user.EntityID, report)
 		if err != nil {
-			log.Fatal(err)
+			log.Println(err)
 			erpc.ResponseHandler(w, erpc.StatusInternalServerError)
+			return
+		}
+
+		stored, err := storeReport(user.EntityType, user.EntityID, ipfsHash, report)
+		if err != nil {
+			log.Println(err)
+			erpc.ResponseHandler(w, erpc.StatusInternalServerError)
+			return
+		}
+
+		erpc.MarshalSend(w, stored)
+	})
+}
+
+// storeReport saves a report that has been committed to IPFS in the database
+func storeReport(actorType string, actorID int, ipfsHash string, report interface{}) (db.Report, error) {
+	switch data := report.(type) {
+	case ipfs.Emissions:
+		return db.NewEmissionsReport(actorType, actorID, ipfsHash, data)
+	case ipfs.Mitigation:
+		return db.NewMitigationReport(actorType, actorID, ipfsHash, data)
+	case ipfs.Adaptation:
+		return db.NewAdaptationReport(actorType, actorID, ipfsHash, data)
+	default:
+		var empty db.Report
+		return empty, errors.New("can't store reports of this type")
+	}
+}
+
+/*
+	Retrieve the reports a climate actor has submitted. Reports the actor
+	doesn't disclose to the viewer are left out, and reports it only
+	discloses in aggregate are returned without their breakdowns.
+
+	URL parameters:
+	- "actor_type": either city, country, region, state, company, etc.
+	- "actor_id": the ID assigned to the actor in the database.
+	- "report_type" (optional): either Emissions, Mitigation or Adaptation.
+		Reports of all types are returned if it is not set.
+	- "username", "access_token" (optional): the viewer's credentials
+*/
+func getActorReports() {
+	http.HandleFunc("/report/actor", func(w http.ResponseWriter, r *http.Request) {
+		viewer, err := CheckGetViewer(w, r)
+		if err != nil {
+			return
+		}
+
+		if !checkReqdParams(w, r, "actor_type", "actor_id") {
+			return
+		}
+
+		actorType := r.URL.Query()["actor_type"][0]
+		actorID, err := strconv.Atoi(r.URL.Query()["actor_id"][0])
+		if err != nil {
+			log.Println(err)
+			erpc.ResponseHandler(w, erpc.StatusBadRequest)
+			return
+		}
+
+		reportType := r.URL.Query().Get("report_type")
+
+		reports, err := db.RetrieveActorReports(actorType, actorID, reportType)
+		if err != nil {
+			log.Println(err)
+			erpc.ResponseHandler(w, erpc.StatusInternalServerError)
+			return
+		}
+
+		reports, err = newDisclosure(viewer).discloseReports(reports)
+		if err != nil {
+			log.Println(err)
+			erpc.ResponseHandler(w, erpc.StatusInternalServerError)
+			return
+		}
+
+		erpc.MarshalSend(w, reports)
+	})
+}
+
+/*
+	Allows members of an oversight organization to vouch for a report they
+	reviewed. Verifications count towards the actor's accountability score
+	with the weight of the organization.
+
+	Form parameters:
+	- "report_id": the ID of the report
+*/
+func verifyReport() {
+	http.HandleFunc("/report/verify", func(w http.ResponseWriter, r *http.Request) {
+		user, err := CheckPostAuth(w, r)
+		if err != nil {
+			return
+		}
+
+		if user.EntityType != "oversight" || !user.Verified {
+			erpc.ResponseHandler(w, erpc.StatusUnauthorized)
+			return
+		}
+
+		err = r.ParseForm()
+		if err != nil {
+			erpc.ResponseHandler(w, erpc.StatusBadRequest)
+			return
+		}
+
+		if !checkReqdPostParams(w, r, "report_id") {
+			return
+		}
+
+		reportID, err := strconv.Atoi(r.FormValue("report_id"))
+		if err != nil {
+			erpc.ResponseHandler(w, erpc.StatusBadRequest)
+			return
+		}
+
+		report, err := db.RetrieveReport(reportID)
+		if err != nil {
+			log.Println(err)
+			erpc.ResponseHandler(w, erpc.StatusNotFound)
+			return
+		}
+
+		err = report.Verify(user.EntityID, user.Index)
+		if err != nil {
+			log.Println(err)
+			erpc.ResponseHandler(w, erpc.StatusInternalServerError)
+			return
+		}
+
+		erpc.MarshalSend(w, report)
+	})
+}
+
+/*
+	Aggregate the emissions, mitigation and adaptation reports a climate
+	actor has submitted by year, from the reports it discloses to the viewer.
+
+	URL parameters:
+	- "actor_type": either city, country, region, state, company, etc.
+	- "actor_id": the ID assigned to the actor in the database.
+	- "gwp" (optional): AR4, AR5 or AR6, converts the gas inventory with the
+		GWPs of this assessment report instead of the ones each report chose
+	- "horizon" (optional): 20 or 100, the time horizon of the GWPs in years
+	- "username", "access_token" (optional): the viewer's credentials
+*/
+func getActorReportSummary() {
+	http.HandleFunc("/report/summary", func(w http.ResponseWriter, r *http.Request) {
+		viewer, err := CheckGetViewer(w, r)
+		if err != nil {
+			return
+		}
+
+		if !checkReqdParams(w, r, "actor_type", "actor_id") {
+			return
+		}
+
+		actorType := r.URL.Query()["actor_type"][0]
+		actorID, err := strconv.Atoi(r.URL.Query()["actor_id"][0])
+		if err != nil {
+			log.Println(err)
+			erpc.ResponseHandler(w, erpc.StatusBadRequest)
+			return
+		}
+
+		reports, err := db.RetrieveActorReports(actorType, actorID, "")
+		if err != nil {
+			log.Println(err)
+			erpc.ResponseHandler(w, erpc.StatusInternalServerError)
+			return
+		}
+
+		reports, err = newDisclosure(viewer).discloseReports(reports)
+		if err != nil {
+			log.Println(err)
+			erpc.ResponseHandler(w, erpc.StatusInternalServerError)
+			return
+		}
+
+		summaries := db.SummarizeReports(reports)
+
+		if r.URL.Query().Get("gwp") != "" || r.URL.Query().Get("horizon") != "" {
+			gwp, err := parseGWPSet(r.URL.Query().Get("gwp"), r.URL.Query().Get("horizon"))
+			if err != nil {
+				erpc.ResponseHandler(w, erpc.StatusBadRequest)
+				return
+			}
+
+			for i, summary := range summaries {
+				summaries[i].Inventory, err = ghg.ConvertGases(summary.Inventory, gwp)
+				if err != nil {
+					log.Println(err)
+					erpc.ResponseHandler(w, erpc.StatusInternalServerError)
+					return
+				}
+			}
+		}
+
+		erpc.MarshalSend(w, summaries)
+	})
+}
+
+// GasBreakdown is a climate actor's emissions of each Kyoto gas group by
+// scope in a year
+type GasBreakdown struct {
+	Year  int
+	GWP   ghg.GWPSet
+	Gases []ipfs.GasEmissions
+}
+
+/*
+	Break the emissions a climate actor reported down by scope and Kyoto gas
+	group (CO2, CH4, N2O, HFCs, PFCs, SF6 and NF3) for every year, in tonnes
+	and in CO2e. Only available if the actor discloses its emissions to the
+	viewer in full.
+
+	URL parameters:
+	- "actor_type": either city, country, region, state, company, etc.
+	- "actor_id": the ID assigned to the actor in the database.
+	- "gwp" (optional): AR4, AR5 or AR6, the assessment report GWPs are taken from
+	- "horizon" (optional): 20 or 100, the time horizon of the GWPs in years
+	- "username", "access_token" (optional): the viewer's credentials
+*/
+func getActorGases() {
+	http.HandleFunc("/report/gases", func(w http.ResponseWriter, r *http.Request) {
+		viewer, err := CheckGetViewer(w, r)
+		if err != nil {
+			return
+		}
+
+		if !checkReqdParams(w, r, "actor_type", "actor_id") {
+			return
+		}
+
+		actorType := r.URL.Query()["actor_type"][0]
+		actorID, err := strconv.Atoi(r.URL.Query()["actor_id"][0])
+		if err != nil {
+			log.Println(err)
+			erpc.ResponseHandler(w, erpc.StatusBadRequest)
+			return
+		}
+
+		gwp, err := parseGWPSet(r.URL.Query().Get("gwp"), r.URL.Query().Get("horizon"))
+		if err != nil {
+			erpc.ResponseHandler(w, erpc.StatusBadRequest)
+			return
+		}
+
+		// the gases are a breakdown of the reported totals
+		access, err := newDisclosure(viewer).access(actorType, actorID, db.FieldEmissions)
+		if err != nil {
+			log.Println(err)
+			erpc.ResponseHandler(w, erpc.StatusInternalServerError)
+			return
+		}
+		if access != db.AccessFull {
+			erpc.ResponseHandler(w, erpc.StatusUnauthorized)
+			return
+		}
+
+		reports, err := db.RetrieveActorReports(actorType, actorID, "Emissions")
+		if err != nil {
+			log.Println(err)
+			erpc.ResponseHandler(w, erpc.StatusInternalServerError)
+			return
+		}
+
+		var breakdowns []GasBreakdown
+		for _, summary := range db.SummarizeReports(reports) {
+			var breakdown GasBreakdown
+			breakdown.Year = summary.Year
+			breakdown.GWP = gwp
+			breakdown.Gases, err = ghg.Breakdown(summary.Inventory, gwp)
+			if err != nil {
+				log.Println(err)
+				erpc.ResponseHandler(w, erpc.StatusInternalServerError)
+				return
+			}
+			breakdowns = append(breakdowns, breakdown)
 		}
-		// commit to blockchain
 
-		// erpc.MarshalSend(w, ipfsHash)
+		erpc.MarshalSend(w, breakdowns)
 	})
 }
 
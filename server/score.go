@@ -0,0 +1,128 @@
+package server
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	erpc "github.com/Varunram/essentials/rpc"
+	"github.com/Varunram/essentials/utils"
+	db "github.com/YaleOpenLab/openclimate/database"
+	"github.com/YaleOpenLab/openclimate/score"
+)
+
+func setupScoreHandlers() {
+	getActorScore()
+	getScoreWeights()
+}
+
+/*
+	Rates how accountable an actor is, from the completeness and verification
+	of its reporting and the ambition of and progress towards its pledges.
+	Returns each component of the score with the points it contributed, and
+	the actor's emissions broken down into direct, indirect and untrackable.
+
+	URL parameters:
+	- "actor_type": either city, country, region, state, company, etc.
+	- "actor_id": the ID assigned to the actor in the database.
+	- "completeness", "verification", "ambition", "progress" (optional):
+		weights replacing the default weights of the components
+	- "verification_target" (optional): total weight of the oversight
+		organizations that verified a report for it to count as fully verified
+	- "username", "access_token" (optional): the viewer's credentials. The
+		emissions breakdown is left out if the actor keeps its emissions private.
+*/
+func getActorScore() {
+	http.HandleFunc("/score", func(w http.ResponseWriter, r *http.Request) {
+		viewer, err := CheckGetViewer(w, r)
+		if err != nil {
+			return
+		}
+
+		if !checkReqdParams(w, r, "actor_type", "actor_id") {
+			return
+		}
+
+		actorType := r.URL.Query()["actor_type"][0]
+		actorID, err := utils.ToInt(r.URL.Query()["actor_id"][0])
+		if err != nil {
+			log.Println(err)
+			erpc.ResponseHandler(w, erpc.StatusBadRequest)
+			return
+		}
+
+		_, err = db.RetrieveActor(actorType, actorID)
+		if err != nil {
+			log.Println(err)
+			erpc.ResponseHandler(w, erpc.StatusNotFound)
+			return
+		}
+
+		weights, err := score.LoadWeights()
+		if err != nil {
+			log.Println(err)
+			erpc.ResponseHandler(w, erpc.StatusInternalServerError)
+			return
+		}
+
+		params := map[string]*float64{
+			"completeness":        &weights.Completeness,
+			"verification":        &weights.Verification,
+			"ambition":            &weights.Ambition,
+			"progress":            &weights.Progress,
+			"verification_target": &weights.VerificationTarget,
+		}
+		for param, weight := range params {
+			if r.URL.Query().Get(param) == "" {
+				continue
+			}
+			*weight, err = strconv.ParseFloat(r.URL.Query().Get(param), 64)
+			if err != nil {
+				erpc.ResponseHandler(w, erpc.StatusBadRequest)
+				return
+			}
+		}
+
+		err = weights.Validate()
+		if err != nil {
+			log.Println(err)
+			erpc.ResponseHandler(w, erpc.StatusBadRequest)
+			return
+		}
+
+		s, err := score.ScoreActor(actorType, actorID, weights)
+		if err != nil {
+			log.Println(err)
+			erpc.ResponseHandler(w, erpc.StatusInternalServerError)
+			return
+		}
+
+		s, err = newDisclosure(viewer).discloseScore(s)
+		if err != nil {
+			log.Println(err)
+			erpc.ResponseHandler(w, erpc.StatusInternalServerError)
+			return
+		}
+
+		erpc.MarshalSend(w, s)
+	})
+}
+
+// Returns the default weights of the components of the accountability score
+func getScoreWeights() {
+	http.HandleFunc("/score/weights", func(w http.ResponseWriter, r *http.Request) {
+		err := erpc.CheckGet(w, r)
+		if err != nil {
+			return
+		}
+
+		weights, err := score.LoadWeights()
+		if err != nil {
+			log.Println(err)
+			erpc.ResponseHandler(w, erpc.StatusInternalServerError)
+			return
+		}
+
+		erpc.MarshalSend(w, weights)
+	})
+}
@@ -0,0 +1,178 @@
+package server
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	erpc "github.com/Varunram/essentials/rpc"
+	db "github.com/YaleOpenLab/openclimate/database"
+)
+
+func setupSeriesHandlers() {
+	reportDataPoint()
+	getSeries()
+}
+
+/*
+	Record a single value in the time series of the logged in user's
+	climate actor. A value recorded earlier for the same metric, period
+	and source is replaced.
+
+	Values from other sources are compared against the self-reported ones
+	(see oracle/reconcile.go), so only oversight organizations can record
+	them, for the actor given by "actor_type" and "actor_id".
+
+	Form parameters:
+	- "metric": e.g. scope1, scope2, scope3, scope1/CH4 or scope2/Energy
+	- "period": either a year (YYYY) or a month (YYYY-MM)
+	- "value": the reported value
+	- "unit" (optional): the unit of the value, tCO2e if not set
+	- "source" (oversight organizations only): where the value comes from
+	- "actor_type" (oversight organizations only): city, country, region, state, company, etc.
+	- "actor_id" (oversight organizations only): the ID assigned to the actor in the database.
+*/
+func reportDataPoint() {
+	http.HandleFunc("/series/report", func(w http.ResponseWriter, r *http.Request) {
+		user, err := CheckPostAuth(w, r)
+		if err != nil {
+			return
+		}
+
+		err = r.ParseForm()
+		if err != nil {
+			erpc.ResponseHandler(w, erpc.StatusBadRequest)
+			return
+		}
+
+		if !checkReqdPostParams(w, r, "metric", "period", "value") {
+			return
+		}
+
+		var point db.DataPoint
+		point.ActorType = user.EntityType
+		point.ActorID = user.EntityID
+		point.Metric = r.FormValue("metric")
+		point.Period = r.FormValue("period")
+		point.Unit = r.FormValue("unit")
+		point.Source = db.SourceSelfReported
+
+		point.Value, err = strconv.ParseFloat(r.FormValue("value"), 64)
+		if err != nil || !db.ValidPeriod(point.Period) {
+			erpc.ResponseHandler(w, erpc.StatusBadRequest)
+			return
+		}
+
+		if point.Unit == "" {
+			point.Unit = "tCO2e"
+		}
+
+		if isOversight(user) {
+			if !checkReqdPostParams(w, r, "source", "actor_type", "actor_id") {
+				return
+			}
+
+			point.Source = r.FormValue("source")
+			point.ActorType = r.FormValue("actor_type")
+			point.ActorID, err = strconv.Atoi(r.FormValue("actor_id"))
+			if err != nil || point.Source == db.SourceSelfReported {
+				erpc.ResponseHandler(w, erpc.StatusBadRequest)
+				return
+			}
+		} else if source := r.FormValue("source"); source != "" && source != db.SourceSelfReported {
+			erpc.ResponseHandler(w, erpc.StatusUnauthorized)
+			return
+		}
+
+		point, err = db.RecordDataPoint(point)
+		if err != nil {
+			log.Println(err)
+			erpc.ResponseHandler(w, erpc.StatusInternalServerError)
+			return
+		}
+
+		erpc.MarshalSend(w, point)
+	})
+}
+
+/*
+	Retrieve the time series of a metric for a climate actor. The response
+	maps each source to its values sorted by period, so that sources can be
+	compared side by side.
+
+	URL parameters:
+	- "actor_type": either city, country, region, state, company, etc.
+	- "actor_id": the ID assigned to the actor in the database.
+	- "metric": e.g. scope1, scope2, scope3, scope1/CH4 or scope2/Energy
+	- "from" (optional): first period to return, YYYY or YYYY-MM
+	- "to" (optional): last period to return, YYYY or YYYY-MM
+	- "granularity" (optional): annual (default) or monthly
+	- "sources" (optional): comma separated list of sources to return,
+		all sources are returned if it is not set.
+	- "username", "access_token" (optional): the viewer's credentials
+
+	Values the actor reported itself (or rolled up from its assets) are only
+	returned if the actor discloses the metric to the viewer. Breakdowns of
+	totals, like a single gas, need full disclosure.
+*/
+func getSeries() {
+	http.HandleFunc("/series", func(w http.ResponseWriter, r *http.Request) {
+		viewer, err := CheckGetViewer(w, r)
+		if err != nil {
+			return
+		}
+
+		if !checkReqdParams(w, r, "actor_type", "actor_id", "metric") {
+			return
+		}
+
+		var query db.SeriesQuery
+		query.ActorType = r.URL.Query()["actor_type"][0]
+		query.ActorID, err = strconv.Atoi(r.URL.Query()["actor_id"][0])
+		if err != nil {
+			log.Println(err)
+			erpc.ResponseHandler(w, erpc.StatusBadRequest)
+			return
+		}
+
+		query.Metric = r.URL.Query()["metric"][0]
+		query.From = r.URL.Query().Get("from")
+		query.To = r.URL.Query().Get("to")
+		query.Granularity = r.URL.Query().Get("granularity")
+
+		if sources := r.URL.Query().Get("sources"); sources != "" {
+			query.Sources = strings.Split(sources, ",")
+		}
+
+		if query.Granularity != "" && query.Granularity != db.Annual && query.Granularity != db.Monthly {
+			erpc.ResponseHandler(w, erpc.StatusBadRequest)
+			return
+		}
+		if (query.From != "" && !db.ValidPeriod(query.From)) || (query.To != "" && !db.ValidPeriod(query.To)) {
+			erpc.ResponseHandler(w, erpc.StatusBadRequest)
+			return
+		}
+
+		series, err := db.QuerySeries(query)
+		if err != nil {
+			log.Println(err)
+			erpc.ResponseHandler(w, erpc.StatusInternalServerError)
+			return
+		}
+
+		field, breakdown := metricField(query.Metric)
+		access, err := newDisclosure(viewer).access(query.ActorType, query.ActorID, field)
+		if err != nil {
+			log.Println(err)
+			erpc.ResponseHandler(w, erpc.StatusInternalServerError)
+			return
+		}
+		if access == db.AccessNone || (breakdown && access != db.AccessFull) {
+			delete(series, db.SourceSelfReported)
+			delete(series, db.SourceAssets)
+		}
+
+		erpc.MarshalSend(w, series)
+	})
+}
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"encoding/json"
 	"log"
 	"net/http"
 
@@ -30,6 +31,27 @@ func checkReqdPostParams(w http.ResponseWriter, r *http.Request, options ...stri
 	return true
 }
 
+type errorResponse struct {
+	Status int         `json:"status"`
+	Errors interface{} `json:"errors"`
+}
+
+// responseErrors sends the status code along with the reasons the request
+// was rejected, for errors that the caller is expected to fix
+func responseErrors(w http.ResponseWriter, status int, errs interface{}) {
+	w.Header().Add("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	var x errorResponse
+	x.Status = status
+	x.Errors = errs
+	err := json.NewEncoder(w).Encode(x)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
 func StartServer(portx int, insecure bool) {
 
 	erpc.SetupBasicHandlers()
@@ -41,6 +63,17 @@ func StartServer(portx int, insecure bool) {
 
 	setupActorsHandlers()
 	setupIpfsHandlers()
+	setupParisHandlers()
+	setupSeriesHandlers()
+	setupAggregationHandlers()
+	setupReconcileHandlers()
+	setupGhgHandlers()
+	setupAssetHandlers()
+	setupScoreHandlers()
+	setupDisclosureHandlers()
+	setupFeedHandlers()
+	setupDocumentHandlers()
+	setupVerifyHandlers()
 
 	setupSwytchApis()
 	setupDataHandlers()
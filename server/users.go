@@ -78,6 +78,33 @@ func CheckGetAuth(w http.ResponseWriter, r *http.Request) (database.User, error)
 	return user, nil
 }
 
+// CheckGetViewer checks a GET request and returns the user viewing the data,
+// which decides what data they can see. Viewers that don't pass their
+// credentials, or that aren't verified for their entity, see what the public
+// sees and are returned as an empty user.
+func CheckGetViewer(w http.ResponseWriter, r *http.Request) (database.User, error) {
+	var viewer database.User
+	err := erpc.CheckGet(w, r)
+	if err != nil {
+		return viewer, errors.Wrap(err, "could not checkgetviewer")
+	}
+
+	if r.URL.Query().Get("username") == "" && r.URL.Query().Get("access_token") == "" {
+		return viewer, nil
+	}
+
+	user, err := database.ValidateAccessToken(r.URL.Query().Get("username"), r.URL.Query().Get("access_token"))
+	if err != nil {
+		erpc.ResponseHandler(w, erpc.StatusUnauthorized)
+		return viewer, errors.New("user not found in database, quitting")
+	}
+
+	if user.Verified {
+		viewer = user
+	}
+	return viewer, nil
+}
+
 func CheckPostAuth(w http.ResponseWriter, r *http.Request) (database.User, error) {
 	var user database.User
 	err := erpc.CheckPost(w, r)
@@ -146,6 +173,12 @@ func retrieveAllUsers() {
 			return
 		}
 
+		// anyone can list users, so their credentials are left out
+		for i := range users {
+			users[i].Pwhash = ""
+			users[i].AccessToken = ""
+		}
+
 		erpc.MarshalSend(w, users)
 	})
 }
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	erpc "github.com/Varunram/essentials/rpc"
+	"github.com/YaleOpenLab/openclimate/anchor"
+	db "github.com/YaleOpenLab/openclimate/database"
+)
+
+func setupVerifyHandlers() {
+	verifyHash()
+}
+
+/*
+	Verify that data committed to IPFS is anchored. The inclusion proof of
+	the data's hash is looked up, the root of its batch is recomputed from the
+	hash and the proof, and compared against the root read back from the
+	ledger the batch is anchored on. The proof and the receipt of where the
+	root is anchored are returned with the outcome, so that the check can be
+	repeated independently (see verifier). The value the oracle computed for
+	the data is left out if the actor doesn't disclose it to the viewer.
+	Reports can only be looked up by viewers who can see their IPFS hash.
+
+	URL parameters (one of):
+	- "hash": the IPFS hash of the data
+	- "report_id": the ID of a report, whose IPFS hash is verified
+	- "username", "access_token" (optional): the viewer's credentials
+*/
+func verifyHash() {
+	http.HandleFunc("/verify", func(w http.ResponseWriter, r *http.Request) {
+		viewer, err := CheckGetViewer(w, r)
+		if err != nil {
+			return
+		}
+
+		d := newDisclosure(viewer)
+		hash := r.URL.Query().Get("hash")
+		if r.URL.Query().Get("report_id") != "" {
+			reportID, err := strconv.Atoi(r.URL.Query().Get("report_id"))
+			if err != nil {
+				log.Println(err)
+				erpc.ResponseHandler(w, erpc.StatusBadRequest)
+				return
+			}
+
+			report, err := db.RetrieveReport(reportID)
+			if err != nil || report.Index == 0 {
+				log.Println(err)
+				erpc.ResponseHandler(w, erpc.StatusNotFound)
+				return
+			}
+
+			access, err := d.access(report.ActorType, report.ActorID, reportField(report.ReportType))
+			if err != nil {
+				log.Println(err)
+				erpc.ResponseHandler(w, erpc.StatusInternalServerError)
+				return
+			}
+			if access != db.AccessFull {
+				erpc.ResponseHandler(w, erpc.StatusUnauthorized)
+				return
+			}
+			hash = report.IpfsHash
+		}
+
+		if hash == "" {
+			erpc.ResponseHandler(w, erpc.StatusBadRequest)
+			return
+		}
+
+		v, err := anchor.Verify(hash)
+		if err != nil {
+			log.Println(err)
+			erpc.ResponseHandler(w, erpc.StatusNotFound)
+			return
+		}
+
+		access, err := d.access(v.Leaf.ActorType, v.Leaf.ActorID, reportField(v.Leaf.ReportType))
+		if err != nil {
+			log.Println(err)
+			erpc.ResponseHandler(w, erpc.StatusInternalServerError)
+			return
+		}
+		if access == db.AccessNone {
+			v.Leaf.DataVal = 0
+		}
+
+		erpc.MarshalSend(w, v)
+	})
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/YaleOpenLab/openclimate/anchor"
+	globals "github.com/YaleOpenLab/openclimate/globals"
+	"github.com/YaleOpenLab/openclimate/ipfs"
+	flags "github.com/jessevdk/go-flags"
+)
+
+// verifier checks that data committed to IPFS by the platform is anchored.
+// The platform only provides the inclusion proof and the receipt of where
+// the root is anchored; the root is recomputed here and compared against the
+// root read from the ledger directly, so the platform doesn't have to be
+// trusted. Neither is the receipt: the ledger and the Stellar account the
+// root has to be on are passed by the user.
+
+var opts struct {
+	Api      string `long:"api" description:"The URL of the platform's API" default:"http://localhost:8080"`
+	Hash     string `long:"hash" description:"The IPFS hash of the data to verify"`
+	Report   int    `long:"report" description:"The ID of the report to verify"`
+	File     string `long:"file" description:"A copy of the data to verify, whose hash is computed locally"`
+	Username string `long:"username" description:"Username, to verify reports that aren't public"`
+	Token    string `long:"token" description:"Access token, to verify reports that aren't public"`
+
+	Ledger       string `long:"ledger" description:"The ledger the root has to be anchored on: ethereum or stellar" default:"ethereum"`
+	EthRpc       string `long:"ethrpc" description:"The Ethereum node the root is read from. Default: Kovan via Infura"`
+	RootContract string `long:"rootcontract" description:"The address of the root storage contract"`
+	Horizon      string `long:"horizon" description:"The Horizon server Stellar roots are read from. Default: Stellar testnet"`
+	Account      string `long:"account" description:"The Stellar account the platform anchors roots from, required with --ledger stellar"`
+}
+
+// ledgerAnchor returns the anchor roots are read back from, for the ledger
+// the user trusts. The platform's local log can't be read from here.
+func ledgerAnchor() (anchor.Anchor, error) {
+	switch opts.Ledger {
+	case anchor.LedgerEthereum:
+		return anchor.NewAnchor(anchor.LedgerEthereum)
+	case anchor.LedgerStellar:
+		if opts.Account == "" {
+			return nil, fmt.Errorf("--account is required to verify roots on stellar")
+		}
+		a := anchor.NewStellarAnchor(globals.StellarHorizonUrl, "", "")
+		a.Source = opts.Account
+		return a, nil
+	default:
+		return nil, fmt.Errorf("roots on %s can't be verified independently of the platform", opts.Ledger)
+	}
+}
+
+// fetchProof retrieves the inclusion proof of a hash or report from the
+// platform
+func fetchProof() (anchor.Verification, error) {
+	var v anchor.Verification
+
+	params := url.Values{}
+	if opts.Hash != "" {
+		params.Set("hash", opts.Hash)
+	} else {
+		params.Set("report_id", strconv.Itoa(opts.Report))
+	}
+	if opts.Username != "" {
+		params.Set("username", opts.Username)
+		params.Set("access_token", opts.Token)
+	}
+
+	resp, err := http.Get(strings.TrimSuffix(opts.Api, "/") + "/verify?" + params.Encode())
+	if err != nil {
+		return v, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return v, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return v, fmt.Errorf("platform responded with %s: %s", resp.Status, body)
+	}
+	err = json.Unmarshal(body, &v)
+	return v, err
+}
+
+func main() {
+	_, err := flags.Parse(&opts)
+	if err != nil {
+		os.Exit(1)
+	}
+	if opts.EthRpc != "" {
+		globals.EthRpcUrl = opts.EthRpc
+	}
+	if opts.RootContract != "" {
+		globals.EthRootContract = opts.RootContract
+	}
+	if opts.Horizon != "" {
+		globals.StellarHorizonUrl = opts.Horizon
+	}
+
+	if opts.File != "" {
+		data, err := ioutil.ReadFile(opts.File)
+		if err != nil {
+			log.Fatal(err)
+		}
+		hash := ipfs.ComputeCID(data)
+		if opts.Hash != "" && opts.Hash != hash {
+			log.Fatal("file has hash ", hash, ", not ", opts.Hash)
+		}
+		opts.Hash = hash
+	}
+	if opts.Hash == "" && opts.Report == 0 {
+		log.Fatal("one of --hash, --report or --file is required")
+	}
+	a, err := ledgerAnchor()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	v, err := fetchProof()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// the hash of a report is taken from the platform, unless a copy of the
+	// report is passed with --file
+	if opts.Hash != "" {
+		v.Hash = opts.Hash
+	}
+
+	fmt.Println("hash:       ", v.Hash)
+	if v.Leaf.BatchID == 0 || v.Batch.Status != anchor.BatchAnchored {
+		fmt.Println("not anchored:", v.Error)
+		os.Exit(1)
+	}
+
+	fmt.Println("reported by:", v.Leaf.ActorType, v.Leaf.ActorID, "("+v.Leaf.ReportType+")")
+	fmt.Println("batch:      ", v.Batch.Index, "of", v.Batch.Leaves, "hashes, anchored", v.Batch.Anchored)
+	fmt.Println("ledger:     ", v.Batch.Receipt.Ledger)
+	fmt.Println("timestamp:  ", v.Batch.Receipt.Timestamp)
+	for _, txHash := range v.Batch.Receipt.TxHashes {
+		fmt.Println("transaction:", txHash)
+	}
+	fmt.Println("proof:")
+	for _, step := range v.Leaf.Proof {
+		side := "right"
+		if step.Left {
+			side = "left "
+		}
+		fmt.Println("  ", side, step.Hash)
+	}
+
+	if v.Batch.Receipt.Ledger != opts.Ledger {
+		fmt.Println("verification failed: root is anchored on " + v.Batch.Receipt.Ledger + ", not " + opts.Ledger)
+		os.Exit(1)
+	}
+	v.CheckWith(a)
+
+	fmt.Println("computed root:", v.ComputedRoot)
+	fmt.Println("ledger root:  ", v.ChainRoot)
+	if !v.Verified {
+		fmt.Println("verification failed:", v.Error)
+		os.Exit(1)
+	}
+	fmt.Println("verified")
+}